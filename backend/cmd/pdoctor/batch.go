@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/batch"
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/parser"
+)
+
+// runBatch diagnoses every track in a directory or archive and writes one
+// summary
+func runBatch(e *env, args []string) error {
+	fs := newFlagSet(e, "batch", "[flags] <directory|archive>")
+	var opts optionFlags
+	opts.register(fs)
+	workers := fs.Int("workers", 0, "files diagnosed in parallel; 0 means one per CPU")
+	output := fs.String("o", "-", "summary file; - writes to stdout")
+	to := fs.String("to", "", "summary format (csv, json); taken from -o's extension, else csv")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	format := strings.ToLower(*to)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*output)), ".")
+	}
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unsupported summary format %q", format)
+	}
+
+	options, err := opts.request()
+	if err != nil {
+		return err
+	}
+	service := diagnosis.NewService()
+	if err := service.Validate(options); err != nil {
+		return err
+	}
+
+	files, err := batchFiles(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := batch.NewRunnerWithWorkers(service, *workers)
+	summary, err := runner.Run(ctx, files, options)
+	if err != nil {
+		return err
+	}
+
+	out := e.stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if format == "json" {
+		err = summary.WriteJSON(out)
+	} else {
+		err = summary.WriteCSV(out)
+	}
+	if err != nil {
+		return err
+	}
+
+	agg := summary.Aggregate
+	fmt.Fprintf(e.stderr, "Diagnosed %d tracks (%d failed) with %d workers in %s; median health score %.0f\n",
+		agg.Files, agg.Failed, runner.Workers(), time.Duration(summary.Duration)*time.Millisecond, agg.Score.Median)
+	return nil
+}
+
+// batchFiles lists the inputs of a batch run: the files below a directory
+// or the tracks in an archive
+func batchFiles(path string) ([]batch.File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return batch.DirFiles(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return batch.ArchiveFiles(filepath.Base(path), data, parser.DefaultMaxDecompressedSize)
+}
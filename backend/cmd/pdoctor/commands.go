@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/model"
+	"github.com/positiondoctor/backend/internal/parser"
+)
+
+// runDiagnose prints the health score and the anomaly table
+func runDiagnose(e *env, args []string) error {
+	fs := newFlagSet(e, "diagnose", "[flags] [file]")
+	var opts optionFlags
+	opts.register(fs)
+	asJSON := fs.Bool("json", false, "print the full diagnosis as JSON")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	result, err := diagnoseInput(e, fs, &opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return writeJSON(e.stdout, result.Data())
+	}
+
+	score := result.Diagnostics.HealthScore
+	fmt.Fprintf(e.stdout, "Health score: %d (%s)\n\n", score.Total, score.Rating)
+
+	w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tSCORE\tWEIGHT")
+	for _, name := range sortedKeys(score.Breakdown) {
+		detail := score.Breakdown[name]
+		fmt.Fprintf(w, "%s\t%.1f\t%.2f\n", name, detail.Score, detail.Weight)
+	}
+	w.Flush()
+	fmt.Fprintln(e.stdout)
+
+	if len(result.Diagnostics.Anomalies) == 0 {
+		fmt.Fprintln(e.stdout, "No anomalies found.")
+		return nil
+	}
+
+	w = tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tSEVERITY\tCOUNT\tDESCRIPTION")
+	for _, a := range result.Diagnostics.Anomalies {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", a.Type, a.Severity, a.Count, a.Description)
+	}
+	return w.Flush()
+}
+
+// runFix writes the corrected track
+func runFix(e *env, args []string) error {
+	fs := newFlagSet(e, "fix", "[flags] [file]")
+	var opts optionFlags
+	opts.register(fs)
+	output := fs.String("o", "-", "output file; - writes to stdout")
+	to := fs.String("to", "", "output format ("+strings.Join(parser.ExportFormats(), ", ")+"); taken from -o's extension, else gpx")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	format := strings.ToLower(*to)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*output)), ".")
+	}
+	if format == "" {
+		format = "gpx"
+	}
+	if !contains(parser.ExportFormats(), format) {
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+
+	result, err := diagnoseInput(e, fs, &opts)
+	if err != nil {
+		return err
+	}
+
+	data, err := parser.Export(format, result.Points, "PositionDoctor Corrected Trajectory")
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		_, err = e.stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.stderr, "Wrote %d points to %s (health score %d)\n",
+		len(result.Points), *output, result.Diagnostics.HealthScore.Total)
+	return nil
+}
+
+// runStats prints the trajectory statistics before and after correction
+func runStats(e *env, args []string) error {
+	fs := newFlagSet(e, "stats", "[flags] [file]")
+	var opts optionFlags
+	opts.register(fs)
+	asJSON := fs.Bool("json", false, "print the statistics as JSON")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	result, err := diagnoseInput(e, fs, &opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return writeJSON(e.stdout, map[string]model.TrajectoryStats{
+			"original":  result.Original,
+			"corrected": result.Corrected,
+		})
+	}
+
+	o, c := result.Original, result.Corrected
+	w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tORIGINAL\tCORRECTED")
+	fmt.Fprintf(w, "Points\t%d\t%d\n", o.PointCount, c.PointCount)
+	fmt.Fprintf(w, "Distance (m)\t%.1f\t%.1f\n", o.Distance, c.Distance)
+	fmt.Fprintf(w, "Duration\t%s\t%s\n", seconds(o.DurationSecs), seconds(c.DurationSecs))
+	fmt.Fprintf(w, "Avg speed (km/h)\t%.1f\t%.1f\n", o.AvgSpeed, c.AvgSpeed)
+	fmt.Fprintf(w, "Max speed (km/h)\t%.1f\t%.1f\n", o.MaxSpeed, c.MaxSpeed)
+	fmt.Fprintf(w, "Elevation min/max (m)\t%.1f / %.1f\t%.1f / %.1f\n", o.Elevation.Min, o.Elevation.Max, c.Elevation.Min, c.Elevation.Max)
+	fmt.Fprintf(w, "Elevation gain/loss (m)\t%.1f / %.1f\t%.1f / %.1f\n", o.Elevation.Gain, o.Elevation.Loss, c.Elevation.Gain, c.Elevation.Loss)
+	fmt.Fprintf(w, "Bounds N/S\t%.6f / %.6f\t%.6f / %.6f\n", o.Bounds.North, o.Bounds.South, c.Bounds.North, c.Bounds.South)
+	fmt.Fprintf(w, "Bounds E/W\t%.6f / %.6f\t%.6f / %.6f\n", o.Bounds.East, o.Bounds.West, c.Bounds.East, c.Bounds.West)
+	return w.Flush()
+}
+
+// newFlagSet creates a subcommand flag set that reports errors instead of
+// exiting
+func newFlagSet(e *env, name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: pdoctor %s %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// diagnoseInput reads the track named by the remaining arguments and
+// diagnoses it. Ctrl-C cancels a long diagnosis.
+func diagnoseInput(e *env, fs *flag.FlagSet, opts *optionFlags) (*diagnosis.Result, error) {
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return nil, errUsage
+	}
+
+	options, err := opts.request()
+	if err != nil {
+		return nil, err
+	}
+
+	service := diagnosis.NewService()
+	if err := service.Validate(options); err != nil {
+		return nil, err
+	}
+
+	points, err := readTrack(e, fs.Arg(0), opts.format)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) < 2 {
+		return nil, fmt.Errorf("too few valid points (minimum 2 required)")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return service.Diagnose(ctx, points, options)
+}
+
+// readTrack parses the track in path, or stdin for "" and "-"
+func readTrack(e *env, path, format string) ([]model.Point, error) {
+	var data []byte
+	var err error
+	if path == "" || path == "-" {
+		path = ""
+		data, err = io.ReadAll(e.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	factory := parser.NewParserFactory()
+	if format != "" {
+		return factory.ParseAs(strings.ToLower(format), data)
+	}
+	return factory.ParseFile(path, data)
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// seconds formats a duration given in seconds
+func seconds(s int64) string {
+	return (time.Duration(s) * time.Second).String()
+}
+
+// sortedKeys returns the keys of a score breakdown in order
+func sortedKeys(m map[string]model.ScoreDetail) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
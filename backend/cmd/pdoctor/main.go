@@ -0,0 +1,87 @@
+// Command pdoctor diagnoses and corrects track files offline, without the
+// HTTP server.
+//
+//	pdoctor diagnose [flags] [file]   print the health score and anomalies
+//	pdoctor fix      [flags] [file]   write the corrected track
+//	pdoctor stats    [flags] [file]   print the trajectory summary
+//	pdoctor batch    [flags] <dir>    summarise every track in a directory or archive
+//
+// The track is read from stdin when no file (or "-") is given.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// command is one pdoctor subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(env *env, args []string) error
+}
+
+// env holds the process streams so commands can be run from tests
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var commands = []command{
+	{"diagnose", "print the health score and anomaly table", runDiagnose},
+	{"fix", "write the corrected track as GPX, KML, GeoJSON, ...", runFix},
+	{"stats", "print the trajectory statistics", runStats},
+	{"batch", "summarise every track in a directory or archive as CSV or JSON", runBatch},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}))
+}
+
+// run executes the subcommand in args and returns the exit code
+func run(args []string, e *env) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(e.stderr)
+		return exitUsage
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		if err := c.run(e, args[1:]); err != nil {
+			if err == errUsage {
+				return exitUsage
+			}
+			fmt.Fprintf(e.stderr, "pdoctor %s: %v\n", c.name, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	fmt.Fprintf(e.stderr, "pdoctor: unknown command %q\n\n", args[0])
+	usage(e.stderr)
+	return exitUsage
+}
+
+// usage prints the list of subcommands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: pdoctor <command> [flags] [file]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The track is read from stdin when no file is given.")
+	fmt.Fprintln(w, "Run 'pdoctor <command> -h' for the command's flags.")
+}
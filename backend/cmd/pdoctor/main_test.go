@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/positiondoctor/backend/internal/batch"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func runCLI(t *testing.T, stdin []byte, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, &env{stdin: bytes.NewReader(stdin), stdout: &stdout, stderr: &stderr})
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Diagnose(t *testing.T) {
+	code, stdout, stderr := runCLI(t, nil, "diagnose", "../../testdata/demo_track_with_anomalies.gpx")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "Health score:") || !strings.Contains(stdout, "SEVERITY") {
+		t.Errorf("diagnose output missing score or anomaly table:\n%s", stdout)
+	}
+}
+
+func TestRun_FixFromStdin(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/sample.gpx")
+	if err != nil {
+		t.Fatalf("Failed to read sample: %v", err)
+	}
+
+	code, stdout, stderr := runCLI(t, data, "fix", "-to", "geojson")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+
+	var feature struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &feature); err != nil || feature.Type != "Feature" {
+		t.Errorf("fix should write a GeoJSON feature, got %q (%v)", stdout, err)
+	}
+}
+
+func TestRun_Stats(t *testing.T) {
+	code, stdout, stderr := runCLI(t, nil, "stats", "-json", "-steps", "outlierRemoval", "../../testdata/sample.gpx")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+
+	var stats map[string]model.TrajectoryStats
+	if err := json.Unmarshal([]byte(stdout), &stats); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if stats["original"].PointCount == 0 {
+		t.Errorf("original point count should not be 0")
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"repair"}, exitUsage},
+		{"unknown flag", []string{"diagnose", "-bogus"}, exitUsage},
+		{"unknown step", []string{"fix", "-steps", "bogus", "../../testdata/sample.gpx"}, exitError},
+		{"unknown output format", []string{"fix", "-to", "shp", "../../testdata/sample.gpx"}, exitError},
+		{"missing file", []string{"stats", "missing.gpx"}, exitError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, _ := runCLI(t, nil, tt.args...); code != tt.code {
+				t.Errorf("exit code = %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestOptionFlags_Request(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "options.yaml")
+	os.WriteFile(yamlPath, []byte(`
+thresholds:
+  maxSpeed: 30
+algorithms:
+  simplification: false
+parameters:
+  adaptiveRTS:
+    processNoise: 2
+`), 0o644)
+
+	opts := optionFlags{config: yamlPath, maxJump: 200}
+	options, err := opts.request()
+	if err != nil {
+		t.Fatalf("request() error = %v", err)
+	}
+
+	if options.Thresholds.MaxSpeed != 30 {
+		t.Errorf("MaxSpeed = %v, want 30 from config", options.Thresholds.MaxSpeed)
+	}
+	if options.Thresholds.MaxJump != 200 {
+		t.Errorf("MaxJump = %v, want 200 from flag", options.Thresholds.MaxJump)
+	}
+	if options.Thresholds.MaxAcceleration != 0 {
+		t.Errorf("MaxAcceleration should stay unset for the profile to preset")
+	}
+	if options.Algorithms.Simplification || !options.Algorithms.AdaptiveRTS {
+		t.Errorf("Algorithms = %+v, want simplification off and the rest on", options.Algorithms)
+	}
+	if options.Parameters.AdaptiveRTS.ProcessNoise != 2 {
+		t.Errorf("ProcessNoise = %v, want 2", options.Parameters.AdaptiveRTS.ProcessNoise)
+	}
+
+	jsonPath := filepath.Join(dir, "options.json")
+	os.WriteFile(jsonPath, []byte(`{"thresholds": {"maxSped": 30}}`), 0o644)
+	opts = optionFlags{config: jsonPath}
+	if _, err := opts.request(); err == nil {
+		t.Errorf("request() should reject unknown config fields")
+	}
+}
+
+func TestRun_Batch(t *testing.T) {
+	summaryPath := filepath.Join(t.TempDir(), "summary.json")
+	code, _, stderr := runCLI(t, nil, "batch", "-workers", "2", "-o", summaryPath, "../../testdata")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr)
+	}
+
+	data, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("summary not written: %v", err)
+	}
+	var summary batch.Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("invalid JSON summary: %v", err)
+	}
+	if summary.Aggregate.Files == 0 || summary.Aggregate.Succeeded == 0 {
+		t.Errorf("Aggregate = %+v, want diagnosed files", summary.Aggregate)
+	}
+}
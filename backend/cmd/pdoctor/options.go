@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/positiondoctor/backend/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+// errUsage reports that flag parsing failed; the flag package has already
+// printed the problem
+var errUsage = errors.New("usage")
+
+// optionFlags are the diagnose options shared by all subcommands
+type optionFlags struct {
+	config          string
+	format          string
+	profile         string
+	maxSpeed        float64
+	maxAcceleration float64
+	maxJump         float64
+	epsilon         float64
+	algorithms      string
+	steps           string
+}
+
+// register adds the option flags to fs
+func (o *optionFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.config, "config", "", "JSON or YAML file with diagnose options (same fields as the API's options)")
+	fs.StringVar(&o.format, "format", "", "input format (gpx, kml, nmea, fit, tcx, csv, geojson); detected when empty")
+	fs.StringVar(&o.profile, "profile", "", "transport mode presets: walk, bike, car, train, flight, boat or auto")
+	fs.Float64Var(&o.maxSpeed, "max-speed", 0, "speed threshold in km/h")
+	fs.Float64Var(&o.maxAcceleration, "max-acceleration", 0, "acceleration threshold in m/s²")
+	fs.Float64Var(&o.maxJump, "max-jump", 0, "jump threshold in meters")
+	fs.Float64Var(&o.epsilon, "epsilon", 0, "Douglas-Peucker tolerance in meters")
+	fs.StringVar(&o.algorithms, "algorithms", "", "comma-separated algorithms to enable: adaptiveRTS, splineInterpolation, simplification, outlierRemoval")
+	fs.StringVar(&o.steps, "steps", "", "comma-separated correction steps in order; overrides -algorithms and the config's algorithms")
+}
+
+// request builds the diagnose options: defaults, then the config file, then
+// flags that were set
+func (o *optionFlags) request() (model.DiagnoseRequest, error) {
+	options := model.DefaultRequest()
+
+	if o.config != "" {
+		if err := loadConfig(o.config, &options); err != nil {
+			return options, err
+		}
+	}
+
+	if o.profile != "" {
+		options.Profile = o.profile
+	}
+	if o.maxSpeed > 0 {
+		options.Thresholds.MaxSpeed = o.maxSpeed
+	}
+	if o.maxAcceleration > 0 {
+		options.Thresholds.MaxAcceleration = o.maxAcceleration
+	}
+	if o.maxJump > 0 {
+		options.Thresholds.MaxJump = o.maxJump
+	}
+	if o.epsilon > 0 {
+		options.Output.SimplifyEpsilon = o.epsilon
+	}
+
+	if o.algorithms != "" {
+		options.Algorithms = model.AlgorithmOptions{}
+		for _, name := range splitList(o.algorithms) {
+			switch name {
+			case model.StepAdaptiveRTS:
+				options.Algorithms.AdaptiveRTS = true
+			case model.StepSplineInterpolation:
+				options.Algorithms.SplineInterpolation = true
+			case model.StepSimplification:
+				options.Algorithms.Simplification = true
+			case model.StepOutlierRemoval:
+				options.Algorithms.OutlierRemoval = true
+			default:
+				return options, fmt.Errorf("unknown algorithm %q", name)
+			}
+		}
+	}
+
+	if o.steps != "" {
+		options.Steps = nil
+		for _, name := range splitList(o.steps) {
+			options.Steps = append(options.Steps, model.StepConfig{Name: name})
+		}
+	}
+
+	return options, nil
+}
+
+// loadConfig reads a JSON or YAML options file over options. YAML uses the
+// same field names as the JSON API.
+func loadConfig(path string, options *model.DiagnoseRequest) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid config %s: %w", path, err)
+		}
+		if doc == nil {
+			return nil
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(options); err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
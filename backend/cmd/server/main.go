@@ -11,12 +11,19 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/positiondoctor/backend/internal/algorithm"
 	"github.com/positiondoctor/backend/internal/api"
+	"github.com/positiondoctor/backend/internal/store"
 )
 
 var (
 	port   = flag.String("port", "8080", "Server port")
 	host   = flag.String("host", "", "Server host")
+	drain  = flag.Duration("drain-timeout", 5*time.Minute, "How long shutdown waits for queued and running jobs")
+
+	reportDir = flag.String("report-dir", "", "Directory for stored reports; kept in memory when empty")
+	reportTTL = flag.Duration("report-ttl", store.DefaultTTL, "How long reports stay available")
+	reports   = flag.Int("reports", store.DefaultCapacity, "Reports kept in memory before the least recently used is evicted")
 )
 
 func main() {
@@ -24,8 +31,19 @@ func main() {
 
 	addr := fmt.Sprintf("%s:%s", *host, *port)
 
+	// Create report store
+	var reportStore store.ReportStore = store.NewMemoryStoreWithLimits(*reports, *reportTTL)
+	if *reportDir != "" {
+		fileStore, err := store.NewFileStoreWithTTL(*reportDir, *reportTTL)
+		if err != nil {
+			log.Fatalf("Report store: %v", err)
+		}
+		reportStore = fileStore
+	}
+
 	// Create server
-	server := api.GetServer(addr)
+	handler := api.NewHandlerWithStore(algorithm.DefaultRegistry(), reportStore)
+	server := api.NewServer(addr, handler)
 
 	// Configure server
 	server.ReadTimeout = 30 * time.Second
@@ -47,6 +65,13 @@ func main() {
 
 	log.Println("Shutting down server...")
 
+	// Drain async jobs first so clients can still poll for their results
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), *drain)
+	if err := handler.Shutdown(drainCtx); err != nil {
+		log.Printf("Jobs canceled after drain timeout: %v", err)
+	}
+	cancelDrain()
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
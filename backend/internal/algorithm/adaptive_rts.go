@@ -1,28 +1,35 @@
 package algorithm
 
 import (
+	"context"
 	"math"
 
 	"github.com/positiondoctor/backend/internal/model"
 )
 
 // AdaptiveRTS implements the Adaptive Rauch-Tung-Striebel smoother
-// with Variational Bayesian noise estimation
+// with Variational Bayesian noise estimation. Filtering runs in a local
+// east/north frame in meters, so the noise settings mean the same thing at
+// every latitude.
 type AdaptiveRTS struct {
-	// Process noise initial values
-	initQ float64
-	// Measurement noise initial values
-	initR float64
-	// Variational Bayesian parameters
+	// ProcessNoise is the spectral density of the white-noise acceleration
+	// driving the constant velocity model, in m²/s³
+	ProcessNoise float64
+	// MeasurementNoise is the initial position variance per axis, in m²,
+	// refined by Variational Bayes for fixes that report no accuracy
+	MeasurementNoise float64
+	// Variational Bayesian prior strength (pseudo-observations)
 	vbAlpha float64
-	vbBeta  float64
-	// State dimension (2D: lat, lon)
+	// Variational Bayesian prior scale, m²; vbAlpha × MeasurementNoise when
+	// zero, so the prior starts at MeasurementNoise
+	vbBeta float64
+	// State dimension (2D: east, north)
 	stateDim int
 }
 
 // FilterState represents the forward filter state
 type FilterState struct {
-	// State vector [lat, lon, vel_lat, vel_lon]
+	// State vector [east, north, vel_east, vel_north] in m and m/s
 	X []float64
 	// Covariance matrix
 	P [][]float64
@@ -30,35 +37,131 @@ type FilterState struct {
 	Time float64
 }
 
-// NewAdaptiveRTS creates a new AdaptiveRTS smoother
+// NewAdaptiveRTS creates a new AdaptiveRTS smoother with default parameters
 func NewAdaptiveRTS() *AdaptiveRTS {
+	return NewAdaptiveRTSWithParameters(model.DefaultParameters().AdaptiveRTS)
+}
+
+// NewAdaptiveRTSWithParameters creates a new AdaptiveRTS smoother; unset
+// parameters use the defaults
+func NewAdaptiveRTSWithParameters(params model.RTSParameters) *AdaptiveRTS {
+	params = model.AlgorithmParameters{AdaptiveRTS: params}.WithDefaults().AdaptiveRTS
 	return &AdaptiveRTS{
-		initQ:    1e-3, // Process noise - 增加以允许更多状态变化
-		initR:    1e-1, // Measurement noise - 增加以适应GPS漂移
-		vbAlpha:  1.0,  // Initial alpha
-		vbBeta:   10.0, // Initial beta - 增加初始测量噪声估计
-		stateDim: 4,    // [lat, lon, v_lat, v_lon]
+		ProcessNoise:     params.ProcessNoise,
+		MeasurementNoise: params.MeasurementNoise,
+		vbAlpha:          params.VBAlpha,
+		vbBeta:           params.VBBeta,
+		stateDim:         4, // [e, n, v_e, v_n]
+	}
+}
+
+// Parameters returns the parameters the smoother runs with
+func (a *AdaptiveRTS) Parameters() model.RTSParameters {
+	return model.RTSParameters{
+		ProcessNoise:     a.ProcessNoise,
+		MeasurementNoise: a.MeasurementNoise,
+		VBAlpha:          a.vbAlpha,
+		VBBeta:           a.priorBeta(),
 	}
 }
 
-// Smooth applies AdaptiveRTS smoothing to trajectory points
+// initialVelocityVariance is the prior velocity variance, (m/s)²
+const initialVelocityVariance = 100.0
+
+// Smooth applies AdaptiveRTS smoothing to trajectory points.
+// Segments are smoothed independently so no state carries over a pause.
 func (a *AdaptiveRTS) Smooth(points []model.Point) []model.Point {
+	result, _ := a.SmoothContext(context.Background(), points)
+	return result
+}
+
+// SmoothContext is Smooth that stops with the context's error once ctx is
+// canceled
+func (a *AdaptiveRTS) SmoothContext(ctx context.Context, points []model.Point) ([]model.Point, error) {
 	if len(points) < 2 {
-		return points
+		return points, nil
+	}
+
+	if segments := model.SplitSegments(points); len(segments) > 1 {
+		result := make([]model.Point, 0, len(points))
+		for _, segment := range segments {
+			smoothed, err := a.SmoothContext(ctx, segment)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, smoothed...)
+		}
+		return result, nil
+	}
+
+	// Step 1: Project into a local metric frame
+	frame := NewLocalFrame(points)
+	measurements := make([][2]float64, len(points))
+	for i, p := range points {
+		e, n := frame.ToENU(p.Lat, p.Lon)
+		measurements[i] = [2]float64{e, n}
 	}
 
-	// Step 1: Forward EKF filtering with adaptive noise estimation
-	forwardStates, qEstimates, _ := a.forwardFilter(points)
+	// Step 2: Forward Kalman filtering with adaptive noise estimation
+	forwardStates, qEstimates, _, err := a.forwardFilter(ctx, points, measurements)
+	if err != nil {
+		return nil, err
+	}
 
-	// Step 2: Backward RTS smoothing
-	smoothedStates := a.backwardSmooth(forwardStates, qEstimates)
+	// Step 3: Backward RTS smoothing
+	smoothedStates, err := a.backwardSmooth(ctx, forwardStates, qEstimates)
+	if err != nil {
+		return nil, err
+	}
 
-	// Step 3: Convert states back to points
-	result := a.statesToPoints(points, smoothedStates)
+	// Step 4: Convert states back to points
+	result := a.statesToPoints(points, smoothedStates, frame)
 
-	// Step 4: Apply drift correction for points marked as drift
+	// Step 5: Apply drift correction for points marked as drift
 	result = a.correctDriftSegments(points, result)
 
+	return result, nil
+}
+
+// CollapseStops moves the points in each stop's ranges, matched by
+// Point.Index, to the stop's centroid; excursions keep their position.
+// Smoothing turns jitter while standing still into a slow scribble; a stop
+// has one position.
+func (a *AdaptiveRTS) CollapseStops(points []model.Point, stops []model.Stop) []model.Point {
+	if len(stops) == 0 {
+		return points
+	}
+
+	result := make([]model.Point, len(points))
+	copy(result, points)
+	moved := make([]bool, len(result))
+	for i := range result {
+		p := &result[i]
+		if p.IsInterpolated {
+			continue
+		}
+		stop, ok := stopAt(stops, p.Index)
+		if !ok {
+			continue
+		}
+		if p.OriginalLat == 0 && p.OriginalLon == 0 {
+			p.OriginalLat, p.OriginalLon = p.Lat, p.Lon
+		}
+		p.Lat, p.Lon = stop.Lat, stop.Lon
+		p.FixedBy = FixedByRTS
+		moved[i] = true
+	}
+
+	// Recalculate speed and bearing around moved points
+	for i := 1; i < len(result); i++ {
+		if (moved[i] || moved[i-1]) && model.SameSegment(result[i-1], result[i]) {
+			result[i].Speed = model.CalculateSpeed(result[i-1], result[i])
+			result[i].Bearing = model.CalculateBearing(
+				result[i-1].Lat, result[i-1].Lon,
+				result[i].Lat, result[i].Lon,
+			)
+		}
+	}
 	return result
 }
 
@@ -72,20 +175,26 @@ func (a *AdaptiveRTS) GetShortName() string {
 	return "RTS平滑"
 }
 
-// forwardFilter performs forward Extended Kalman Filtering
-func (a *AdaptiveRTS) forwardFilter(points []model.Point) ([]FilterState, []float64, []float64) {
+// forwardFilter performs forward Kalman filtering on projected measurements
+func (a *AdaptiveRTS) forwardFilter(ctx context.Context, points []model.Point, measurements [][2]float64) ([]FilterState, []float64, []float64, error) {
 	n := len(points)
 	states := make([]FilterState, n)
 	qEstimates := make([]float64, n-1)
 	rEstimates := make([]float64, n)
 
-	// Initialize first state
-	states[0] = a.initState(points[0])
+	noise := a.newNoiseEstimate()
 
-	// Variational Bayesian parameters
-	alpha, beta := a.vbAlpha, a.vbBeta
+	// Initialize first state
+	states[0] = a.initState(points[0], measurements[0])
+	rEstimates[0] = a.measurementVariance(points[0], noise.variance())
 
 	for i := 1; i < n; i++ {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+
 		prevState := states[i-1]
 		dt := 1.0 // Default to 1 second
 
@@ -100,39 +209,75 @@ func (a *AdaptiveRTS) forwardFilter(points []model.Point) ([]FilterState, []floa
 		// Predict step
 		predictedState := a.predict(prevState, dt)
 
-		// Get current Q and R estimates
-		Q := alpha / beta
-
-		// Update step with measurement
-		innovation := points[i].Lat - predictedState.X[0]
-		innovationLon := points[i].Lon - predictedState.X[1]
-
-		// Update R estimate using variational Bayesian
-		alpha += 0.5
-		beta += 0.5 * (innovation*innovation + innovationLon*innovationLon)
-
-		R := alpha / beta
+		// Fixes that report their accuracy set their own measurement noise
+		Q := a.ProcessNoise
+		R := a.measurementVariance(points[i], noise.variance())
 
 		// Store noise estimates
 		qEstimates[i-1] = Q
 		rEstimates[i] = R
 
 		// Perform update
-		states[i] = a.update(predictedState, points[i], Q, R, dt)
+		states[i] = a.update(predictedState, measurements[i], R, dt)
+
+		noise.observe(measurements[i], states[i])
+	}
+
+	return states, qEstimates, rEstimates, nil
+}
+
+// noiseEstimate is the Variational Bayesian estimate of the measurement
+// variance, beta/alpha
+type noiseEstimate struct {
+	alpha, beta float64
+}
+
+// newNoiseEstimate starts an estimate from the configured prior
+func (a *AdaptiveRTS) newNoiseEstimate() noiseEstimate {
+	return noiseEstimate{alpha: a.vbAlpha, beta: a.priorBeta()}
+}
+
+// priorBeta returns the prior scale of the noise estimate
+func (a *AdaptiveRTS) priorBeta() float64 {
+	if a.vbBeta > 0 {
+		return a.vbBeta
 	}
+	return a.vbAlpha * a.MeasurementNoise
+}
 
-	return states, qEstimates, rEstimates
+// variance returns the estimated per-axis measurement variance in m²
+func (e noiseEstimate) variance() float64 {
+	return e.beta / e.alpha
 }
 
-// initState initializes the first state from a point
-func (a *AdaptiveRTS) initState(p model.Point) FilterState {
+// observe updates the estimate from the post-fit residual of an updated
+// state (VB-AKF)
+func (e *noiseEstimate) observe(z [2]float64, state FilterState) {
+	resE := z[0] - state.X[0]
+	resN := z[1] - state.X[1]
+	e.alpha += 1 // two measured dimensions
+	e.beta += 0.5 * (resE*resE + resN*resN + state.P[0][0] + state.P[1][1])
+}
+
+// measurementVariance returns the per-axis position variance of a fix in m²,
+// from its reported accuracy or the adaptive estimate
+func (a *AdaptiveRTS) measurementVariance(p model.Point, estimate float64) float64 {
+	if acc := model.EstimatedAccuracy(p); acc > 0 {
+		return acc * acc
+	}
+	return estimate
+}
+
+// initState initializes the first state from a measurement
+func (a *AdaptiveRTS) initState(p model.Point, z [2]float64) FilterState {
+	r := a.measurementVariance(p, a.MeasurementNoise)
 	return FilterState{
-		X: []float64{p.Lat, p.Lon, 0, 0},
+		X: []float64{z[0], z[1], 0, 0},
 		P: [][]float64{
-			{1e-4, 0, 0, 0},    // 位置方差 - 增加以允许更多测量修正
-			{0, 1e-4, 0, 0},
-			{0, 0, 1e-3, 0},     // 速度方差 - 增加以适应速度变化
-			{0, 0, 0, 1e-3},
+			{r, 0, 0, 0},
+			{0, r, 0, 0},
+			{0, 0, initialVelocityVariance, 0},
+			{0, 0, 0, initialVelocityVariance},
 		},
 		Time: float64(p.Time.Unix()),
 	}
@@ -154,7 +299,6 @@ func (a *AdaptiveRTS) predict(state FilterState, dt float64) FilterState {
 	newX[3] = state.X[3]
 
 	// Predict covariance: P = F*P*F' + Q
-	// Simplified calculation for efficiency
 	newP := a.predictCovariance(state.P, dt)
 
 	return FilterState{
@@ -166,56 +310,41 @@ func (a *AdaptiveRTS) predict(state FilterState, dt float64) FilterState {
 
 // predictCovariance predicts the covariance matrix
 func (a *AdaptiveRTS) predictCovariance(P [][]float64, dt float64) [][]float64 {
-	newP := make([][]float64, 4)
-	for i := range newP {
-		newP[i] = make([]float64, 4)
+	FP := matMul(transitionMatrix(dt), P)
+	newP := matMul(FP, transpose(transitionMatrix(dt)))
+
+	// Discrete white-noise acceleration: q * [[dt³/3, dt²/2], [dt²/2, dt]] per axis
+	q := a.ProcessNoise
+	for axis := 0; axis < 2; axis++ {
+		pos, vel := axis, axis+2
+		newP[pos][pos] += q * dt * dt * dt / 3
+		newP[pos][vel] += q * dt * dt / 2
+		newP[vel][pos] += q * dt * dt / 2
+		newP[vel][vel] += q * dt
 	}
 
-	// F*P*F' + Q (simplified)
-	newP[0][0] = P[0][0] + 2*dt*P[0][2] + dt*dt*P[2][2] + a.initQ*dt
-	newP[0][1] = P[0][1] + dt*P[0][3] + dt*P[1][2] + dt*dt*P[2][3]
-	newP[0][2] = P[0][2] + dt*P[2][2]
-	newP[0][3] = P[0][3] + dt*P[2][3]
-
-	newP[1][0] = newP[0][1]
-	newP[1][1] = P[1][1] + 2*dt*P[1][3] + dt*dt*P[3][3] + a.initQ*dt
-	newP[1][2] = P[1][2] + dt*P[2][3]
-	newP[1][3] = P[1][3] + dt*P[3][3]
-
-	newP[2][0] = newP[0][2]
-	newP[2][1] = newP[1][2]
-	newP[2][2] = P[2][2] + a.initQ*dt
-	newP[2][3] = P[2][3]
-
-	newP[3][0] = newP[0][3]
-	newP[3][1] = newP[1][3]
-	newP[3][2] = newP[2][3]
-	newP[3][3] = P[3][3] + a.initQ*dt
-
 	return newP
 }
 
-// update performs the update step with measurement
-func (a *AdaptiveRTS) update(state FilterState, point model.Point, Q, R float64, dt float64) FilterState {
+// update performs the update step with a measurement in meters
+func (a *AdaptiveRTS) update(state FilterState, z [2]float64, R float64, dt float64) FilterState {
 	// Measurement matrix H (we only measure position, not velocity)
 	// H = [[1, 0, 0, 0],
 	//      [0, 1, 0, 0]]
 
 	// Measurement residual (innovation)
-	yLat := point.Lat - state.X[0]
-	yLon := point.Lon - state.X[1]
+	yE := z[0] - state.X[0]
+	yN := z[1] - state.X[1]
 
-	// Residual covariance S = H*P*H' + R
-	// Simplified: S = P[0:2, 0:2] + R*I
+	// Residual covariance S = H*P*H' + R*I
 	S := [][]float64{
 		{state.P[0][0] + R, state.P[0][1]},
 		{state.P[1][0], state.P[1][1] + R},
 	}
 
 	// Kalman gain K = P*H'*S^(-1)
-	// Simplified calculation - 增加阈值以适应更大的协方差值
 	det := S[0][0]*S[1][1] - S[0][1]*S[1][0]
-	if math.Abs(det) < 1e-8 {
+	if math.Abs(det) < 1e-12 {
 		return state // Singular matrix, return predicted state
 	}
 
@@ -235,10 +364,9 @@ func (a *AdaptiveRTS) update(state FilterState, point model.Point, Q, R float64,
 
 	// Updated state: x = x + K*y
 	newX := make([]float64, 4)
-	newX[0] = state.X[0] + K[0][0]*yLat + K[0][1]*yLon
-	newX[1] = state.X[1] + K[1][0]*yLat + K[1][1]*yLon
-	newX[2] = state.X[2] + K[2][0]*yLat + K[2][1]*yLon
-	newX[3] = state.X[3] + K[3][0]*yLat + K[3][1]*yLon
+	for i := range newX {
+		newX[i] = state.X[i] + K[i][0]*yE + K[i][1]*yN
+	}
 
 	// Updated covariance: P = (I - K*H)*P
 	newP := a.updateCovariance(state.P, K)
@@ -246,7 +374,7 @@ func (a *AdaptiveRTS) update(state FilterState, point model.Point, Q, R float64,
 	return FilterState{
 		X:    newX,
 		P:    newP,
-		Time: state.Time + dt,
+		Time: state.Time,
 	}
 }
 
@@ -263,7 +391,7 @@ func (a *AdaptiveRTS) updateCovariance(P [][]float64, K [][]float64) [][]float64
 }
 
 // backwardSmooth performs backward RTS smoothing
-func (a *AdaptiveRTS) backwardSmooth(forwardStates []FilterState, qEstimates []float64) []FilterState {
+func (a *AdaptiveRTS) backwardSmooth(ctx context.Context, forwardStates []FilterState, qEstimates []float64) ([]FilterState, error) {
 	n := len(forwardStates)
 	smoothed := make([]FilterState, n)
 
@@ -272,6 +400,12 @@ func (a *AdaptiveRTS) backwardSmooth(forwardStates []FilterState, qEstimates []f
 
 	// Smooth from n-2 down to 0
 	for i := n - 2; i >= 0; i-- {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
 		dt := forwardStates[i+1].Time - forwardStates[i].Time
 		if dt <= 0 {
 			dt = 1.0
@@ -287,33 +421,18 @@ func (a *AdaptiveRTS) backwardSmooth(forwardStates []FilterState, qEstimates []f
 		smoothed[i] = a.applySmoothing(forwardStates[i], smoothed[i+1], predicted, C)
 	}
 
-	return smoothed
+	return smoothed, nil
 }
 
-// calculateSmoothingGain calculates the RTS smoothing gain
+// calculateSmoothingGain calculates the RTS smoothing gain C = P * F' * Pp^(-1)
 func (a *AdaptiveRTS) calculateSmoothingGain(P, Pp [][]float64, dt float64) [][]float64 {
-	C := make([][]float64, 4)
-	for i := range C {
-		C[i] = make([]float64, 4)
-	}
-
-	// C = P * F' * Pp^(-1)
-	// Simplified calculation - 增加阈值以适应更大的协方差值
-	det := Pp[0][0]*Pp[1][1] - Pp[0][1]*Pp[1][0]
-	if math.Abs(det) < 1e-8 {
-		return C
+	PpInv, ok := invertMatrix(Pp)
+	if !ok {
+		// Singular prediction: no smoothing correction
+		return make4x4()
 	}
 
-	invDet := 1.0 / det
-
-	// Only compute first two rows (for position smoothing)
-	// 增强平滑效果：使用完整的前向协方差与预测协方差的比值
-	C[0][0] = (P[0][0] + dt*P[2][0]) * Pp[1][1] * invDet
-	C[0][1] = (P[0][1] + dt*P[2][1]) * Pp[1][1] * invDet
-	C[1][0] = (P[1][0] + dt*P[3][0]) * Pp[1][1] * invDet
-	C[1][1] = (P[1][1] + dt*P[3][1]) * Pp[1][1] * invDet
-
-	return C
+	return matMul(matMul(P, transpose(transitionMatrix(dt))), PpInv)
 }
 
 // applySmoothing applies the RTS smoothing
@@ -325,23 +444,31 @@ func (a *AdaptiveRTS) applySmoothing(
 ) FilterState {
 	// x_s = x_f + C * (x_s(next) - x_predicted)
 	dx := make([]float64, 4)
-	dx[0] = nextSmoothed.X[0] - predicted.X[0]
-	dx[1] = nextSmoothed.X[1] - predicted.X[1]
-	dx[2] = nextSmoothed.X[2] - predicted.X[2]
-	dx[3] = nextSmoothed.X[3] - predicted.X[3]
+	for i := range dx {
+		dx[i] = nextSmoothed.X[i] - predicted.X[i]
+	}
 
 	newX := make([]float64, 4)
-	newX[0] = filtered.X[0] + C[0][0]*dx[0] + C[0][1]*dx[1]
-	newX[1] = filtered.X[1] + C[1][0]*dx[0] + C[1][1]*dx[1]
-	newX[2] = filtered.X[2] + C[0][0]*dx[2] + C[0][1]*dx[3]
-	newX[3] = filtered.X[3] + C[1][0]*dx[2] + C[1][1]*dx[3]
+	for i := range newX {
+		newX[i] = filtered.X[i]
+		for j := range dx {
+			newX[i] += C[i][j] * dx[j]
+		}
+	}
 
-	// Smoothed covariance
-	newP := make([][]float64, 4)
+	// P_s = P_f + C * (P_s(next) - P_predicted) * C'
+	dP := make4x4()
+	for i := range dP {
+		for j := range dP[i] {
+			dP[i][j] = nextSmoothed.P[i][j] - predicted.P[i][j]
+		}
+	}
+	correction := matMul(matMul(C, dP), transpose(C))
+
+	newP := make4x4()
 	for i := range newP {
-		newP[i] = make([]float64, 4)
 		for j := range newP[i] {
-			newP[i][j] = filtered.P[i][j]
+			newP[i][j] = filtered.P[i][j] + correction[i][j]
 		}
 	}
 
@@ -353,27 +480,28 @@ func (a *AdaptiveRTS) applySmoothing(
 }
 
 // statesToPoints converts smoothed states back to points
-func (a *AdaptiveRTS) statesToPoints(original []model.Point, states []FilterState) []model.Point {
+func (a *AdaptiveRTS) statesToPoints(original []model.Point, states []FilterState, frame LocalFrame) []model.Point {
 	result := make([]model.Point, len(original))
 
 	for i, state := range states {
 		result[i] = original[i]
+		lat, lon := frame.ToLatLon(state.X[0], state.X[1])
 
 		// Store original values if different
-		if math.Abs(original[i].Lat-state.X[0]) > 1e-9 ||
-			math.Abs(original[i].Lon-state.X[1]) > 1e-9 {
+		if math.Abs(original[i].Lat-lat) > 1e-9 ||
+			math.Abs(original[i].Lon-lon) > 1e-9 {
 			result[i].OriginalLat = original[i].Lat
 			result[i].OriginalLon = original[i].Lon
 			// Mark as fixed by this algorithm
-			result[i].FixedBy = "RTS平滑"
+			result[i].FixedBy = FixedByRTS
 			// Update status if it was an anomaly
 			if result[i].Status == model.StatusDrift {
 				result[i].Status = model.StatusNormal
 			}
 		}
 
-		result[i].Lat = state.X[0]
-		result[i].Lon = state.X[1]
+		result[i].Lat = lat
+		result[i].Lon = lon
 
 		// Recalculate speed and bearing
 		if i > 0 {
@@ -388,6 +516,98 @@ func (a *AdaptiveRTS) statesToPoints(original []model.Point, states []FilterStat
 	return result
 }
 
+// transitionMatrix returns the constant velocity transition matrix F
+func transitionMatrix(dt float64) [][]float64 {
+	return [][]float64{
+		{1, 0, dt, 0},
+		{0, 1, 0, dt},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// make4x4 allocates a zero 4x4 matrix
+func make4x4() [][]float64 {
+	m := make([][]float64, 4)
+	for i := range m {
+		m[i] = make([]float64, 4)
+	}
+	return m
+}
+
+// matMul multiplies two square matrices
+func matMul(a, b [][]float64) [][]float64 {
+	n := len(a)
+	result := make([][]float64, n)
+	for i := range result {
+		result[i] = make([]float64, n)
+		for j := 0; j < n; j++ {
+			for k := 0; k < n; k++ {
+				result[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return result
+}
+
+// transpose returns the transpose of a square matrix
+func transpose(m [][]float64) [][]float64 {
+	n := len(m)
+	result := make([][]float64, n)
+	for i := range result {
+		result[i] = make([]float64, n)
+		for j := 0; j < n; j++ {
+			result[i][j] = m[j][i]
+		}
+	}
+	return result
+}
+
+// invertMatrix inverts a square matrix by Gauss-Jordan elimination with
+// partial pivoting; ok is false when the matrix is singular
+func invertMatrix(m [][]float64) ([][]float64, bool) {
+	n := len(m)
+	aug := make([][]float64, n)
+	for i := range aug {
+		aug[i] = make([]float64, 2*n)
+		copy(aug[i], m[i])
+		aug[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(aug[row][col]) > math.Abs(aug[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(aug[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+
+		scale := aug[col][col]
+		for j := range aug[col] {
+			aug[col][j] /= scale
+		}
+		for row := 0; row < n; row++ {
+			if row == col || aug[row][col] == 0 {
+				continue
+			}
+			factor := aug[row][col]
+			for j := range aug[row] {
+				aug[row][j] -= factor * aug[col][j]
+			}
+		}
+	}
+
+	inv := make([][]float64, n)
+	for i := range inv {
+		inv[i] = aug[i][n:]
+	}
+	return inv, true
+}
+
 // correctDriftSegments applies additional correction to drift segments
 // by pulling points toward a reference path between segment endpoints
 func (a *AdaptiveRTS) correctDriftSegments(original, smoothed []model.Point) []model.Point {
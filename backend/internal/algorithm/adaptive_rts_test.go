@@ -1,6 +1,9 @@
 package algorithm
 
 import (
+	"context"
+	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -65,6 +68,85 @@ func TestAdaptiveRTS_SinglePoint(t *testing.T) {
 	}
 }
 
+func TestAdaptiveRTS_PerPointAccuracy(t *testing.T) {
+	rts := NewAdaptiveRTS()
+
+	// Same outlier, reported once as a precise fix and once as a poor one
+	deviation := func(outlierAccuracy float64) float64 {
+		points := createTestPoints(30)
+		for i := range points {
+			points[i].Lat = 39.9042 + float64(i)*0.0001
+			points[i].Lon = 116.4074 + float64(i)*0.0001
+			points[i].Accuracy = 5
+		}
+		expectedLat := points[15].Lat
+		points[15].Lat += 0.0005
+		points[15].Accuracy = outlierAccuracy
+
+		smoothed := rts.Smooth(points)
+		return math.Abs(smoothed[15].Lat - expectedLat)
+	}
+
+	precise, poor := deviation(5), deviation(50)
+	if poor >= precise {
+		t.Errorf("Expected a poor fix to be pulled closer to the track: deviation %g (poor) vs %g (precise)", poor, precise)
+	}
+}
+
+func TestEstimatedAccuracy(t *testing.T) {
+	tests := []struct {
+		point    model.Point
+		expected float64
+	}{
+		{model.Point{Accuracy: 12, HDOP: 1}, 12},
+		{model.Point{HDOP: 2}, 2 * model.UserEquivalentRangeError},
+		{model.Point{Satellites: 4}, 4 * model.UserEquivalentRangeError},
+		{model.Point{Satellites: 3}, 0},
+		{model.Point{}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := model.EstimatedAccuracy(tt.point); got != tt.expected {
+			t.Errorf("EstimatedAccuracy(%+v) = %v, expected %v", tt.point, got, tt.expected)
+		}
+	}
+}
+
+func TestAdaptiveRTS_LatitudeInvariant(t *testing.T) {
+	rts := NewAdaptiveRTS()
+
+	// The same 100 m east-west zigzag at the equator and at 60°N
+	correction := func(lat float64) float64 {
+		metersPerDegreeLon := 111320 * math.Cos(lat*math.Pi/180)
+		points := make([]model.Point, 40)
+		start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+		for i := range points {
+			offset := 0.0
+			if i%2 == 1 {
+				offset = 20
+			}
+			points[i] = model.Point{
+				Index:  i,
+				Lat:    lat,
+				Lon:    10 + (float64(i)*5+offset)/metersPerDegreeLon,
+				Time:   start.Add(time.Duration(i) * time.Second),
+				Status: model.StatusNormal,
+			}
+		}
+
+		smoothed := rts.Smooth(points)
+		return model.HaversineDistance(points[20].Lat, points[20].Lon, smoothed[20].Lat, smoothed[20].Lon)
+	}
+
+	equator, north := correction(0), correction(60)
+	if equator == 0 {
+		t.Fatal("Expected the zigzag to be smoothed")
+	}
+	if math.Abs(equator-north) > 0.01*equator {
+		t.Errorf("Expected the same correction in meters at any latitude, got %.3f m at 0° and %.3f m at 60°", equator, north)
+	}
+}
+
 func createTestPoints(count int) []model.Point {
 	points := make([]model.Point, count)
 	baseTime := time.Now()
@@ -97,3 +179,102 @@ func BenchmarkAdaptiveRTS_Smooth(b *testing.B) {
 		rts.Smooth(points)
 	}
 }
+
+func TestAdaptiveRTS_NoisePrior(t *testing.T) {
+	// Unset, the prior scale follows the measurement noise
+	rts := NewAdaptiveRTSWithParameters(model.RTSParameters{MeasurementNoise: 16, VBAlpha: 4})
+	if beta := rts.Parameters().VBBeta; beta != 64 {
+		t.Errorf("Expected derived vbBeta 64, got %v", beta)
+	}
+	if v := rts.newNoiseEstimate().variance(); v != 16 {
+		t.Errorf("Expected prior variance 16 m², got %v", v)
+	}
+
+	// Set, it moves the prior away from the measurement noise
+	rts = NewAdaptiveRTSWithParameters(model.RTSParameters{MeasurementNoise: 16, VBAlpha: 4, VBBeta: 400})
+	if beta := rts.Parameters().VBBeta; beta != 400 {
+		t.Errorf("Expected vbBeta 400, got %v", beta)
+	}
+	if v := rts.newNoiseEstimate().variance(); v != 100 {
+		t.Errorf("Expected prior variance 100 m², got %v", v)
+	}
+}
+
+func TestAdaptiveRTS_SmoothContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewAdaptiveRTS().SmoothContext(ctx, createTestPoints(1000)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// An uncanceled context smooths as usual
+	points := createTestPoints(100)
+	result, err := NewAdaptiveRTS().SmoothContext(context.Background(), points)
+	if err != nil || len(result) != len(points) {
+		t.Errorf("Expected %d smoothed points, got %d (err %v)", len(points), len(result), err)
+	}
+}
+
+func TestOnlineFilter_MatchesForwardPass(t *testing.T) {
+	rts := NewAdaptiveRTS()
+	points := createTestPoints(30)
+
+	frame := NewLocalFrame(points)
+	measurements := make([][2]float64, len(points))
+	for i, p := range points {
+		e, n := frame.ToENU(p.Lat, p.Lon)
+		measurements[i] = [2]float64{e, n}
+	}
+	states, _, _, err := rts.forwardFilter(context.Background(), points, measurements)
+	if err != nil {
+		t.Fatalf("forwardFilter() error = %v", err)
+	}
+
+	filter := NewOnlineFilter(rts)
+	for i, p := range points {
+		got := filter.Push(p)
+		lat, lon := frame.ToLatLon(states[i].X[0], states[i].X[1])
+		if d := model.HaversineDistance(got.Lat, got.Lon, lat, lon); d > 0.05 {
+			t.Errorf("point %d is %.3f m from the forward pass estimate", i, d)
+		}
+	}
+}
+
+func TestOnlineFilter_RestartsOnNewSegment(t *testing.T) {
+	filter := NewOnlineFilter(NewAdaptiveRTS())
+	points := createTestPoints(10)
+	for _, p := range points {
+		filter.Push(p)
+	}
+
+	// A fix far away after a pause starts over at the measurement
+	next := model.Point{Lat: 40.5, Lon: 117.0, Time: points[9].Time.Add(time.Hour), Segment: 1}
+	got := filter.Push(next)
+	if got.Lat != next.Lat || got.Lon != next.Lon || got.Speed != 0 {
+		t.Errorf("Push() = %+v, want the raw fix at rest", got)
+	}
+}
+
+func TestOnlineFilter_Reanchors(t *testing.T) {
+	filter := NewOnlineFilter(NewAdaptiveRTS())
+	base := time.Unix(1700000000, 0)
+
+	// 30 m/s due east for 20 minutes crosses several frame recentrings
+	var fix, got model.Point
+	for i := 0; i <= 1200; i++ {
+		lon := 116.0 + float64(i)*30/(111320*math.Cos(40*math.Pi/180))
+		fix = model.Point{Lat: 40.0, Lon: lon, Time: base.Add(time.Duration(i) * time.Second)}
+		got = filter.Push(fix)
+	}
+
+	if d := model.HaversineDistance(got.Lat, got.Lon, fix.Lat, fix.Lon); d > 1 {
+		t.Errorf("estimate is %.2f m from a noise-free fix", d)
+	}
+	if math.Abs(got.Speed-108) > 1 {
+		t.Errorf("Speed = %.2f km/h, want about 108", got.Speed)
+	}
+	if math.Abs(got.Bearing-90) > 1 {
+		t.Errorf("Bearing = %.2f, want about 90", got.Bearing)
+	}
+}
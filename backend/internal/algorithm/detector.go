@@ -1,8 +1,10 @@
 package algorithm
 
 import (
+	"context"
 	"math"
 	"sort"
+	"time"
 
 	"github.com/positiondoctor/backend/internal/model"
 )
@@ -14,8 +16,14 @@ type Detector struct {
 	MaxAcceleration float64
 	MaxJump         float64
 	DriftThreshold  float64
+	// Stay points; jitter inside a stop is not reported as an anomaly
+	StopRadius   float64
+	StopDuration time.Duration
 	// Adaptive thresholds
 	UseAdaptive bool
+
+	// ctx cancels the detector loops; set per call by DetectAllContext
+	ctx context.Context
 }
 
 // NewDetector creates a new anomaly detector
@@ -25,45 +33,58 @@ func NewDetector() *Detector {
 		MaxAcceleration: 10.0,  // m/s²
 		MaxJump:         500.0, // meters
 		DriftThreshold:  0.0001, // degrees
+		StopRadius:      50.0,   // meters
+		StopDuration:    2 * time.Minute,
 		UseAdaptive:     true,
 	}
 }
 
 // DetectAll detects all types of anomalies
 func (d *Detector) DetectAll(points []model.Point) []model.Anomaly {
-	var anomalies []model.Anomaly
+	anomalies, _ := d.DetectAllContext(context.Background(), points)
+	return anomalies
+}
 
-	// Speed anomalies
-	if speedAnomalies := d.DetectSpeedAnomalies(points); len(speedAnomalies) > 0 {
-		anomalies = append(anomalies, speedAnomalies...)
-	}
+// DetectAllContext detects all types of anomalies, stopping with the
+// context's error once it is canceled
+func (d *Detector) DetectAllContext(ctx context.Context, points []model.Point) ([]model.Anomaly, error) {
+	dc := *d
+	dc.ctx = ctx
 
-	// Acceleration anomalies
-	if accelAnomalies := d.DetectAccelerationAnomalies(points); len(accelAnomalies) > 0 {
-		anomalies = append(anomalies, accelAnomalies...)
+	detectors := []func([]model.Point) []model.Anomaly{
+		dc.DetectSpeedAnomalies,        // Speed anomalies
+		dc.DetectAccelerationAnomalies, // Acceleration anomalies
+		dc.DetectJumps,                 // Position jumps
+		dc.DetectDrift,                 // GPS drift
+		dc.DetectMissing,               // Missing data
+		dc.DetectDensityAnomalies,      // Density anomalies
 	}
 
-	// Position jumps
-	if jumpAnomalies := d.DetectJumps(points); len(jumpAnomalies) > 0 {
-		anomalies = append(anomalies, jumpAnomalies...)
+	var anomalies []model.Anomaly
+	for _, detect := range detectors {
+		found := detect(points)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, found...)
 	}
 
-	// GPS drift
-	if driftAnomalies := d.DetectDrift(points); len(driftAnomalies) > 0 {
-		anomalies = append(anomalies, driftAnomalies...)
-	}
+	return withoutStopJitter(anomalies, dc.DetectStops(points)), nil
+}
 
-	// Missing data
-	if missingAnomalies := d.DetectMissing(points); len(missingAnomalies) > 0 {
-		anomalies = append(anomalies, missingAnomalies...)
-	}
+// DetectStops finds the stay points of a trajectory: at least StopDuration
+// spent within StopRadius of one place
+func (d *Detector) DetectStops(points []model.Point) []model.Stop {
+	return DetectStayPoints(points, d.StopRadius, d.StopDuration)
+}
 
-	// Density anomalies
-	if densityAnomalies := d.DetectDensityAnomalies(points); len(densityAnomalies) > 0 {
-		anomalies = append(anomalies, densityAnomalies...)
-	}
+// cancelCheckInterval is how many loop iterations run between context checks
+const cancelCheckInterval = 256
 
-	return anomalies
+// canceled reports whether the detection context is done, polling it only
+// every cancelCheckInterval iterations
+func (d *Detector) canceled(i int) bool {
+	return d.ctx != nil && i%cancelCheckInterval == 0 && d.ctx.Err() != nil
 }
 
 // DetectSpeedAnomalies detects abnormal speeds
@@ -76,6 +97,9 @@ func (d *Detector) DetectSpeedAnomalies(points []model.Point) []model.Anomaly {
 	}
 
 	for i, p := range points {
+		if d.canceled(i) {
+			return nil
+		}
 		if p.Speed > threshold {
 			indices = append(indices, i)
 		}
@@ -101,6 +125,12 @@ func (d *Detector) DetectAccelerationAnomalies(points []model.Point) []model.Ano
 	var indices []int
 
 	for i := 2; i < len(points); i++ {
+		if d.canceled(i) {
+			return nil
+		}
+		if !model.SameSegment(points[i-2], points[i]) {
+			continue
+		}
 		accel := model.CalculateAcceleration(points[i-2], points[i-1], points[i])
 		if math.Abs(accel) > d.MaxAcceleration {
 			indices = append(indices, i-1)
@@ -132,6 +162,14 @@ func (d *Detector) DetectJumps(points []model.Point) []model.Anomaly {
 	}
 
 	for i := 1; i < len(points); i++ {
+		if d.canceled(i) {
+			return nil
+		}
+		// A new segment starts after a recording pause, not a jump
+		if !model.SameSegment(points[i-1], points[i]) {
+			continue
+		}
+
 		dist := model.HaversineDistance(
 			points[i-1].Lat, points[i-1].Lon,
 			points[i].Lat, points[i].Lon,
@@ -177,7 +215,13 @@ func (d *Detector) DetectDrift(points []model.Point) []model.Anomaly {
 
 	// Use moving window to detect drift
 	for i := windowSize; i < len(points); i++ {
+		if d.canceled(i) {
+			return nil
+		}
 		window := points[i-windowSize+1 : i+1]
+		if !model.SameSegment(points[i-windowSize/2], points[i]) || !model.SameSegment(window[0], points[i]) {
+			continue
+		}
 
 		// Calculate expected position based on linear regression
 		expectedLat, expectedLon := d.linearRegression(window)
@@ -218,6 +262,18 @@ func (d *Detector) DetectMissing(points []model.Point) []model.Anomaly {
 	maxInterval := avgInterval * 5 // Consider gap if 5x average
 
 	for i := 1; i < len(points); i++ {
+		if d.canceled(i) {
+			return nil
+		}
+		// Pauses between segments are intentional, not missing data
+		if !model.SameSegment(points[i-1], points[i]) {
+			if start != -1 {
+				gaps = append(gaps, []int{start, i - 1})
+				start = -1
+			}
+			continue
+		}
+
 		if !points[i].Time.IsZero() && !points[i-1].Time.IsZero() {
 			interval := points[i].Time.Sub(points[i-1].Time).Seconds()
 			if interval > maxInterval && interval > 10 { // At least 10 seconds
@@ -261,7 +317,13 @@ func (d *Detector) DetectDensityAnomalies(points []model.Point) []model.Anomaly
 	// Calculate point density in sliding windows
 	windowSize := 10
 	for i := 0; i <= len(points)-windowSize; i++ {
+		if d.canceled(i) {
+			return nil
+		}
 		window := points[i : i+windowSize]
+		if !model.SameSegment(window[0], window[windowSize-1]) {
+			continue
+		}
 
 		// Calculate average distance between consecutive points
 		totalDist := 0.0
@@ -310,30 +372,62 @@ func (d *Detector) DetectDensityAnomalies(points []model.Point) []model.Anomaly
 // Helper functions
 
 func (d *Detector) calculateAdaptiveSpeedThreshold(points []model.Point) float64 {
-	speeds := make([]float64, 0)
+	// Filter invalid values; fast modes raise the plausible limit
+	speeds := speedDistribution(points, 0, math.Max(300, d.MaxSpeed*2.5))
+
+	if len(speeds) == 0 {
+		return d.MaxSpeed
+	}
+
+	// Use 95th percentile as threshold
+	p95 := percentile(speeds, 0.95)
+	return math.Min(p95*1.5, d.MaxSpeed)
+}
+
+// speedDistribution returns the sorted point speeds above min and below
+// max, in km/h
+func speedDistribution(points []model.Point, min, max float64) []float64 {
+	speeds := make([]float64, 0, len(points))
 	for _, p := range points {
-		if p.Speed > 0 && p.Speed < 300 { // Filter invalid values
+		if p.Speed > min && p.Speed < max {
 			speeds = append(speeds, p.Speed)
 		}
 	}
+	sort.Float64s(speeds)
+	return speeds
+}
 
-	if len(speeds) == 0 {
-		return d.MaxSpeed
+// accelerationDistribution returns the sorted absolute accelerations within
+// track segments, in m/s²
+func accelerationDistribution(points []model.Point) []float64 {
+	accels := make([]float64, 0, len(points))
+	for i := 2; i < len(points); i++ {
+		if !model.SameSegment(points[i-2], points[i]) {
+			continue
+		}
+		accels = append(accels, math.Abs(model.CalculateAcceleration(points[i-2], points[i-1], points[i])))
 	}
+	sort.Float64s(accels)
+	return accels
+}
 
-	sort.Float64s(speeds)
-	// Use 95th percentile as threshold, protect against index out of bounds
-	idx := int(float64(len(speeds)) * 0.95)
-	if idx >= len(speeds) {
-		idx = len(speeds) - 1
+// percentile returns the q-quantile (0 to 1) of sorted values, which must
+// not be empty
+func percentile(sorted []float64, q float64) float64 {
+	// Protect against index out of bounds
+	idx := int(float64(len(sorted)) * q)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
 	}
-	p95 := speeds[idx]
-	return math.Min(p95*1.5, d.MaxSpeed)
+	return sorted[idx]
 }
 
 func (d *Detector) calculateAdaptiveJumpThreshold(points []model.Point) float64 {
 	distances := make([]float64, 0)
 	for i := 1; i < len(points); i++ {
+		if !model.SameSegment(points[i-1], points[i]) {
+			continue
+		}
 		dist := model.HaversineDistance(
 			points[i-1].Lat, points[i-1].Lon,
 			points[i].Lat, points[i].Lon,
@@ -362,6 +456,9 @@ func (d *Detector) calculateAverageInterval(points []model.Point) float64 {
 	count := 0
 
 	for i := 1; i < len(points); i++ {
+		if !model.SameSegment(points[i-1], points[i]) {
+			continue
+		}
 		if !points[i].Time.IsZero() && !points[i-1].Time.IsZero() {
 			interval := points[i].Time.Sub(points[i-1].Time).Seconds()
 			if interval > 0 && interval < 1000 {
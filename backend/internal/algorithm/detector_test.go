@@ -1,6 +1,9 @@
 package algorithm
 
 import (
+	"context"
+	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -69,6 +72,63 @@ func TestDetector_AdaptiveThreshold(t *testing.T) {
 	// Should not panic
 }
 
+func TestDetector_SegmentBoundaries(t *testing.T) {
+	detector := NewDetector()
+
+	// Two segments an hour and several kilometres apart
+	points := createTestTrajectory()
+	for i := 50; i < len(points); i++ {
+		points[i].Speed = 30.0
+		points[i].Segment = 1
+		points[i].Lat += 0.05
+		points[i].Time = points[i].Time.Add(time.Hour)
+	}
+
+	for _, a := range detector.DetectJumps(points) {
+		t.Errorf("Expected no jump across segment boundary, got indices %v", a.Indices)
+	}
+	for _, a := range detector.DetectMissing(points) {
+		t.Errorf("Expected no missing gap across segment boundary, got indices %v", a.Indices)
+	}
+}
+
+func TestSplineInterpolator_SegmentBoundaries(t *testing.T) {
+	points := createTestTrajectory()
+
+	// A 30 second gap inside the first segment
+	for i := 20; i < len(points); i++ {
+		points[i].Time = points[i].Time.Add(30 * time.Second)
+	}
+	// A one hour pause before the second segment
+	for i := 50; i < len(points); i++ {
+		points[i].Segment = 1
+		points[i].Time = points[i].Time.Add(time.Hour)
+	}
+
+	result := NewSplineInterpolator().Interpolate(points)
+
+	interpolated := 0
+	for i, p := range result {
+		if p.IsInterpolated {
+			interpolated++
+			if p.Segment != 0 {
+				t.Errorf("Point %d: interpolated into segment %d", i, p.Segment)
+			}
+		}
+	}
+	if interpolated == 0 {
+		t.Error("Expected the gap within the first segment to be filled")
+	}
+
+	// Every original point is kept
+	if len(result)-interpolated != len(points) {
+		t.Errorf("Expected original points to be kept, got %d of %d", len(result)-interpolated, len(points))
+	}
+	if last := result[len(result)-1]; last.Index != points[len(points)-1].Index {
+		t.Errorf("Expected last point %d, got %d", points[len(points)-1].Index, last.Index)
+	}
+}
+
 func createTestTrajectory() []model.Point {
 	baseTime := time.Now()
 	points := make([]model.Point, 100)
@@ -91,3 +151,62 @@ func createTestTrajectory() []model.Point {
 
 	return points
 }
+
+func TestDetector_DetectAllContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	anomalies, err := NewDetector().DetectAllContext(ctx, createTestPoints(1000))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if anomalies != nil {
+		t.Errorf("Expected no anomalies after cancellation, got %d", len(anomalies))
+	}
+}
+
+func TestWindowDetector_Push(t *testing.T) {
+	w := NewWindowDetector(NewDetector())
+	base := time.Unix(1700000000, 0)
+
+	// About 11 m/s north, then a 2 km jump at index 30
+	var found []model.LiveAnomaly
+	for i := 0; i < 32; i++ {
+		lat := 39.9 + float64(i)*0.0001
+		if i >= 30 {
+			lat += 0.02
+		}
+		found = append(found, w.Push(model.Point{Index: i, Lat: lat, Lon: 116.4, Time: base.Add(time.Duration(i) * time.Second)})...)
+	}
+
+	types := make(map[model.AnomalyType][]int)
+	for _, a := range found {
+		types[a.Type] = append(types[a.Type], a.Index)
+		if math.Abs(a.Value) <= a.Threshold {
+			t.Errorf("%s at %d: value %.2f does not exceed threshold %.2f", a.Type, a.Index, a.Value, a.Threshold)
+		}
+	}
+	if got := types[model.AnomalyJump]; len(got) != 1 || got[0] != 30 {
+		t.Errorf("jumps at %v, want [30]", got)
+	}
+	if got := types[model.AnomalySpeedAnomaly]; len(got) != 1 || got[0] != 30 {
+		t.Errorf("speed anomalies at %v, want [30]", got)
+	}
+	// Acceleration into and out of the jump is reported one point late
+	if got := types[model.AnomalyAccelAnomaly]; len(got) != 2 || got[0] != 29 || got[1] != 30 {
+		t.Errorf("acceleration anomalies at %v, want [29 30]", got)
+	}
+}
+
+func TestWindowDetector_SegmentBoundaries(t *testing.T) {
+	w := NewWindowDetectorWithSize(NewDetector(), 10)
+	base := time.Unix(1700000000, 0)
+
+	w.Push(model.Point{Index: 0, Lat: 39.9, Lon: 116.4, Time: base})
+	w.Push(model.Point{Index: 1, Lat: 39.9001, Lon: 116.4, Time: base.Add(time.Second)})
+	// The next segment starts elsewhere after a pause
+	found := w.Push(model.Point{Index: 2, Lat: 40.5, Lon: 116.4, Time: base.Add(2 * time.Second), Segment: 1})
+	if len(found) != 0 {
+		t.Errorf("Push() across segments = %+v, want no anomalies", found)
+	}
+}
@@ -0,0 +1,156 @@
+package algorithm
+
+import (
+	"math"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// DefaultDetectionWindow is how many recent points a WindowDetector keeps
+const DefaultDetectionWindow = 120
+
+// minAdaptiveWindow is how many points the window needs before the adaptive
+// thresholds replace the fixed ones
+const minAdaptiveWindow = 20
+
+// WindowDetector runs the speed, jump and acceleration checks of a Detector
+// on live points as they arrive. Adaptive thresholds are computed over a
+// rolling window of recent points instead of the whole track.
+type WindowDetector struct {
+	detector *Detector
+	size     int
+	window   []model.Point
+}
+
+// NewWindowDetector creates a window detector using d's thresholds over the
+// last DefaultDetectionWindow points
+func NewWindowDetector(d *Detector) *WindowDetector {
+	return NewWindowDetectorWithSize(d, DefaultDetectionWindow)
+}
+
+// NewWindowDetectorWithSize creates a window detector keeping the last size
+// points, at least 3
+func NewWindowDetectorWithSize(d *Detector, size int) *WindowDetector {
+	if size < 3 {
+		size = 3
+	}
+	return &WindowDetector{detector: d, size: size}
+}
+
+// Push adds the next point and returns the anomalies it reveals, identified
+// by the points' Index. Speed anomalies and jumps are reported for p itself;
+// abnormal acceleration for the point before p, which takes p to measure.
+func (w *WindowDetector) Push(p model.Point) []model.LiveAnomaly {
+	p.Speed = 0
+	if n := len(w.window); n > 0 && model.SameSegment(w.window[n-1], p) {
+		p.Speed = model.CalculateSpeed(w.window[n-1], p)
+	}
+
+	if len(w.window) == w.size {
+		copy(w.window, w.window[1:])
+		w.window = w.window[:w.size-1]
+	}
+	w.window = append(w.window, p)
+
+	var anomalies []model.LiveAnomaly
+	if a, ok := w.checkSpeed(); ok {
+		anomalies = append(anomalies, a)
+	}
+	if a, ok := w.checkJump(); ok {
+		anomalies = append(anomalies, a)
+	}
+	if a, ok := w.checkAcceleration(); ok {
+		anomalies = append(anomalies, a)
+	}
+	return anomalies
+}
+
+// adaptive reports whether the window is long enough for adaptive thresholds
+func (w *WindowDetector) adaptive() bool {
+	return w.detector.UseAdaptive && len(w.window) >= minAdaptiveWindow
+}
+
+// checkSpeed flags the newest point when it moved faster than the threshold
+func (w *WindowDetector) checkSpeed() (model.LiveAnomaly, bool) {
+	d := w.detector
+	last := len(w.window) - 1
+	p := w.window[last]
+
+	threshold := d.MaxSpeed
+	if w.adaptive() {
+		threshold = d.calculateAdaptiveSpeedThreshold(w.window)
+	}
+	if p.Speed <= threshold {
+		return model.LiveAnomaly{}, false
+	}
+
+	return model.LiveAnomaly{
+		Index:     p.Index,
+		Type:      model.AnomalySpeedAnomaly,
+		Severity:  d.calculateSpeedSeverity([]int{last}, w.window, threshold),
+		Value:     p.Speed,
+		Threshold: threshold,
+	}, true
+}
+
+// checkJump flags the newest point when it is further from the previous one
+// than both the jump threshold and the maximum speed allow
+func (w *WindowDetector) checkJump() (model.LiveAnomaly, bool) {
+	d := w.detector
+	n := len(w.window)
+	if n < 2 {
+		return model.LiveAnomaly{}, false
+	}
+	prev, p := w.window[n-2], w.window[n-1]
+	if !model.SameSegment(prev, p) || p.Time.IsZero() || prev.Time.IsZero() {
+		return model.LiveAnomaly{}, false
+	}
+
+	threshold := d.MaxJump
+	if w.adaptive() {
+		threshold = d.calculateAdaptiveJumpThreshold(w.window)
+	}
+
+	dist := model.HaversineDistance(prev.Lat, prev.Lon, p.Lat, p.Lon)
+	if dist <= threshold {
+		return model.LiveAnomaly{}, false
+	}
+	if duration := p.Time.Sub(prev.Time).Seconds(); duration > 0 && dist <= (d.MaxSpeed/3.6)*duration {
+		return model.LiveAnomaly{}, false
+	}
+
+	severity := model.SeverityMedium
+	if dist > d.MaxJump*2 {
+		severity = model.SeverityHigh
+	}
+	return model.LiveAnomaly{
+		Index:     p.Index,
+		Type:      model.AnomalyJump,
+		Severity:  severity,
+		Value:     dist,
+		Threshold: threshold,
+	}, true
+}
+
+// checkAcceleration flags the point before the newest when the speed change
+// around it exceeds the maximum acceleration
+func (w *WindowDetector) checkAcceleration() (model.LiveAnomaly, bool) {
+	d := w.detector
+	n := len(w.window)
+	if n < 3 || !model.SameSegment(w.window[n-3], w.window[n-1]) {
+		return model.LiveAnomaly{}, false
+	}
+
+	accel := model.CalculateAcceleration(w.window[n-3], w.window[n-2], w.window[n-1])
+	if math.Abs(accel) <= d.MaxAcceleration {
+		return model.LiveAnomaly{}, false
+	}
+
+	return model.LiveAnomaly{
+		Index:     w.window[n-2].Index,
+		Type:      model.AnomalyAccelAnomaly,
+		Severity:  d.calculateAccelSeverity([]int{n - 1}, w.window),
+		Value:     accel,
+		Threshold: d.MaxAcceleration,
+	}, true
+}
@@ -26,12 +26,21 @@ func NewImprovedDouglasPeucker(epsilon float64) *ImprovedDouglasPeucker {
 	}
 }
 
-// Simplify simplifies the trajectory using Douglas-Peucker algorithm
+// Simplify simplifies the trajectory using Douglas-Peucker algorithm.
+// Each segment is simplified on its own so segment endpoints are kept.
 func (idp *ImprovedDouglasPeucker) Simplify(points []model.Point) []model.Point {
 	if len(points) <= idp.MinPoints {
 		return points
 	}
 
+	if segments := model.SplitSegments(points); len(segments) > 1 {
+		result := make([]model.Point, 0, len(points))
+		for _, segment := range segments {
+			result = append(result, idp.Simplify(segment)...)
+		}
+		return result
+	}
+
 	// Build list of indices to keep
 	keep := make([]bool, len(points))
 	keep[0] = true
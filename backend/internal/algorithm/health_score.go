@@ -196,6 +196,9 @@ func (h *HealthScorer) calculateSmoothness(points []model.Point) model.ScoreDeta
 	// Calculate angular changes (bearing differences)
 	angleChanges := make([]float64, 0)
 	for i := 2; i < len(points); i++ {
+		if !model.SameSegment(points[i-2], points[i]) {
+			continue
+		}
 		bearing1 := model.CalculateBearing(
 			points[i-2].Lat, points[i-2].Lon,
 			points[i-1].Lat, points[i-1].Lon,
@@ -0,0 +1,57 @@
+package algorithm
+
+import (
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// LabelPoints projects detected anomalies onto the points they name and
+// returns the labeled copy. Every point gets the statuses of the anomalies
+// naming it in Flags, strongest first, and the strongest as its Status.
+// Labels from an earlier diagnosis are cleared; interpolated and missing
+// points keep their status unless an anomaly names them.
+func LabelPoints(points []model.Point, anomalies []model.Anomaly) []model.Point {
+	result := make([]model.Point, len(points))
+	copy(result, points)
+	for i := range result {
+		result[i].Flags = nil
+		if result[i].Status == "" || result[i].Status.IsAnomaly() {
+			result[i].Status = model.StatusNormal
+		}
+	}
+
+	for _, a := range anomalies {
+		status, ok := model.StatusForAnomaly(a.Type)
+		if !ok {
+			continue
+		}
+		for _, i := range a.Indices {
+			if i >= 0 && i < len(result) {
+				result[i].Flags = addFlag(result[i].Flags, status)
+			}
+		}
+	}
+
+	for i := range result {
+		if len(result[i].Flags) > 0 {
+			result[i].Status = result[i].Flags[0]
+		}
+	}
+
+	return result
+}
+
+// addFlag inserts status into flags in precedence order, unless present
+func addFlag(flags []model.PointStatus, status model.PointStatus) []model.PointStatus {
+	for i, f := range flags {
+		if f == status {
+			return flags
+		}
+		if status.Outranks(f) {
+			flags = append(flags, "")
+			copy(flags[i+1:], flags[i:])
+			flags[i] = status
+			return flags
+		}
+	}
+	return append(flags, status)
+}
@@ -0,0 +1,63 @@
+package algorithm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func TestLabelPoints(t *testing.T) {
+	points := make([]model.Point, 6)
+	for i := range points {
+		points[i] = model.Point{Index: i, Status: model.StatusNormal}
+	}
+	points[4].Status = model.StatusJump // Left over from an earlier diagnosis
+	points[4].Flags = []model.PointStatus{model.StatusJump}
+	points[5].Status = model.StatusInterpolated
+
+	anomalies := []model.Anomaly{
+		{Type: model.AnomalyDrift, Indices: []int{1, 2}},
+		{Type: model.AnomalySpeedAnomaly, Indices: []int{2, 3}},
+		{Type: model.AnomalyJump, Indices: []int{2, 9}},
+		{Type: model.AnomalyAccelAnomaly, Indices: []int{3}},
+		{Type: model.AnomalyMissing, Gaps: [][]int{{0, 5}}},
+		{Type: model.AnomalyDensity, Indices: []int{0}},
+	}
+
+	labeled := LabelPoints(points, anomalies)
+
+	want := []struct {
+		status model.PointStatus
+		flags  []model.PointStatus
+	}{
+		{model.StatusNormal, nil},
+		{model.StatusDrift, []model.PointStatus{model.StatusDrift}},
+		{model.StatusJump, []model.PointStatus{model.StatusJump, model.StatusSpeedAnomaly, model.StatusDrift}},
+		{model.StatusSpeedAnomaly, []model.PointStatus{model.StatusSpeedAnomaly, model.StatusAccelAnomaly}},
+		{model.StatusNormal, nil},
+		{model.StatusInterpolated, nil},
+	}
+	for i, w := range want {
+		if labeled[i].Status != w.status || !reflect.DeepEqual(labeled[i].Flags, w.flags) {
+			t.Errorf("Point %d: status %s, flags %v; want %s, %v", i, labeled[i].Status, labeled[i].Flags, w.status, w.flags)
+		}
+	}
+
+	if points[2].Status != model.StatusNormal || points[4].Status != model.StatusJump {
+		t.Error("Expected the input points to be left unchanged")
+	}
+}
+
+func TestPointStatus_Outranks(t *testing.T) {
+	order := []model.PointStatus{model.StatusJump, model.StatusSpeedAnomaly, model.StatusAccelAnomaly,
+		model.StatusDrift, model.StatusNormal}
+	for i := 0; i < len(order)-1; i++ {
+		if !order[i].Outranks(order[i+1]) || order[i+1].Outranks(order[i]) {
+			t.Errorf("Expected %s to outrank %s", order[i], order[i+1])
+		}
+	}
+	if model.StatusMissing.IsAnomaly() || !model.StatusDrift.IsAnomaly() {
+		t.Error("Expected only detector statuses to be anomalies")
+	}
+}
@@ -0,0 +1,71 @@
+package algorithm
+
+import (
+	"math"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// earthRadius is the mean Earth radius in meters, matching model.HaversineDistance
+const earthRadius = 6371000.0
+
+// LocalFrame is a local east/north tangent plane centred on a track. It uses
+// an equirectangular projection about the centre, which stays well below GPS
+// noise for tracks spanning tens of kilometres.
+type LocalFrame struct {
+	lat0, lon0 float64 // Origin, degrees
+	cosLat0    float64
+}
+
+// NewLocalFrame creates a frame centred on the mean position of points
+func NewLocalFrame(points []model.Point) LocalFrame {
+	if len(points) == 0 {
+		return LocalFrame{cosLat0: 1}
+	}
+
+	// Average longitudes as unit vectors so tracks crossing ±180° stay centred
+	var latSum, sinSum, cosSum float64
+	for _, p := range points {
+		latSum += p.Lat
+		sinSum += math.Sin(p.Lon * math.Pi / 180)
+		cosSum += math.Cos(p.Lon * math.Pi / 180)
+	}
+
+	lat0 := latSum / float64(len(points))
+	return LocalFrame{
+		lat0:    lat0,
+		lon0:    math.Atan2(sinSum, cosSum) * 180 / math.Pi,
+		cosLat0: math.Cos(lat0 * math.Pi / 180),
+	}
+}
+
+// ToENU projects a position to east/north meters from the frame origin
+func (f LocalFrame) ToENU(lat, lon float64) (east, north float64) {
+	dLon := lon - f.lon0
+	// Take the short way around the antimeridian
+	if dLon > 180 {
+		dLon -= 360
+	} else if dLon < -180 {
+		dLon += 360
+	}
+
+	east = dLon * math.Pi / 180 * earthRadius * f.cosLat0
+	north = (lat - f.lat0) * math.Pi / 180 * earthRadius
+	return east, north
+}
+
+// ToLatLon converts east/north meters back to a position in degrees
+func (f LocalFrame) ToLatLon(east, north float64) (lat, lon float64) {
+	lat = f.lat0 + north/earthRadius*180/math.Pi
+
+	lon = f.lon0
+	if f.cosLat0 > 1e-12 {
+		lon += east / (earthRadius * f.cosLat0) * 180 / math.Pi
+	}
+	if lon > 180 {
+		lon -= 360
+	} else if lon < -180 {
+		lon += 360
+	}
+	return lat, lon
+}
@@ -0,0 +1,88 @@
+package algorithm
+
+import (
+	"math"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// reanchorDistance is how far in meters the filter may move from its frame
+// origin before the frame is recentred on the current estimate
+const reanchorDistance = 10000.0
+
+// OnlineFilter runs the forward Kalman pass of AdaptiveRTS one fix at a
+// time, for live tracks that cannot wait for the backward smoothing pass.
+// Each estimate only depends on the fixes pushed so far.
+type OnlineFilter struct {
+	rts     *AdaptiveRTS
+	frame   LocalFrame
+	state   FilterState
+	noise   noiseEstimate
+	last    model.Point
+	started bool
+}
+
+// NewOnlineFilter creates an online filter running with the parameters of rts
+func NewOnlineFilter(rts *AdaptiveRTS) *OnlineFilter {
+	return &OnlineFilter{rts: rts}
+}
+
+// Reset forgets the filter state; the next fix starts a new track
+func (f *OnlineFilter) Reset() {
+	f.started = false
+}
+
+// Push filters the next fix and returns it at the estimated position, with
+// speed and bearing from the estimated velocity. A fix in a new segment
+// restarts the filter.
+func (f *OnlineFilter) Push(p model.Point) model.Point {
+	if !f.started || !model.SameSegment(f.last, p) {
+		f.frame = NewLocalFrame([]model.Point{p})
+		f.state = f.rts.initState(p, [2]float64{0, 0})
+		f.noise = f.rts.newNoiseEstimate()
+		f.last = p
+		f.started = true
+		return p
+	}
+
+	dt := 1.0 // Default to 1 second, as the batch forward pass does
+	if !p.Time.IsZero() && !f.last.Time.IsZero() {
+		if d := p.Time.Sub(f.last.Time).Seconds(); d > 0 {
+			dt = d
+		}
+	}
+
+	e, n := f.frame.ToENU(p.Lat, p.Lon)
+	z := [2]float64{e, n}
+	R := f.rts.measurementVariance(p, f.noise.variance())
+
+	f.state = f.rts.update(f.rts.predict(f.state, dt), z, R, dt)
+	f.noise.observe(z, f.state)
+	f.last = p
+
+	result := p
+	result.Lat, result.Lon = f.frame.ToLatLon(f.state.X[0], f.state.X[1])
+	if math.Abs(p.Lat-result.Lat) > 1e-9 || math.Abs(p.Lon-result.Lon) > 1e-9 {
+		result.OriginalLat = p.Lat
+		result.OriginalLon = p.Lon
+		result.FixedBy = FixedByRTS
+	}
+
+	vE, vN := f.state.X[2], f.state.X[3]
+	result.Speed = math.Hypot(vE, vN) * 3.6 // km/h
+	result.Bearing = math.Mod(math.Atan2(vE, vN)*180/math.Pi+360, 360)
+
+	if math.Hypot(f.state.X[0], f.state.X[1]) > reanchorDistance {
+		f.reanchor(result.Lat, result.Lon)
+	}
+
+	return result
+}
+
+// reanchor recentres the frame on a position so the equirectangular
+// projection stays accurate on long tracks. The velocity is kept as is; the
+// frame rotation over reanchorDistance is negligible.
+func (f *OnlineFilter) reanchor(lat, lon float64) {
+	f.frame = NewLocalFrame([]model.Point{{Lat: lat, Lon: lon}})
+	f.state.X[0], f.state.X[1] = 0, 0
+}
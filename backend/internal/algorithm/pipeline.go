@@ -0,0 +1,121 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Pipeline runs correction steps in order
+type Pipeline struct {
+	stages []stage
+}
+
+// stage is a step with its resolved parameters
+type stage struct {
+	step   Step
+	params Params
+}
+
+// StepReport describes one executed step
+type StepReport struct {
+	Step         Step
+	Params       Params
+	Input        int // Points the step received
+	Stats        StepStats
+	FixedIndices []int // Indices in the final points of points this step corrected
+}
+
+// NewPipeline resolves step configs against the registry, validating names
+// and parameters
+func (r *Registry) NewPipeline(configs []model.StepConfig) (*Pipeline, *model.ErrorDetails) {
+	p := &Pipeline{stages: make([]stage, 0, len(configs))}
+
+	for i, cfg := range configs {
+		field := fmt.Sprintf("steps[%d]", i)
+		step, ok := r.Lookup(cfg.Name)
+		if !ok {
+			return nil, &model.ErrorDetails{
+				Field:   field + ".name",
+				Message: fmt.Sprintf("unknown step %q", cfg.Name),
+			}
+		}
+
+		specs := step.Parameters()
+		if details := model.ValidateParameters(field+".parameters", specs, cfg.Parameters); details != nil {
+			return nil, details
+		}
+
+		params := Params(model.ResolveParameters(specs, cfg.Parameters))
+		if checker, ok := step.(ParameterChecker); ok {
+			if err := checker.CheckParameters(params); err != nil {
+				return nil, &model.ErrorDetails{
+					Field:   field + ".parameters",
+					Message: err.Error(),
+				}
+			}
+		}
+
+		p.stages = append(p.stages, stage{step: step, params: params})
+	}
+
+	return p, nil
+}
+
+// Len returns the number of steps
+func (p *Pipeline) Len() int {
+	return len(p.stages)
+}
+
+// Run applies every step to a copy of points, stopping with ctx's error once
+// it is canceled
+func (p *Pipeline) Run(ctx context.Context, points []model.Point, input StepInput) ([]model.Point, []StepReport, error) {
+	return p.RunWithProgress(ctx, points, input, nil)
+}
+
+// RunWithProgress is Run with a callback invoked after each step with its
+// position and report; after may be nil. FixedIndices are not yet set then.
+func (p *Pipeline) RunWithProgress(ctx context.Context, points []model.Point, input StepInput, after func(i int, report StepReport)) ([]model.Point, []StepReport, error) {
+	result := make([]model.Point, len(points))
+	copy(result, points)
+
+	reports := make([]StepReport, 0, len(p.stages))
+	for i, s := range p.stages {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		count := len(result)
+		out, stats, err := s.step.Apply(ctx, result, s.params, input)
+		if err != nil {
+			return nil, nil, fmt.Errorf("step %s: %w", s.step.Name(), err)
+		}
+		result = out
+		reports = append(reports, StepReport{
+			Step:   s.step,
+			Params: s.params,
+			Input:  count,
+			Stats:  stats,
+		})
+		if after != nil {
+			after(i, reports[i])
+		}
+	}
+
+	// Attribute corrected points once all steps have run
+	for i := range reports {
+		label := reports[i].Stats.FixedBy
+		if label == "" {
+			continue
+		}
+		reports[i].FixedIndices = make([]int, 0)
+		for j, pt := range result {
+			if pt.FixedBy == label {
+				reports[i].FixedIndices = append(reports[i].FixedIndices, j)
+			}
+		}
+	}
+
+	return result, reports, nil
+}
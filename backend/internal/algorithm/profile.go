@@ -0,0 +1,73 @@
+package algorithm
+
+import (
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Transport mode inference. Modes are told apart by the 85th percentile of
+// moving speeds, which ignores stops and the odd spike, and a car on a fast
+// road is told from a train by how hard it brakes.
+const (
+	minMovingSpeed    = 1.0    // km/h; slower points are standing still
+	maxPlausibleSpeed = 1200.0 // km/h; faster speeds are errors
+	minMovingPoints   = 10     // Fewer moving points leave the default profile
+
+	walkMaxSpeed  = 8.0   // km/h, 85th percentile
+	bikeMaxSpeed  = 30.0  // km/h
+	carMaxSpeed   = 140.0 // km/h
+	trainMaxSpeed = 450.0 // km/h; faster tracks are flights
+
+	fastRoadSpeed    = 100.0 // km/h; cars this fast may be trains
+	trainMaxBraking  = 1.2   // m/s², 99th percentile of |acceleration|
+	minAccelerations = 10    // Fewer samples cannot tell cars from trains
+	speedQuantile    = 0.85
+	brakingQuantile  = 0.99
+)
+
+// InferProfile guesses a track's transport mode from its speed and
+// acceleration distributions. Boats are never inferred as their speeds
+// overlap with bikes and cars. Tracks with too few moving points get
+// DefaultProfile.
+func InferProfile(points []model.Point) string {
+	speeds := speedDistribution(points, minMovingSpeed, maxPlausibleSpeed)
+	if len(speeds) < minMovingPoints {
+		return model.DefaultProfile
+	}
+	return classifyMode(points, percentile(speeds, speedQuantile))
+}
+
+// classifyMode picks the transport mode of points from their typical moving
+// speed v, in km/h
+func classifyMode(points []model.Point, v float64) string {
+	switch {
+	case v < walkMaxSpeed:
+		return model.ProfileWalk
+	case v < bikeMaxSpeed:
+		return model.ProfileBike
+	case v < fastRoadSpeed:
+		return model.ProfileCar
+	case v < carMaxSpeed:
+		accels := accelerationDistribution(points)
+		if len(accels) >= minAccelerations && percentile(accels, brakingQuantile) < trainMaxBraking {
+			return model.ProfileTrain
+		}
+		return model.ProfileCar
+	case v < trainMaxSpeed:
+		return model.ProfileTrain
+	}
+	return model.ProfileFlight
+}
+
+// ResolveProfile returns the profile a request names, inferring it from
+// points for ProfileAuto; inferred reports whether it did. Unknown names
+// select DefaultProfile, so validate them with model.ValidateProfile first.
+func ResolveProfile(name string, points []model.Point) (profile model.Profile, inferred bool) {
+	if name == model.ProfileAuto {
+		name, inferred = InferProfile(points), true
+	}
+	profile, ok := model.LookupProfile(name)
+	if !ok {
+		profile, _ = model.LookupProfile(model.DefaultProfile)
+	}
+	return profile, inferred
+}
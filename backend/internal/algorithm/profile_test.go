@@ -0,0 +1,95 @@
+package algorithm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// createModeTrack creates a track heading east at the given speeds in km/h,
+// one fix per interval, with the speeds set as PostProcess would
+func createModeTrack(interval time.Duration, speeds ...float64) []model.Point {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]model.Point, len(speeds))
+	lon := 116.4074
+	for i, v := range speeds {
+		if i > 0 {
+			meters := v / 3.6 * interval.Seconds()
+			lon += meters / (111320 * 0.7666) // cos(39.9°)
+		}
+		points[i] = model.Point{
+			Index:  i,
+			Lat:    39.9042,
+			Lon:    lon,
+			Time:   start.Add(time.Duration(i) * interval),
+			Speed:  v,
+			Status: model.StatusNormal,
+		}
+	}
+	return points
+}
+
+// constantSpeeds returns n copies of v
+func constantSpeeds(n int, v float64) []float64 {
+	speeds := make([]float64, n)
+	for i := range speeds {
+		speeds[i] = v
+	}
+	return speeds
+}
+
+func TestInferProfile(t *testing.T) {
+	// A car on a fast road brakes hard now and then; a train does not
+	braking := constantSpeeds(60, 120)
+	for i := 10; i < 60; i += 20 {
+		braking[i] = 100
+	}
+
+	tests := []struct {
+		name   string
+		points []model.Point
+		want   string
+	}{
+		{"walk", createModeTrack(time.Second, constantSpeeds(60, 5)...), model.ProfileWalk},
+		{"bike", createModeTrack(time.Second, constantSpeeds(60, 20)...), model.ProfileBike},
+		{"car", createModeTrack(time.Second, constantSpeeds(60, 60)...), model.ProfileCar},
+		{"car on a fast road", createModeTrack(time.Second, braking...), model.ProfileCar},
+		{"regional train", createModeTrack(time.Second, constantSpeeds(60, 120)...), model.ProfileTrain},
+		{"high-speed rail", createModeTrack(time.Second, constantSpeeds(60, 300)...), model.ProfileTrain},
+		{"flight", createModeTrack(10*time.Second, constantSpeeds(60, 850)...), model.ProfileFlight},
+		{"standing still", createModeTrack(time.Second, constantSpeeds(60, 0)...), model.DefaultProfile},
+		{"too short", createModeTrack(time.Second, constantSpeeds(5, 5)...), model.DefaultProfile},
+	}
+
+	for _, tt := range tests {
+		if got := InferProfile(tt.points); got != tt.want {
+			t.Errorf("%s: InferProfile() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	walk := createModeTrack(time.Second, constantSpeeds(60, 5)...)
+
+	if p, inferred := ResolveProfile(model.ProfileAuto, walk); p.Name != model.ProfileWalk || !inferred {
+		t.Errorf("auto: got %s (inferred %v), want an inferred walk", p.Name, inferred)
+	}
+	if p, inferred := ResolveProfile(model.ProfileFlight, walk); p.Name != model.ProfileFlight || inferred {
+		t.Errorf("flight: got %s (inferred %v), want flight as named", p.Name, inferred)
+	}
+	if p, _ := ResolveProfile("", walk); p.Name != model.DefaultProfile {
+		t.Errorf("empty: got %s, want %s", p.Name, model.DefaultProfile)
+	}
+
+	// The default profile's presets match the defaults
+	car, _ := model.LookupProfile(model.DefaultProfile)
+	if got := model.DefaultRequest().WithProfile(car).Thresholds; got != car.Thresholds {
+		t.Errorf("Default request thresholds = %+v, want %+v", got, car.Thresholds)
+	}
+	defaults := model.DefaultParameters()
+	if car.Parameters.AdaptiveRTS.ProcessNoise != defaults.AdaptiveRTS.ProcessNoise ||
+		car.Parameters.SplineInterpolation.MaxGapSeconds != defaults.SplineInterpolation.MaxGapSeconds {
+		t.Errorf("Default profile parameters = %+v, want the defaults", car.Parameters)
+	}
+}
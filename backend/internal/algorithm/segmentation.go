@@ -0,0 +1,258 @@
+package algorithm
+
+import (
+	"context"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Mode segmentation. Every moving point is classified like a whole track in
+// InferProfile, from the median speed and the braking in a window around it;
+// the median moves the boundary between two legs to where one mode takes
+// over. Stops join the leg they end, and legs too short to be a trip of
+// their own merge into a neighbour.
+const (
+	modeWindow      = 60 * time.Second // Half-width of the window classifying a point
+	minModeWindow   = 5                // Points on each side at least
+	maxModeWindow   = 60               // Points on each side at most
+	minWindowMoving = 3                // Fewer moving points in a window mean a stop
+	minModeDuration = 90 * time.Second // Shorter legs merge into a neighbour
+)
+
+// modeLeg is a run of points classified alike
+type modeLeg struct {
+	mode       string
+	start, end int // Inclusive
+}
+
+// SegmentModes splits a trajectory into legs of one transport mode each, such
+// as the walk to a bus stop, the bus ride and the walk on to the metro.
+// Points must carry the speeds computed by parser.PostProcess. A track
+// without movement is one segment of DefaultProfile.
+func SegmentModes(points []model.Point) []model.ModeSegment {
+	if len(points) == 0 {
+		return nil
+	}
+
+	modes := make([]string, len(points))
+	for i, p := range points {
+		if p.Speed <= minMovingSpeed {
+			continue
+		}
+		lo, hi := modeWindowBounds(points, i)
+		window := points[lo : hi+1]
+		if speeds := speedDistribution(window, minMovingSpeed, maxPlausibleSpeed); len(speeds) >= minWindowMoving {
+			modes[i] = classifyMode(window, percentile(speeds, 0.5))
+		}
+	}
+	fillStops(modes)
+
+	legs := mergeShortLegs(points, modeLegs(modes))
+	segments := make([]model.ModeSegment, len(legs))
+	for i, l := range legs {
+		segments[i] = newModeSegment(points, l)
+	}
+	return segments
+}
+
+// modeWindowBounds returns the first and last point of the window around
+// point i: every point within modeWindow, bounded by minModeWindow and
+// maxModeWindow points on each side
+func modeWindowBounds(points []model.Point, i int) (int, int) {
+	within := func(j int) bool {
+		if points[i].Time.IsZero() || points[j].Time.IsZero() {
+			return false
+		}
+		dt := points[j].Time.Sub(points[i].Time)
+		return dt <= modeWindow && dt >= -modeWindow
+	}
+
+	lo := i
+	for lo > 0 && i-lo < maxModeWindow && (i-lo < minModeWindow || within(lo-1)) {
+		lo--
+	}
+	hi := i
+	for hi < len(points)-1 && hi-i < maxModeWindow && (hi-i < minModeWindow || within(hi+1)) {
+		hi++
+	}
+	return lo, hi
+}
+
+// fillStops gives stopped points, which have no mode, the mode of the leg
+// they end; stops at the start take the first mode found
+func fillStops(modes []string) {
+	first := ""
+	for _, m := range modes {
+		if m != "" {
+			first = m
+			break
+		}
+	}
+	if first == "" {
+		first = model.DefaultProfile
+	}
+
+	last := first
+	for i, m := range modes {
+		if m == "" {
+			modes[i] = last
+		}
+		last = modes[i]
+	}
+}
+
+// modeLegs groups consecutive points of the same mode
+func modeLegs(modes []string) []modeLeg {
+	var legs []modeLeg
+	for i, m := range modes {
+		if len(legs) > 0 && legs[len(legs)-1].mode == m {
+			legs[len(legs)-1].end = i
+			continue
+		}
+		legs = append(legs, modeLeg{mode: m, start: i, end: i})
+	}
+	return legs
+}
+
+// mergeShortLegs merges legs shorter than minModeDuration into their longer
+// neighbour, shortest first, until every leg is long enough or one is left
+func mergeShortLegs(points []model.Point, legs []modeLeg) []modeLeg {
+	duration := func(l modeLeg) time.Duration {
+		return points[l.end].Time.Sub(points[l.start].Time)
+	}
+
+	for len(legs) > 1 {
+		shortest := -1
+		for i, l := range legs {
+			if duration(l) < minModeDuration && (shortest < 0 || duration(l) < duration(legs[shortest])) {
+				shortest = i
+			}
+		}
+		if shortest < 0 {
+			break
+		}
+
+		neighbour := shortest - 1
+		if shortest == 0 || (shortest < len(legs)-1 && duration(legs[shortest+1]) > duration(legs[neighbour])) {
+			neighbour = shortest + 1
+		}
+		legs[shortest].mode = legs[neighbour].mode
+
+		// Join neighbours that now share a mode
+		merged := legs[:1]
+		for _, l := range legs[1:] {
+			if prev := &merged[len(merged)-1]; prev.mode == l.mode {
+				prev.end = l.end
+				continue
+			}
+			merged = append(merged, l)
+		}
+		legs = merged
+	}
+	return legs
+}
+
+// newModeSegment summarises a leg
+func newModeSegment(points []model.Point, l modeLeg) model.ModeSegment {
+	segment := model.ModeSegment{
+		Mode:       l.mode,
+		StartIndex: l.start,
+		EndIndex:   l.end,
+		PointCount: l.end - l.start + 1,
+		StartTime:  points[l.start].Time,
+		EndTime:    points[l.end].Time,
+	}
+	for i := l.start + 1; i <= l.end; i++ {
+		if model.SameSegment(points[i-1], points[i]) {
+			segment.Distance += model.HaversineDistance(points[i-1].Lat, points[i-1].Lon, points[i].Lat, points[i].Lon)
+		}
+	}
+
+	if !segment.StartTime.IsZero() && !segment.EndTime.IsZero() {
+		seconds := segment.EndTime.Sub(segment.StartTime).Seconds()
+		segment.DurationSecs = int64(seconds)
+		if seconds > 0 {
+			segment.AvgSpeed = segment.Distance / seconds * 3.6
+		}
+	}
+	return segment
+}
+
+// DetectModeSegmentsContext detects the anomalies of each mode segment with
+// the detector detectorFor returns for its mode and merges them by type.
+// Each segment is checked together with the point before it, so a jump into
+// a segment is judged by that segment's thresholds.
+func DetectModeSegmentsContext(ctx context.Context, points []model.Point, segments []model.ModeSegment,
+	detectorFor func(mode string) *Detector) ([]model.Anomaly, error) {
+	var anomalies []model.Anomaly
+	for _, s := range segments {
+		from := s.StartIndex
+		if from > 0 {
+			from--
+		}
+
+		found, err := detectorFor(s.Mode).DetectAllContext(ctx, points[from:s.EndIndex+1])
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range found {
+			if a, ok := offsetAnomaly(a, from, s.StartIndex); ok {
+				anomalies = mergeAnomaly(anomalies, a)
+			}
+		}
+	}
+	return anomalies, nil
+}
+
+// offsetAnomaly shifts an anomaly found in a slice starting at from to track
+// indices, dropping points before start. It returns false when nothing is
+// left.
+func offsetAnomaly(a model.Anomaly, from, start int) (model.Anomaly, bool) {
+	var indices []int
+	for _, i := range a.Indices {
+		if i+from >= start {
+			indices = append(indices, i+from)
+		}
+	}
+	var gaps [][]int
+	for _, g := range a.Gaps {
+		if g[1]+from >= start {
+			gaps = append(gaps, []int{g[0] + from, g[1] + from})
+		}
+	}
+
+	a.Indices, a.Gaps = indices, gaps
+	a.Count = len(indices) + len(gaps)
+	return a, a.Count > 0
+}
+
+// mergeAnomaly adds a to the anomaly of the same type and description,
+// keeping the higher severity, or appends it
+func mergeAnomaly(anomalies []model.Anomaly, a model.Anomaly) []model.Anomaly {
+	for i := range anomalies {
+		m := &anomalies[i]
+		if m.Type != a.Type || m.Description != a.Description {
+			continue
+		}
+		m.Indices = append(m.Indices, a.Indices...)
+		m.Gaps = append(m.Gaps, a.Gaps...)
+		m.Count += a.Count
+		if severityRank(a.Severity) > severityRank(m.Severity) {
+			m.Severity = a.Severity
+		}
+		return anomalies
+	}
+	return append(anomalies, a)
+}
+
+// severityRank orders severities from low to high
+func severityRank(s model.Severity) int {
+	switch s {
+	case model.SeverityHigh:
+		return 2
+	case model.SeverityMedium:
+		return 1
+	}
+	return 0
+}
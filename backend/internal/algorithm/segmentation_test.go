@@ -0,0 +1,114 @@
+package algorithm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func TestSegmentModes(t *testing.T) {
+	// Walk to the car park, wait, drive, then walk on; a short jog while
+	// walking is no leg of its own
+	var speeds []float64
+	speeds = append(speeds, constantSpeeds(200, 5)...)
+	speeds = append(speeds, constantSpeeds(20, 12)...)
+	speeds = append(speeds, constantSpeeds(100, 5)...)
+	speeds = append(speeds, constantSpeeds(60, 0)...)
+	speeds = append(speeds, constantSpeeds(600, 60)...)
+	speeds = append(speeds, constantSpeeds(300, 5)...)
+	points := createModeTrack(time.Second, speeds...)
+
+	segments := SegmentModes(points)
+	want := []string{model.ProfileWalk, model.ProfileCar, model.ProfileWalk}
+	if len(segments) != len(want) {
+		t.Fatalf("Expected %d segments, got %+v", len(want), segments)
+	}
+
+	next := 0
+	for i, s := range segments {
+		if s.Mode != want[i] {
+			t.Errorf("Segment %d: mode = %s, want %s", i, s.Mode, want[i])
+		}
+		if s.StartIndex != next || s.PointCount != s.EndIndex-s.StartIndex+1 {
+			t.Errorf("Segment %d: points %d-%d (%d), want a run from %d", i, s.StartIndex, s.EndIndex, s.PointCount, next)
+		}
+		next = s.EndIndex + 1
+	}
+	if next != len(points) {
+		t.Errorf("Segments end at %d, want %d", next-1, len(points)-1)
+	}
+
+	// The wait joins the walk it ends, the drive starts when the car moves
+	if car := segments[1]; car.StartIndex < 370 || car.StartIndex > 390 {
+		t.Errorf("Drive starts at %d, want about 380", car.StartIndex)
+	}
+	if car := segments[1]; car.AvgSpeed < 55 || car.AvgSpeed > 65 {
+		t.Errorf("Drive average speed = %.1f km/h, want about 60", car.AvgSpeed)
+	}
+	if walk := segments[2]; walk.DurationSecs < 280 || walk.DurationSecs > 320 {
+		t.Errorf("Last walk lasts %ds, want about 300", walk.DurationSecs)
+	}
+
+	// Stationary and empty tracks
+	segments = SegmentModes(createModeTrack(time.Second, constantSpeeds(50, 0)...))
+	if len(segments) != 1 || segments[0].Mode != model.DefaultProfile {
+		t.Errorf("Expected one %s segment for a stationary track, got %+v", model.DefaultProfile, segments)
+	}
+	if segments := SegmentModes(nil); segments != nil {
+		t.Errorf("Expected no segments for an empty track, got %+v", segments)
+	}
+}
+
+func TestDetectModeSegments(t *testing.T) {
+	speeds := append(constantSpeeds(300, 5), constantSpeeds(300, 60)...)
+	speeds[450] = 200
+	points := createModeTrack(time.Second, speeds...)
+	segments := SegmentModes(points)
+	if len(segments) != 2 {
+		t.Fatalf("Expected a walk and a drive, got %+v", segments)
+	}
+
+	detectorFor := func(mode string) *Detector {
+		profile, _ := model.LookupProfile(mode)
+		d := NewDetector()
+		d.MaxSpeed = profile.Thresholds.MaxSpeed
+		d.MaxAcceleration = profile.Thresholds.MaxAcceleration
+		d.MaxJump = profile.Thresholds.MaxJump
+		return d
+	}
+	anomalies, err := DetectModeSegmentsContext(context.Background(), points, segments, detectorFor)
+	if err != nil {
+		t.Fatalf("Failed to detect: %v", err)
+	}
+
+	// Only the spike is too fast for a car; walking thresholds on the
+	// whole track would flag the entire drive
+	var speedAnomalies []model.Anomaly
+	for _, a := range anomalies {
+		if a.Type == model.AnomalySpeedAnomaly {
+			speedAnomalies = append(speedAnomalies, a)
+		}
+	}
+	if len(speedAnomalies) != 1 {
+		t.Fatalf("Expected one speed anomaly, got %+v", speedAnomalies)
+	}
+	if a := speedAnomalies[0]; len(a.Indices) != 1 || a.Indices[0] != 450 || a.Count != 1 {
+		t.Errorf("Expected the spike at index 450, got %v (count %d)", a.Indices, a.Count)
+	}
+}
+
+func TestMergeAnomaly(t *testing.T) {
+	var anomalies []model.Anomaly
+	anomalies = mergeAnomaly(anomalies, model.Anomaly{Type: model.AnomalyJump, Description: "jump", Count: 1, Severity: model.SeverityLow, Indices: []int{3}})
+	anomalies = mergeAnomaly(anomalies, model.Anomaly{Type: model.AnomalyJump, Description: "jump", Count: 2, Severity: model.SeverityHigh, Indices: []int{40, 41}})
+	anomalies = mergeAnomaly(anomalies, model.Anomaly{Type: model.AnomalyDrift, Description: "drift", Count: 1, Severity: model.SeverityMedium, Indices: []int{7}})
+
+	if len(anomalies) != 2 {
+		t.Fatalf("Expected 2 anomalies, got %+v", anomalies)
+	}
+	if a := anomalies[0]; a.Count != 3 || len(a.Indices) != 3 || a.Severity != model.SeverityHigh {
+		t.Errorf("Expected merged jumps with 3 points and high severity, got %+v", a)
+	}
+}
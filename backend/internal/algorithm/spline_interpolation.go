@@ -8,8 +8,12 @@ import (
 
 // SplineInterpolator implements cubic spline interpolation for filling gaps
 type SplineInterpolator struct {
+	// Interval that counts as a gap (in seconds)
+	gapSeconds int
 	// Maximum gap size to interpolate (in seconds)
 	maxGapSeconds int
+	// Maximum points added per gap
+	maxPoints int
 	// Minimum points for interpolation
 	minPoints int
 }
@@ -34,34 +38,73 @@ type SplineCoeff struct {
 	A, B, C, D float64
 }
 
-// NewSplineInterpolator creates a new spline interpolator
+// NewSplineInterpolator creates a new spline interpolator with default parameters
 func NewSplineInterpolator() *SplineInterpolator {
+	return NewSplineInterpolatorWithParameters(model.DefaultParameters().SplineInterpolation)
+}
+
+// NewSplineInterpolatorWithParameters creates a new spline interpolator;
+// unset parameters use the defaults
+func NewSplineInterpolatorWithParameters(params model.SplineParameters) *SplineInterpolator {
+	params = model.AlgorithmParameters{SplineInterpolation: params}.WithDefaults().SplineInterpolation
 	return &SplineInterpolator{
-		maxGapSeconds: 60,  // Maximum 1 minute gap
-		minPoints:     2,   // Need at least 2 points
+		gapSeconds:    params.GapSeconds,
+		maxGapSeconds: params.MaxGapSeconds,
+		maxPoints:     params.MaxPoints,
+		minPoints:     2, // Need at least 2 points
+	}
+}
+
+// Parameters returns the parameters the interpolator runs with
+func (s *SplineInterpolator) Parameters() model.SplineParameters {
+	return model.SplineParameters{
+		GapSeconds:    s.gapSeconds,
+		MaxGapSeconds: s.maxGapSeconds,
+		MaxPoints:     s.maxPoints,
 	}
 }
 
-// Interpolate fills missing points in the trajectory
+// Interpolate fills missing points in the trajectory. Gaps are only filled
+// within a segment; pauses between segments are left as recorded.
 func (s *SplineInterpolator) Interpolate(points []model.Point) []model.Point {
 	if len(points) < s.minPoints {
 		return points
 	}
 
+	if segments := model.SplitSegments(points); len(segments) > 1 {
+		result := make([]model.Point, 0, len(points))
+		for _, segment := range segments {
+			result = append(result, s.interpolateGaps(segment)...)
+		}
+		return result
+	}
+
+	return s.interpolateGaps(points)
+}
+
+// interpolateGaps fills the gaps of a single segment
+func (s *SplineInterpolator) interpolateGaps(points []model.Point) []model.Point {
+	if len(points) < s.minPoints {
+		return points
+	}
+
 	// Find gaps
 	gaps := s.findGaps(points)
 	if len(gaps) == 0 {
 		return points
 	}
 
-	// Process each gap
+	// Process each gap, keeping the points between gaps
 	result := make([]model.Point, 0, len(points)+len(gaps)*10)
-	result = append(result, points[:gaps[0][0]]...)
+	next := 0
 
-	for i, gap := range gaps {
+	for _, gap := range gaps {
 		startIdx := gap[0]
 		endIdx := gap[1]
 
+		result = append(result, points[next:startIdx+1]...)
+		next = endIdx + 1
+
 		// Get boundary points
 		prevPoint := points[startIdx]
 		nextPoint := points[endIdx]
@@ -80,7 +123,7 @@ func (s *SplineInterpolator) Interpolate(points []model.Point) []model.Point {
 		}
 
 		// Calculate number of points to interpolate
-			avgInterval := s.calculateAverageInterval(points, intMax(0, startIdx-5), intMin(len(points)-1, endIdx+5))
+		avgInterval := s.calculateAverageInterval(points, intMax(0, startIdx-5), intMin(len(points)-1, endIdx+5))
 		if avgInterval <= 0 {
 			avgInterval = 1.0
 		}
@@ -89,18 +132,18 @@ func (s *SplineInterpolator) Interpolate(points []model.Point) []model.Point {
 		if numPoints < 1 {
 			numPoints = 1
 		}
-		if numPoints > 100 { // Limit interpolation points
-			numPoints = 100
+		if numPoints > s.maxPoints { // Limit interpolation points
+			numPoints = s.maxPoints
 		}
 
 		// Perform interpolation
 		interpolated := s.interpolateSegment(prevPoint, nextPoint, numPoints, startIdx)
 		result = append(result, interpolated...)
+		result = append(result, points[endIdx])
+	}
 
-		// Add next point if not last gap
-		if i < len(gaps)-1 || endIdx < len(points)-1 {
-			result = append(result, points[endIdx])
-		}
+	if next < len(points) {
+		result = append(result, points[next:]...)
 	}
 
 	return result
@@ -116,14 +159,14 @@ func (s *SplineInterpolator) findGaps(points []model.Point) [][]int {
 		if !points[i].Time.IsZero() && !points[i-1].Time.IsZero() {
 			gap := points[i].Time.Sub(points[i-1].Time).Seconds()
 
-			// If gap is larger than expected (more than 3x average interval)
-			if gap > 10 { // More than 10 seconds is considered a gap
+			// Intervals longer than gapSeconds are gaps
+			if gap > float64(s.gapSeconds) {
 				if start == -1 {
 					start = i - 1
 				}
 			} else if start != -1 {
-				// End of gap
-				gaps = append(gaps, []int{start, i})
+				// End of gap: the previous point closed it
+				gaps = append(gaps, []int{start, i - 1})
 				start = -1
 			}
 		}
@@ -212,7 +255,10 @@ func (s *SplineInterpolator) interpolateSegment(
 			Time:           p1.Time.Add(time.Duration(dt*float64(i+1)+0.5) * time.Second),
 			Status:         model.StatusInterpolated,
 			IsInterpolated: true,
-			FixedBy:        "样条插值", // Mark as fixed by spline interpolation
+			FixedBy:        FixedBySpline,
+			Lap:            p1.Lap,
+			Track:          p1.Track,
+			Segment:        p1.Segment,
 		}
 
 		if eleCoeffs != nil {
@@ -301,10 +347,13 @@ func (s *SplineInterpolator) InterpolatePoints(
 		prevIdx := idx - 1
 		nextIdx := idx + 1
 
-		// Skip if neighbors are also interpolated
+		// Skip if neighbors are also interpolated or in another segment
 		if result[prevIdx].IsInterpolated || result[nextIdx].IsInterpolated {
 			continue
 		}
+		if !model.SameSegment(result[prevIdx], result[nextIdx]) {
+			continue
+		}
 
 		// Interpolate single point
 		t := 0.5
@@ -327,6 +376,9 @@ func (s *SplineInterpolator) InterpolatePoints(
 			IsInterpolated: true,
 			Speed:          model.CalculateSpeed(result[prevIdx], result[nextIdx]),
 			Bearing:        model.CalculateBearing(result[prevIdx].Lat, result[prevIdx].Lon, result[nextIdx].Lat, result[nextIdx].Lon),
+			Lap:            result[prevIdx].Lap,
+			Track:          result[prevIdx].Track,
+			Segment:        result[prevIdx].Segment,
 		}
 
 		if result[prevIdx].Elevation > 0 && result[nextIdx].Elevation > 0 {
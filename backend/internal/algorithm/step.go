@@ -0,0 +1,149 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Step is one correction stage of the pipeline. Steps are stateless; each
+// run receives its resolved parameters.
+type Step interface {
+	// Name is the registry key used in request step lists
+	Name() string
+	// Parameters describes the accepted parameters
+	Parameters() []model.ParameterSpec
+	// Apply corrects points and reports what changed; long-running steps
+	// should stop with ctx's error once it is canceled
+	Apply(ctx context.Context, points []model.Point, params Params, input StepInput) ([]model.Point, StepStats, error)
+}
+
+// Describer is implemented by steps with a display name and description for
+// the algorithm report
+type Describer interface {
+	DisplayName() string
+	Description() string
+}
+
+// ParameterChecker is implemented by steps whose parameters have constraints
+// beyond the per-parameter bounds of their schema
+type ParameterChecker interface {
+	CheckParameters(params Params) error
+}
+
+// StepInput carries diagnosis results a step may use
+type StepInput struct {
+	Anomalies []model.Anomaly // Detected on the input track, indexed by Point.Index
+	Stops     []model.Stop    // Stay points of the input track, indexed by Point.Index
+}
+
+// StepStats reports what a step changed
+type StepStats struct {
+	FixedBy  string                 // Point.FixedBy label of points this step corrected
+	Added    int                    // Points inserted
+	Removed  int                    // Redundant points dropped
+	Rejected int                    // Faulty points dropped
+	Details  map[string]interface{} // Extra values for the algorithm report
+}
+
+// Params holds a step's resolved parameter values
+type Params map[string]interface{}
+
+// Float returns a number parameter
+func (p Params) Float(name string) float64 {
+	switch v := p[name].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return 0
+}
+
+// Int returns an integer parameter
+func (p Params) Int(name string) int {
+	switch v := p[name].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// Bool returns a boolean parameter
+func (p Params) Bool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+
+// Registry holds the correction steps available to pipelines
+type Registry struct {
+	mu    sync.RWMutex
+	steps map[string]Step
+}
+
+// NewRegistry creates a registry holding the built-in steps
+func NewRegistry() *Registry {
+	r := &Registry{steps: make(map[string]Step)}
+	for _, step := range builtinSteps() {
+		r.steps[step.Name()] = step
+	}
+	return r
+}
+
+// Register adds a step; names must be unique
+func (r *Registry) Register(step Step) error {
+	name := step.Name()
+	if name == "" {
+		return fmt.Errorf("step name is empty")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.steps[name]; exists {
+		return fmt.Errorf("step %q is already registered", name)
+	}
+	r.steps[name] = step
+	return nil
+}
+
+// Lookup returns the step registered under name
+func (r *Registry) Lookup(name string) (Step, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	step, ok := r.steps[name]
+	return step, ok
+}
+
+// Steps returns the registered steps sorted by name
+func (r *Registry) Steps() []Step {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	steps := make([]Step, 0, len(r.steps))
+	for _, step := range r.steps {
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Name() < steps[j].Name()
+	})
+	return steps
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the process-wide registry used by the API
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a step to the default registry
+func Register(step Step) error {
+	return defaultRegistry.Register(step)
+}
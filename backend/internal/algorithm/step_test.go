@@ -0,0 +1,113 @@
+package algorithm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// shiftStep moves every point north by a configurable offset
+type shiftStep struct{}
+
+func (shiftStep) Name() string { return "shift" }
+func (shiftStep) Parameters() []model.ParameterSpec {
+	return []model.ParameterSpec{
+		{Name: "degrees", Type: model.ParameterNumber, Default: 0.001, Min: -1, Max: 1},
+	}
+}
+
+func (shiftStep) Apply(ctx context.Context, points []model.Point, params Params, input StepInput) ([]model.Point, StepStats, error) {
+	for i := range points {
+		points[i].Lat += params.Float("degrees")
+		points[i].FixedBy = "shift"
+	}
+	return points, StepStats{FixedBy: "shift"}, nil
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+
+	for _, name := range []string{model.StepAdaptiveRTS, model.StepSplineInterpolation, model.StepSimplification, model.StepOutlierRemoval} {
+		if _, ok := r.Lookup(name); !ok {
+			t.Errorf("Expected built-in step %s", name)
+		}
+	}
+
+	if err := r.Register(shiftStep{}); err != nil {
+		t.Fatalf("Failed to register step: %v", err)
+	}
+	if err := r.Register(shiftStep{}); err == nil {
+		t.Error("Expected error registering a duplicate step")
+	}
+	if len(r.Steps()) != 5 {
+		t.Errorf("Expected 5 steps, got %d", len(r.Steps()))
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+	r := NewRegistry()
+	r.Register(shiftStep{})
+
+	points := createTestPoints(20)
+	points[10].Lat += 0.05
+
+	anomalies := NewDetector().DetectAll(points)
+
+	// Outlier removal first, then a custom step
+	pipeline, details := r.NewPipeline([]model.StepConfig{
+		{Name: model.StepOutlierRemoval},
+		{Name: "shift", Parameters: map[string]interface{}{"degrees": 0.01}},
+	})
+	if details != nil {
+		t.Fatalf("Failed to build pipeline: %+v", details)
+	}
+
+	result, reports, err := pipeline.Run(context.Background(), points, StepInput{Anomalies: anomalies})
+	if err != nil {
+		t.Fatalf("Failed to run pipeline: %v", err)
+	}
+
+	if len(reports) != 2 || reports[0].Step.Name() != model.StepOutlierRemoval || reports[1].Step.Name() != "shift" {
+		t.Fatalf("Expected reports in step order, got %+v", reports)
+	}
+	if reports[0].Stats.Rejected == 0 {
+		t.Error("Expected the jump to be rejected")
+	}
+	if len(reports[1].FixedIndices) != len(result) {
+		t.Errorf("Expected all %d points fixed by shift, got %d", len(result), len(reports[1].FixedIndices))
+	}
+	if reports[1].Params.Float("degrees") != 0.01 {
+		t.Errorf("Expected degrees 0.01, got %v", reports[1].Params["degrees"])
+	}
+	if result[0].Lat != points[0].Lat+0.01 {
+		t.Errorf("Expected shifted latitude, got %f", result[0].Lat)
+	}
+}
+
+func TestPipeline_Validation(t *testing.T) {
+	r := NewRegistry()
+
+	cases := []struct {
+		name  string
+		steps []model.StepConfig
+		field string
+	}{
+		{"unknown step", []model.StepConfig{{Name: "median"}}, "steps[0].name"},
+		{"unknown parameter", []model.StepConfig{{Name: model.StepAdaptiveRTS, Parameters: map[string]interface{}{"q": 1.0}}}, "steps[0].parameters.q"},
+		{"out of range", []model.StepConfig{{Name: model.StepOutlierRemoval}, {Name: model.StepSimplification, Parameters: map[string]interface{}{"epsilon": -1.0}}}, "steps[1].parameters.epsilon"},
+		{"not an integer", []model.StepConfig{{Name: model.StepSplineInterpolation, Parameters: map[string]interface{}{"maxPoints": 2.5}}}, "steps[0].parameters.maxPoints"},
+		{"gap order", []model.StepConfig{{Name: model.StepSplineInterpolation, Parameters: map[string]interface{}{"gapSeconds": 120.0}}}, "steps[0].parameters"},
+	}
+
+	for _, c := range cases {
+		_, details := r.NewPipeline(c.steps)
+		if details == nil {
+			t.Errorf("%s: expected validation error", c.name)
+			continue
+		}
+		if details.Field != c.field {
+			t.Errorf("%s: expected field %s, got %s", c.name, c.field, details.Field)
+		}
+	}
+}
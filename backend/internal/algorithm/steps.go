@@ -0,0 +1,149 @@
+package algorithm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Point.FixedBy labels written by the built-in steps
+const (
+	FixedByRTS    = "RTS平滑"
+	FixedBySpline = "样条插值"
+)
+
+// builtinSteps returns the steps every registry starts with
+func builtinSteps() []Step {
+	return []Step{rtsStep{}, splineStep{}, simplifyStep{}, outlierStep{}}
+}
+
+// rtsStep runs the AdaptiveRTS smoother
+type rtsStep struct{}
+
+func (rtsStep) Name() string                      { return model.StepAdaptiveRTS }
+func (rtsStep) Parameters() []model.ParameterSpec { return model.RTSParameterSpecs() }
+func (rtsStep) DisplayName() string               { return "自适应RTS平滑器" }
+func (rtsStep) Description() string {
+	return "基于Rauch-Tung-Striebel算法的双向卡尔曼滤波平滑，自动估计测量噪声"
+}
+
+func (rtsStep) Apply(ctx context.Context, points []model.Point, params Params, input StepInput) ([]model.Point, StepStats, error) {
+	rts := NewAdaptiveRTSWithParameters(model.RTSParameters{
+		ProcessNoise:     params.Float("processNoise"),
+		MeasurementNoise: params.Float("measurementNoise"),
+		VBAlpha:          params.Float("vbAlpha"),
+		VBBeta:           params.Float("vbBeta"),
+	})
+	result, err := rts.SmoothContext(ctx, points)
+	if err != nil {
+		return nil, StepStats{}, err
+	}
+	result = rts.CollapseStops(result, input.Stops)
+	return result, StepStats{
+		FixedBy: FixedByRTS,
+		Details: map[string]interface{}{
+			"frame":  "ENU",
+			"method": "变分贝叶斯估计",
+		},
+	}, nil
+}
+
+// splineStep fills time gaps with cubic spline points
+type splineStep struct{}
+
+func (splineStep) Name() string                      { return model.StepSplineInterpolation }
+func (splineStep) Parameters() []model.ParameterSpec { return model.SplineParameterSpecs() }
+func (splineStep) DisplayName() string               { return "三次样条插值器" }
+func (splineStep) Description() string {
+	return "使用三次样条曲线在缺失点之间进行平滑插值"
+}
+
+func (splineStep) CheckParameters(params Params) error {
+	if params.Int("maxGapSeconds") < params.Int("gapSeconds") {
+		return fmt.Errorf("maxGapSeconds (%d) must not be less than gapSeconds (%d)",
+			params.Int("maxGapSeconds"), params.Int("gapSeconds"))
+	}
+	return nil
+}
+
+func (splineStep) Apply(ctx context.Context, points []model.Point, params Params, input StepInput) ([]model.Point, StepStats, error) {
+	interpolator := NewSplineInterpolatorWithParameters(model.SplineParameters{
+		GapSeconds:    params.Int("gapSeconds"),
+		MaxGapSeconds: params.Int("maxGapSeconds"),
+		MaxPoints:     params.Int("maxPoints"),
+	})
+	result := interpolator.Interpolate(points)
+	added := len(result) - len(points)
+	return result, StepStats{
+		FixedBy: FixedBySpline,
+		Added:   added,
+		Details: map[string]interface{}{
+			"method":      "cubic",
+			"tension":     0.5,
+			"addedPoints": added,
+		},
+	}, nil
+}
+
+// simplifyStep runs noise-aware Douglas-Peucker simplification
+type simplifyStep struct{}
+
+func (simplifyStep) Name() string                      { return model.StepSimplification }
+func (simplifyStep) Parameters() []model.ParameterSpec { return model.SimplifyParameterSpecs() }
+func (simplifyStep) DisplayName() string               { return "Douglas-Peucker简化器" }
+func (simplifyStep) Description() string {
+	return "保留关键特征点的同时简化轨迹，减少数据冗余"
+}
+
+func (simplifyStep) Apply(ctx context.Context, points []model.Point, params Params, input StepInput) ([]model.Point, StepStats, error) {
+	dp := NewImprovedDouglasPeucker(params.Float("epsilon"))
+	dp.ConsiderNoise = params.Bool("considerNoise")
+	result := dp.Simplify(points)
+	return result, StepStats{Removed: len(points) - len(result)}, nil
+}
+
+// outlierStep drops points flagged by high-severity anomalies
+type outlierStep struct{}
+
+func (outlierStep) Name() string                      { return model.StepOutlierRemoval }
+func (outlierStep) Parameters() []model.ParameterSpec { return nil }
+func (outlierStep) DisplayName() string               { return "离群点移除器" }
+func (outlierStep) Description() string {
+	return "移除高严重性的异常点（GPS漂移、跳跃等）"
+}
+
+func (outlierStep) Apply(ctx context.Context, points []model.Point, params Params, input StepInput) ([]model.Point, StepStats, error) {
+	stats := StepStats{
+		Details: map[string]interface{}{
+			"method":    "severity_high",
+			"threshold": "仅移除高严重性异常",
+		},
+	}
+
+	outlierIndices := make(map[int]bool)
+	for _, a := range input.Anomalies {
+		if a.Severity == model.SeverityHigh {
+			for _, idx := range a.Indices {
+				outlierIndices[idx] = true
+			}
+		}
+	}
+
+	if len(outlierIndices) == 0 {
+		return points, stats, nil
+	}
+
+	// Anomaly indices refer to the input track, so match on Point.Index;
+	// interpolated points reuse neighbouring indices and are never outliers
+	result := make([]model.Point, 0, len(points))
+	for _, p := range points {
+		if !p.IsInterpolated && outlierIndices[p.Index] {
+			continue
+		}
+		result = append(result, p)
+	}
+
+	stats.Rejected = len(points) - len(result)
+	return result, stats, nil
+}
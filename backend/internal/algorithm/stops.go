@@ -0,0 +1,156 @@
+package algorithm
+
+import (
+	"sort"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// DetectStayPoints finds where a trajectory stayed within radius meters of
+// the point it arrived at for at least minDwell. A stop ends at a track
+// segment break or at a point without a time. Two stops at the same place
+// that an excursion of at most minDwell separates, such as a GPS spike or a
+// quick walk to the car, are joined into one; the excursion is not part of
+// the stop's Ranges.
+func DetectStayPoints(points []model.Point, radius float64, minDwell time.Duration) []model.Stop {
+	if radius <= 0 || minDwell <= 0 {
+		return nil
+	}
+
+	var stops []model.Stop
+	for i := 0; i < len(points); {
+		if points[i].Time.IsZero() {
+			i++
+			continue
+		}
+
+		// Extend the stop while points stay near the arrival point
+		j := i + 1
+		for j < len(points) && model.SameSegment(points[j-1], points[j]) && !points[j].Time.IsZero() &&
+			model.HaversineDistance(points[i].Lat, points[i].Lon, points[j].Lat, points[j].Lon) <= radius {
+			j++
+		}
+		if points[j-1].Time.Sub(points[i].Time) < minDwell {
+			i++
+			continue
+		}
+
+		stop := newStop(points, [][]int{{i, j - 1}})
+		if n := len(stops); n > 0 && joinsStop(points, stops[n-1], stop, radius, minDwell) {
+			// Only the points near the joined stop's arrival point belong to it
+			prev := stops[n-1]
+			ranges := append(prev.Ranges, rangesNear(points, points[prev.StartIndex], i, j-1, radius)...)
+			stops[n-1] = newStop(points, ranges)
+		} else {
+			stops = append(stops, stop)
+		}
+		i = j
+	}
+	return stops
+}
+
+// joinsStop reports whether next continues prev after a short excursion
+func joinsStop(points []model.Point, prev, next model.Stop, radius float64, minDwell time.Duration) bool {
+	return model.SameSegment(points[prev.EndIndex], points[next.StartIndex]) &&
+		next.Arrival.Sub(prev.Departure) <= minDwell &&
+		model.HaversineDistance(prev.Lat, prev.Lon, next.Lat, next.Lon) <= radius
+}
+
+// rangesNear returns the runs of points from start to end, inclusive, that
+// are within radius meters of anchor
+func rangesNear(points []model.Point, anchor model.Point, start, end int, radius float64) [][]int {
+	var ranges [][]int
+	for k := start; k <= end; k++ {
+		if model.HaversineDistance(anchor.Lat, anchor.Lon, points[k].Lat, points[k].Lon) > radius {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1][1] == k-1 {
+			ranges[n-1][1] = k
+		} else {
+			ranges = append(ranges, []int{k, k})
+		}
+	}
+	return ranges
+}
+
+// newStop summarises the points in ranges, which are sorted and inclusive
+func newStop(points []model.Point, ranges [][]int) model.Stop {
+	start, end := ranges[0][0], ranges[len(ranges)-1][1]
+	stop := model.Stop{
+		StartIndex: start,
+		EndIndex:   end,
+		Ranges:     ranges,
+		Arrival:    points[start].Time,
+		Departure:  points[end].Time,
+		DwellSecs:  int64(points[end].Time.Sub(points[start].Time).Seconds()),
+	}
+
+	for _, r := range ranges {
+		for _, p := range points[r[0] : r[1]+1] {
+			stop.Lat += p.Lat
+			stop.Lon += p.Lon
+			stop.PointCount++
+		}
+	}
+	stop.Lat /= float64(stop.PointCount)
+	stop.Lon /= float64(stop.PointCount)
+
+	for _, r := range ranges {
+		for _, p := range points[r[0] : r[1]+1] {
+			if d := model.HaversineDistance(stop.Lat, stop.Lon, p.Lat, p.Lon); d > stop.Radius {
+				stop.Radius = d
+			}
+		}
+	}
+	return stop
+}
+
+// stopAt returns the stop of stops sorted by position whose ranges contain
+// index i. Points of an excursion between two ranges are in no stop.
+func stopAt(stops []model.Stop, i int) (model.Stop, bool) {
+	k := sort.Search(len(stops), func(k int) bool { return stops[k].EndIndex >= i })
+	if k < len(stops) && stops[k].StartIndex <= i {
+		for _, r := range stops[k].Ranges {
+			if r[0] <= i && i <= r[1] {
+				return stops[k], true
+			}
+		}
+	}
+	return model.Stop{}, false
+}
+
+// stopJitter lists the anomaly types that jitter while standing still
+// produces: the noise looks like movement, and the fixes pile up
+var stopJitter = map[model.AnomalyType]bool{
+	model.AnomalySpeedAnomaly: true,
+	model.AnomalyAccelAnomaly: true,
+	model.AnomalyDrift:        true,
+	model.AnomalyDensity:      true,
+}
+
+// withoutStopJitter drops the points inside stops from anomalies of the
+// stopJitter types. Jumps and gaps are reported even inside a stop.
+func withoutStopJitter(anomalies []model.Anomaly, stops []model.Stop) []model.Anomaly {
+	if len(stops) == 0 {
+		return anomalies
+	}
+
+	kept := make([]model.Anomaly, 0, len(anomalies))
+	for _, a := range anomalies {
+		if stopJitter[a.Type] {
+			var indices []int
+			for _, i := range a.Indices {
+				if _, ok := stopAt(stops, i); !ok {
+					indices = append(indices, i)
+				}
+			}
+			if len(indices) == 0 {
+				continue
+			}
+			a.Indices, a.Count = indices, len(indices)
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
@@ -0,0 +1,187 @@
+package algorithm
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// createStopTrack creates a 2-minute walk, a 4-minute stop with up to 15 m
+// of jitter at points 120 to 359, and another 2-minute walk, sampled every
+// second
+func createStopTrack() []model.Point {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	const metersPerDegree = 111320.0
+	step := 5 / 3.6 / (metersPerDegree * 0.7666) // 5 km/h east at 39.9°
+
+	points := make([]model.Point, 480)
+	lon := 116.4074
+	for i := range points {
+		lat, pointLon := 39.9042, lon
+		if i < 120 || i >= 360 {
+			lon += step
+			pointLon = lon
+		} else {
+			// Deterministic jitter around where the walk ended
+			lat += 15 * math.Sin(float64(i)*1.7) / metersPerDegree
+			pointLon += 15 * math.Cos(float64(i)*2.3) / (metersPerDegree * 0.7666)
+		}
+		points[i] = model.Point{
+			Index:  i,
+			Lat:    lat,
+			Lon:    pointLon,
+			Time:   start.Add(time.Duration(i) * time.Second),
+			Status: model.StatusNormal,
+		}
+		if i > 0 {
+			points[i].Speed = model.CalculateSpeed(points[i-1], points[i])
+		}
+	}
+	return points
+}
+
+func TestDetectStayPoints(t *testing.T) {
+	points := createStopTrack()
+
+	stops := DetectStayPoints(points, 50, 2*time.Minute)
+	if len(stops) != 1 {
+		t.Fatalf("Expected 1 stop, got %+v", stops)
+	}
+	stop := stops[0]
+	// Walking the last and first 50 m counts towards the stop
+	if stop.StartIndex < 84 || stop.StartIndex > 120 || stop.EndIndex < 359 || stop.EndIndex > 396 {
+		t.Errorf("Stop spans points %d-%d, want about 120-359", stop.StartIndex, stop.EndIndex)
+	}
+	if stop.PointCount != stop.EndIndex-stop.StartIndex+1 {
+		t.Errorf("PointCount = %d for points %d-%d", stop.PointCount, stop.StartIndex, stop.EndIndex)
+	}
+	if stop.DwellSecs < 239 || stop.DwellSecs > 312 {
+		t.Errorf("Dwell = %ds, want 240 to 312", stop.DwellSecs)
+	}
+	if !stop.Arrival.Equal(points[stop.StartIndex].Time) || !stop.Departure.Equal(points[stop.EndIndex].Time) {
+		t.Errorf("Arrival and departure should be the times of the first and last point")
+	}
+	if d := model.HaversineDistance(stop.Lat, stop.Lon, 39.9042, points[119].Lon); d > 10 {
+		t.Errorf("Centroid is %.1f m from where the walk ended", d)
+	}
+	if stop.Radius < 10 || stop.Radius > 50 {
+		t.Errorf("Radius = %.1f m, want the jitter amplitude", stop.Radius)
+	}
+
+	// Shorter pauses are no stops
+	if stops := DetectStayPoints(points, 50, 5*time.Minute); len(stops) != 0 {
+		t.Errorf("Expected no 5-minute stops, got %+v", stops)
+	}
+
+	// A spike out of the stop does not split it
+	spiked := createStopTrack()
+	spiked[240].Lat += 0.005
+	if stops := DetectStayPoints(spiked, 50, 2*time.Minute); len(stops) != 1 || stops[0].EndIndex < 359 {
+		t.Errorf("Expected one stop across the spike, got %+v", stops)
+	}
+
+	// Without times no dwell can be measured
+	for i := range spiked {
+		spiked[i].Time = time.Time{}
+	}
+	if stops := DetectStayPoints(spiked, 50, 2*time.Minute); len(stops) != 0 {
+		t.Errorf("Expected no stops without times, got %+v", stops)
+	}
+}
+
+func TestDetectStayPoints_Excursion(t *testing.T) {
+	// 400 s standing still with a 20 s excursion 1 km north in the middle
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]model.Point, 400)
+	for i := range points {
+		lat := 39.9042 + 3*math.Sin(float64(i)*1.7)/111320
+		if i >= 190 && i < 210 {
+			lat += 1000.0 / 111320
+		}
+		points[i] = model.Point{Index: i, Lat: lat, Lon: 116.4074, Time: start.Add(time.Duration(i) * time.Second)}
+	}
+
+	stops := DetectStayPoints(points, 50, 2*time.Minute)
+	if len(stops) != 1 {
+		t.Fatalf("Expected 1 stop, got %+v", stops)
+	}
+	stop := stops[0]
+	if want := [][]int{{0, 189}, {210, 399}}; !reflect.DeepEqual(stop.Ranges, want) {
+		t.Errorf("Ranges = %v, want %v", stop.Ranges, want)
+	}
+	if stop.PointCount != 380 {
+		t.Errorf("PointCount = %d, want 380 without the excursion", stop.PointCount)
+	}
+	if d := model.HaversineDistance(stop.Lat, stop.Lon, 39.9042, 116.4074); d > 1 {
+		t.Errorf("Centroid is %.1f m off, want the excursion left out", d)
+	}
+	if stop.Radius > 5 {
+		t.Errorf("Radius = %.1f m, want the jitter amplitude", stop.Radius)
+	}
+
+	collapsed := NewAdaptiveRTS().CollapseStops(points, stops)
+	for i := 190; i < 210; i++ {
+		if collapsed[i].Lat != points[i].Lat || collapsed[i].FixedBy != "" {
+			t.Fatalf("Excursion point %d was collapsed into the stop", i)
+		}
+	}
+	if collapsed[100].Lat != stop.Lat || collapsed[300].Lat != stop.Lat {
+		t.Errorf("Points of both stays should be collapsed to the centroid")
+	}
+}
+
+func TestDetector_StopJitter(t *testing.T) {
+	points := createStopTrack()
+
+	// Without stop detection the jitter reads as movement
+	d := NewDetector()
+	d.StopRadius = 0
+	if anomalies := d.DetectAll(points); len(anomalies) == 0 {
+		t.Fatal("Expected the jitter to produce anomalies without stop detection")
+	}
+
+	anomalies := NewDetector().DetectAll(points)
+	for _, a := range anomalies {
+		for _, i := range a.Indices {
+			if i >= 125 && i < 355 {
+				t.Errorf("Point %d inside the stop reported as %s", i, a.Type)
+			}
+		}
+	}
+}
+
+func TestAdaptiveRTS_CollapseStops(t *testing.T) {
+	points := createStopTrack()
+	stops := DetectStayPoints(points, 50, 2*time.Minute)
+	if len(stops) != 1 {
+		t.Fatalf("Expected 1 stop, got %d", len(stops))
+	}
+
+	rts := NewAdaptiveRTS()
+	smoothed, err := rts.SmoothContext(context.Background(), points)
+	if err != nil {
+		t.Fatalf("Failed to smooth: %v", err)
+	}
+	collapsed := rts.CollapseStops(smoothed, stops)
+
+	stop := stops[0]
+	for i := stop.StartIndex; i <= stop.EndIndex; i++ {
+		p := collapsed[i]
+		if p.Lat != stop.Lat || p.Lon != stop.Lon {
+			t.Fatalf("Point %d at %v,%v, want the centroid %v,%v", i, p.Lat, p.Lon, stop.Lat, stop.Lon)
+		}
+		if i > stop.StartIndex && p.Speed != 0 {
+			t.Errorf("Point %d speed = %v inside the stop, want 0", i, p.Speed)
+		}
+		if math.Abs(p.OriginalLat-points[i].Lat) > 1e-8 || p.FixedBy != FixedByRTS {
+			t.Errorf("Point %d should keep its original position and name the smoother", i)
+		}
+	}
+	if collapsed[0].Lat != smoothed[0].Lat || collapsed[len(collapsed)-1].Lon != smoothed[len(smoothed)-1].Lon {
+		t.Errorf("Points outside the stop should keep their smoothed position")
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/positiondoctor/backend/internal/batch"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Batch diagnoses every track in an uploaded gzip or zip archive and responds
+// with one summary, as JSON or, with format=csv, as a CSV table
+func (h *Handler) Batch(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxFileSize); err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid_request", "Failed to parse form data", nil)
+		return
+	}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		h.respondError(w, http.StatusBadRequest, "invalid_request", "Invalid summary format. Supported formats: json, csv",
+			&model.ErrorDetails{Field: "format", ReceivedFormat: format})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "invalid_request", "File is required", nil)
+		return
+	}
+	defer file.Close()
+
+	options := h.parseOptions(r)
+	if err := h.service.Validate(options); err != nil {
+		h.respondDiagnosisError(w, err)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to read file", nil)
+		return
+	}
+
+	files, err := batch.ArchiveFiles(header.Filename, data, maxDecompressedSize)
+	if err != nil {
+		h.metrics.parseFailed(err)
+		h.respondAPIError(w, archiveError(err))
+		return
+	}
+
+	summary, err := h.batchRunner.Run(r.Context(), files, options)
+	if err != nil {
+		h.respondDiagnosisError(w, err)
+		return
+	}
+	h.metrics.batchDone(summary)
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="batch-summary.csv"`)
+		summary.WriteCSV(w)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, summary)
+}
@@ -1,48 +1,92 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/google/uuid"
 	"github.com/positiondoctor/backend/internal/algorithm"
+	"github.com/positiondoctor/backend/internal/batch"
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/jobs"
 	"github.com/positiondoctor/backend/internal/model"
 	"github.com/positiondoctor/backend/internal/parser"
+	"github.com/positiondoctor/backend/internal/store"
 )
 
 const (
-	maxFileSize = 50 * 1024 * 1024 // 50MB
+	maxFileSize         = 50 * 1024 * 1024                  // 50MB
+	maxDecompressedSize = parser.DefaultMaxDecompressedSize // guards against zip bombs
 )
 
 // Handler handles HTTP requests
 type Handler struct {
 	parserFactory *parser.ParserFactory
+	registry      *algorithm.Registry
+	service       *diagnosis.Service
+	batchRunner   *batch.Runner
+	jobQueue      *jobs.Queue
+	live          *liveSessions
+	reports       store.ReportStore
+	metrics       *serverMetrics
 	startTime     time.Time
 }
 
-// NewHandler creates a new API handler
+// NewHandler creates a new API handler using the default step registry
 func NewHandler() *Handler {
-	return &Handler{
+	return NewHandlerWithRegistry(algorithm.DefaultRegistry())
+}
+
+// NewHandlerWithRegistry creates a new API handler whose pipelines draw
+// correction steps from registry
+func NewHandlerWithRegistry(registry *algorithm.Registry) *Handler {
+	return NewHandlerWithStore(registry, defaultReports)
+}
+
+// NewHandlerWithStore creates a new API handler that keeps reports in
+// reports for GET /reports/{id} and exports
+func NewHandlerWithStore(registry *algorithm.Registry, reports store.ReportStore) *Handler {
+	service := diagnosis.NewServiceWithRegistry(registry)
+	h := &Handler{
 		parserFactory: parser.NewParserFactory(),
+		registry:      registry,
+		service:       service,
+		batchRunner:   batch.NewRunner(service),
+		jobQueue:      jobs.NewQueue(runtime.NumCPU(), maxQueuedJobs),
+		live:          newLiveSessions(),
+		reports:       reports,
 		startTime:     time.Now(),
 	}
+	h.metrics = newServerMetrics(h)
+	return h
 }
 
 // RegisterRoutes registers all API routes
 func (h *Handler) RegisterRoutes(r chi.Router) {
 	r.Post("/diagnose", h.Diagnose)          // Legacy: multipart/form-data file upload
 	r.Post("/diagnose/points", h.DiagnosePoints) // New: JSON binary array format
+	r.Post("/diagnose/stream", h.DiagnoseStream) // Server-sent progress events
+	r.Post("/batch", h.Batch)                    // Archive of tracks, one summary
+	r.Post("/jobs", h.CreateJob)                 // Async diagnosis: upload or points
+	r.Get("/jobs/{jobID}", h.GetJob)
+	r.Delete("/jobs/{jobID}", h.CancelJob)
+	r.Get("/live", h.Live)                       // WebSocket: live track diagnosis
+	r.Get("/reports/{reportID}", h.GetReport)     // Stored diagnosis, until it expires
+	r.Delete("/reports/{reportID}", h.DeleteReport)
 	r.Get("/health", h.Health)
 	r.Head("/health", h.HealthHead)
 	r.Get("/metrics", h.Metrics)
+	r.Get("/algorithms", h.Algorithms)
 	r.Get("/export/{reportID}/{format}", h.Export)
 }
 
@@ -50,162 +94,366 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 func (h *Handler) Diagnose(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	files, options, apiErr := h.readUpload(r)
+	if apiErr != nil {
+		h.respondAPIError(w, apiErr)
+		return
+	}
+
+	response, err := h.diagnoseFiles(r.Context(), files, options, startTime, nil)
+	if err != nil {
+		h.respondDiagnosisError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, response)
+}
+
+// readUpload reads a multipart track upload and its options, unwrapping
+// gzip/zip uploads and parsing every track they hold
+func (h *Handler) readUpload(r *http.Request) ([]parser.ParsedFile, model.DiagnoseRequest, *apiError) {
+	var options model.DiagnoseRequest
+
 	// Parse multipart form
 	if err := r.ParseMultipartForm(maxFileSize); err != nil {
-		h.respondError(w, http.StatusBadRequest, "invalid_request", "Failed to parse form data", nil)
-		return
+		return nil, options, newAPIError(http.StatusBadRequest, "invalid_request", "Failed to parse form data", nil)
 	}
 
 	// Get file
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		h.respondError(w, http.StatusBadRequest, "invalid_request", "File is required", nil)
-		return
+		return nil, options, newAPIError(http.StatusBadRequest, "invalid_request", "File is required", nil)
 	}
 	defer file.Close()
 
 	// Validate file size
 	if header.Size > maxFileSize {
-		h.respondError(w, http.StatusRequestEntityTooLarge, "file_too_large",
+		return nil, options, newAPIError(http.StatusRequestEntityTooLarge, "file_too_large",
 			fmt.Sprintf("File size exceeds maximum allowed size of %dMB", maxFileSize/(1024*1024)),
 			&model.ErrorDetails{
 				MaxSizeBytes: maxFileSize,
 				ReceivedSize: header.Size,
 			})
-		return
 	}
 
-	// Validate file format
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if ext != ".gpx" && ext != ".kml" {
-		receivedFormat := ""
-		if len(ext) > 1 {
-			receivedFormat = ext[1:]
+	// Parse options
+	options = h.parseOptions(r)
+	if err := h.service.Validate(options); err != nil {
+		return nil, options, diagnosisError(err)
+	}
+
+	// Parse optional CSV column mapping
+	var csvMapping *parser.CSVMapping
+	if mappingStr := r.FormValue("csvMapping"); mappingStr != "" {
+		csvMapping = &parser.CSVMapping{}
+		if err := json.Unmarshal([]byte(mappingStr), csvMapping); err != nil {
+			return nil, options, newAPIError(http.StatusBadRequest, "invalid_request",
+				"Invalid CSV column mapping: "+err.Error(),
+				&model.ErrorDetails{Field: "csvMapping"})
 		}
-		h.respondError(w, http.StatusBadRequest, "invalid_request",
-			"Invalid file format. Only KML and GPX are supported.",
-			&model.ErrorDetails{
-				Field:           "file",
-				ExpectedFormats: []string{"kml", "gpx"},
-				ReceivedFormat:  receivedFormat,
-			})
-		return
 	}
 
-	// Parse options
-	options := h.parseOptions(r)
-
 	// Read file content
 	data, err := io.ReadAll(file)
 	if err != nil {
-		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to read file", nil)
-		return
+		return nil, options, newAPIError(http.StatusInternalServerError, "internal_error", "Failed to read file", nil)
+	}
+
+	factory := h.parserFactory
+	if csvMapping != nil {
+		factory = parser.NewParserFactoryWithCSVMapping(*csvMapping)
 	}
 
-	// Parse trajectory file
-	points, err := h.parserFactory.ParseFile(header.Filename, data)
+	// Unwrap gzip/zip uploads and parse every track they hold
+	files, err := factory.ParseArchive(header.Filename, data, maxDecompressedSize)
 	if err != nil {
-		// Provide user-friendly error messages
-		errorMsg := err.Error()
-		userMsg := "Failed to parse trajectory file"
-
-		switch {
-		case strings.Contains(errorMsg, "empty"):
-			userMsg = "文件为空，请检查上传的文件"
-		case strings.Contains(errorMsg, "too small"):
-			userMsg = "文件太小，可能不是有效的轨迹文件"
-		case strings.Contains(errorMsg, "not a GPX") || strings.Contains(errorMsg, "not a KML"):
-			userMsg = "文件格式错误，请确保上传的是有效的 GPX 或 KML 文件"
-		case strings.Contains(errorMsg, "invalid GPX format") || strings.Contains(errorMsg, "invalid KML format"):
-			userMsg = "文件格式损坏，请检查文件是否完整"
-		case strings.Contains(errorMsg, "no tracks") || strings.Contains(errorMsg, "no documents"):
-			userMsg = "文件中没有找到轨迹数据"
-		case strings.Contains(errorMsg, "no track points") || strings.Contains(errorMsg, "no coordinate"):
-			userMsg = "文件中没有有效的GPS坐标点"
-		case strings.Contains(errorMsg, "invalid XML"):
-			userMsg = "XML格式错误，文件可能已损坏"
-		default:
-			userMsg = fmt.Sprintf("解析失败: %s", errorMsg)
+		h.metrics.parseFailed(err)
+		return nil, options, archiveError(err)
+	}
+	for _, f := range files {
+		if f.Err != nil {
+			h.metrics.parseFailed(f.Err)
 		}
+	}
+
+	return files, options, nil
+}
 
-		h.respondError(w, http.StatusBadRequest, "invalid_file", userMsg, &model.ErrorDetails{
-			Field: "file",
+// archiveError converts an error unpacking an upload to an API error
+func archiveError(err error) *apiError {
+	if errors.Is(err, parser.ErrArchiveTooLarge) {
+		return newAPIError(http.StatusRequestEntityTooLarge, "file_too_large",
+			fmt.Sprintf("Decompressed size exceeds maximum allowed size of %dMB", maxDecompressedSize/(1024*1024)),
+			&model.ErrorDetails{
+				Field:        "file",
+				MaxSizeBytes: maxDecompressedSize,
+			})
+	}
+	return newAPIError(http.StatusBadRequest, "invalid_file", userParseError(err), &model.ErrorDetails{
+		Field: "file",
+	})
+}
+
+// diagnoseFiles diagnoses parsed upload files. A single track keeps the
+// original response shape; archives with several tracks get one diagnosis
+// per track in Files and no top-level Data. observe may be nil.
+func (h *Handler) diagnoseFiles(ctx context.Context, files []parser.ParsedFile, options model.DiagnoseRequest,
+	startTime time.Time, observe diagnosis.Observer) (*model.DiagnoseResponse, error) {
+	// A single track keeps the original response shape
+	if len(files) == 1 {
+		if files[0].Err != nil {
+			return nil, parseError(files[0])
+		}
+		result, err := h.diagnose(ctx, files[0].Points, options, observe)
+		if err != nil {
+			return nil, err
+		}
+		response := h.buildDiagnoseResponse(result, options, startTime)
+		return &response, nil
+	}
+
+	// Archives with several tracks get one diagnosis per track
+	results := make([]model.FileDiagnosis, len(files))
+	succeeded := false
+	for i, f := range files {
+		results[i] = model.FileDiagnosis{Name: f.Name, Format: f.Format}
+		if f.Err != nil {
+			results[i].Error = userParseError(f.Err)
+			continue
+		}
+		data, err := h.diagnose(ctx, f.Points, options, fileObserver(observe, f.Name, i, len(files)))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			results[i].Error = "轨迹修正失败"
+			continue
+		}
+		results[i].Data = data
+		if !options.Output.IncludePoints {
+			results[i].Data.Points = nil
+		}
+		succeeded = true
+	}
+
+	if !succeeded {
+		return nil, newAPIError(http.StatusBadRequest, "invalid_file", "压缩包中没有可解析的轨迹文件", &model.ErrorDetails{
+			Field:   "file",
+			Message: results[0].Error,
 		})
-		return
 	}
 
-	// Post-process points
-	points = parser.PostProcess(points)
-
-	// Store original stats
-	originalStats := model.CalculateStats(points)
-
-	// Run diagnostics
-	detector := algorithm.NewDetector()
-	detector.MaxSpeed = options.Thresholds.MaxSpeed
-	detector.MaxAcceleration = options.Thresholds.MaxAcceleration
-	detector.MaxJump = options.Thresholds.MaxJump
-
-	anomalies := detector.DetectAll(points)
-
-	// Apply corrections with statistics
-	correctedPoints, correctionStats := h.applyCorrectionsWithStats(points, anomalies, options)
-
-	// Calculate corrected stats
-	correctedStats := model.CalculateStats(correctedPoints)
-
-	// Calculate health score
-	scorer := algorithm.NewHealthScorer()
-	healthScore := scorer.Calculate(correctedPoints, anomalies)
-
-	// Build algorithm info with real statistics
-	algorithmInfo := h.buildAlgorithmInfoWithStats(points, correctedPoints, options, correctionStats)
-
-	// Build diagnostics info with new fields
-	diagnostics := model.DiagnosticsInfo{
-		NormalPoints:       h.countNormalPoints(correctedPoints),
-		AnomalyPoints:      h.countAnomalyPoints(correctedPoints),
-		FixedPoints:        correctionStats.OutlierRemovedCount,  // 删除的异常点
-		RemovedPoints:      correctionStats.SimplifiedCount,      // 简化删除的点
-		TotalProcessed:     correctionStats.OutlierRemovedCount + correctionStats.SimplifiedCount,
-		InterpolatedPoints: correctionStats.InterpolatedCount,    // 新增字段：插值生成的点
-		Anomalies:          anomalies,
-		Algorithms:         algorithmInfo,
-		HealthScore:        healthScore,
-	}
-
-	// Build response
-	reportID := uuid.New().String()
-	response := h.buildDiagnoseResponse(
-		reportID,
-		originalStats,
-		correctedStats,
-		diagnostics,
-		correctedPoints,
-		options,
-		startTime,
-	)
+	// Top-level data stays empty: no single diagnosis describes the archive
+	response := h.buildDiagnoseResponse(nil, options, startTime)
+	response.Files = results
+	return &response, nil
+}
 
-	// Store result for export (with TTL)
-	StoreResult(reportID, correctedPoints)
+// fileObserver labels the events of the i-th of n tracks with its name and
+// scales their progress to the whole upload
+func fileObserver(observe diagnosis.Observer, name string, i, n int) diagnosis.Observer {
+	if observe == nil {
+		return nil
+	}
+	return func(e diagnosis.Event) {
+		e.File = name
+		e.Progress = (float64(i) + e.Progress) / float64(n)
+		observe(e)
+	}
+}
 
-	h.respondJSON(w, http.StatusOK, response)
+// parseError returns the error for a track file that failed to parse
+func parseError(f parser.ParsedFile) *apiError {
+	if errors.Is(f.Err, parser.ErrUnsupportedFormat) {
+		return newAPIError(http.StatusBadRequest, "invalid_request",
+			"Invalid file format. Supported formats: "+strings.Join(parser.SupportedFormats(), ", "),
+			&model.ErrorDetails{
+				Field:           "file",
+				ExpectedFormats: parser.SupportedFormats(),
+				ReceivedFormat:  f.Format,
+			})
+	}
+
+	return newAPIError(http.StatusBadRequest, "invalid_file", userParseError(f.Err), &model.ErrorDetails{
+		Field: "file",
+	})
+}
+
+// Parse failure classes, counted by the parse failures metric
+const (
+	parseUnsupportedFormat = "unsupported_format"
+	parseEmpty             = "empty"
+	parseTooSmall          = "too_small"
+	parseWrongFormat       = "wrong_format"
+	parseCorruptFile       = "corrupt_file"
+	parseCorruptArchive    = "corrupt_archive"
+	parseArchiveTooLarge   = "archive_too_large"
+	parseNoTrackFiles      = "no_track_files"
+	parseNoTracks          = "no_tracks"
+	parseNoPoints          = "no_points"
+	parseCSVMapping        = "csv_mapping"
+	parseInvalidXML        = "invalid_xml"
+	parseOther             = "other"
+)
+
+// parseErrorClass classifies a parser error
+func parseErrorClass(err error) string {
+	errorMsg := err.Error()
+
+	switch {
+	case errors.Is(err, parser.ErrUnsupportedFormat):
+		return parseUnsupportedFormat
+	case errors.Is(err, parser.ErrArchiveTooLarge):
+		return parseArchiveTooLarge
+	case strings.Contains(errorMsg, "empty"):
+		return parseEmpty
+	case strings.Contains(errorMsg, "too small"):
+		return parseTooSmall
+	case strings.Contains(errorMsg, "not a GPX") || strings.Contains(errorMsg, "not a KML") ||
+		strings.Contains(errorMsg, "not an NMEA") || strings.Contains(errorMsg, "not a FIT") ||
+		strings.Contains(errorMsg, "not a TCX") || strings.Contains(errorMsg, "not a CSV") ||
+		strings.Contains(errorMsg, "not a GeoJSON"):
+		return parseWrongFormat
+	case strings.Contains(errorMsg, "invalid GPX format") || strings.Contains(errorMsg, "invalid KML format") ||
+		strings.Contains(errorMsg, "invalid FIT format") || strings.Contains(errorMsg, "invalid TCX format") ||
+		strings.Contains(errorMsg, "invalid CSV format") || strings.Contains(errorMsg, "invalid GeoJSON format"):
+		return parseCorruptFile
+	case strings.Contains(errorMsg, "invalid gzip format") || strings.Contains(errorMsg, "invalid zip format"):
+		return parseCorruptArchive
+	case strings.Contains(errorMsg, "no track files"):
+		return parseNoTrackFiles
+	case strings.Contains(errorMsg, "no tracks") || strings.Contains(errorMsg, "no documents"):
+		return parseNoTracks
+	case strings.Contains(errorMsg, "no track points") || strings.Contains(errorMsg, "no coordinate") ||
+		strings.Contains(errorMsg, "no GGA or RMC") || strings.Contains(errorMsg, "no valid fixes"):
+		return parseNoPoints
+	case strings.Contains(errorMsg, "invalid CSV mapping"):
+		return parseCSVMapping
+	case strings.Contains(errorMsg, "invalid XML"):
+		return parseInvalidXML
+	default:
+		return parseOther
+	}
+}
+
+// userParseError converts a parser error to a user-friendly message
+func userParseError(err error) string {
+	switch parseErrorClass(err) {
+	case parseUnsupportedFormat:
+		return "不支持的文件格式，支持的格式: " + strings.Join(parser.SupportedFormats(), ", ")
+	case parseEmpty:
+		return "文件为空，请检查上传的文件"
+	case parseTooSmall:
+		return "文件太小，可能不是有效的轨迹文件"
+	case parseWrongFormat:
+		return "文件格式错误，请确保上传的是有效的 GPX、KML、TCX、NMEA、FIT、CSV 或 GeoJSON 文件"
+	case parseCorruptFile:
+		return "文件格式损坏，请检查文件是否完整"
+	case parseCorruptArchive:
+		return "压缩文件已损坏，请检查文件是否完整"
+	case parseNoTrackFiles:
+		return "压缩包中没有找到轨迹文件"
+	case parseNoTracks:
+		return "文件中没有找到轨迹数据"
+	case parseNoPoints:
+		return "文件中没有有效的GPS坐标点"
+	case parseCSVMapping:
+		return fmt.Sprintf("CSV列映射错误: %s", err.Error())
+	case parseInvalidXML:
+		return "XML格式错误，文件可能已损坏"
+	default:
+		return fmt.Sprintf("解析失败: %s", err.Error())
+	}
+}
+
+// diagnose runs the diagnosis service and stores the report for retrieval
+// and export. observe may be nil.
+func (h *Handler) diagnose(ctx context.Context, points []model.Point, options model.DiagnoseRequest, observe diagnosis.Observer) (*model.Data, error) {
+	result, err := h.service.DiagnoseWithProgress(ctx, points, options, h.metrics.observer(observe))
+	if err != nil {
+		return nil, err
+	}
+	h.metrics.points.Add(float64(len(points)), sourceDiagnose)
+
+	// A report that cannot be stored only costs the client its export link
+	data := result.Data()
+	if err := h.reports.Save(data); err != nil {
+		log.Printf("Failed to store report %s: %v", data.ReportID, err)
+	}
+
+	return data, nil
+}
+
+// apiError is an error with its HTTP response
+type apiError struct {
+	status  int
+	code    string
+	message string
+	details *model.ErrorDetails
+}
+
+func newAPIError(status int, code, message string, details *model.ErrorDetails) *apiError {
+	return &apiError{status: status, code: code, message: message, details: details}
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// diagnosisError maps diagnosis service errors to API errors
+func diagnosisError(err error) *apiError {
+	var apiErr *apiError
+	var optErr *diagnosis.OptionsError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &optErr):
+		return newAPIError(http.StatusBadRequest, "invalid_parameters", "算法参数或步骤无效", optErr.Details)
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return newAPIError(http.StatusServiceUnavailable, "request_canceled", "请求已取消或超时", nil)
+	default:
+		return newAPIError(http.StatusInternalServerError, "internal_error", "Failed to correct trajectory", nil)
+	}
+}
+
+// respondDiagnosisError maps diagnosis service errors to HTTP responses
+func (h *Handler) respondDiagnosisError(w http.ResponseWriter, err error) {
+	h.respondAPIError(w, diagnosisError(err))
+}
+
+// respondAPIError responds with an API error
+func (h *Handler) respondAPIError(w http.ResponseWriter, e *apiError) {
+	h.respondError(w, e.status, e.code, e.message, e.details)
 }
 
 // DiagnosePoints handles trajectory diagnosis requests using binary array format
-// Request format: {"points": [[lat, lon, time, ele?], ...], "options": {...}}
+// Request format: {"points": [[lat, lon, time, ele?, speed?, bearing?, accuracy?], ...], "options": {...}}
 func (h *Handler) DiagnosePoints(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	points, options, apiErr := h.readPoints(r)
+	if apiErr != nil {
+		h.respondAPIError(w, apiErr)
+		return
+	}
+
+	result, err := h.diagnose(r.Context(), points, options, nil)
+	if err != nil {
+		h.respondDiagnosisError(w, err)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, h.buildDiagnoseResponse(result, options, startTime))
+}
+
+// readPoints reads a binary array points request and its options
+func (h *Handler) readPoints(r *http.Request) ([]model.Point, model.DiagnoseRequest, *apiError) {
 	// Parse JSON request
 	var req model.PointsRequest
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&req); err != nil {
-		h.respondError(w, http.StatusBadRequest, "invalid_json",
+		return nil, req.Options, newAPIError(http.StatusBadRequest, "invalid_json",
 			"Failed to parse JSON: "+err.Error(), nil)
-		return
 	}
 
 	// Validate point count limits
@@ -213,145 +461,55 @@ func (h *Handler) DiagnosePoints(w http.ResponseWriter, r *http.Request) {
 	const minPoints = 2
 
 	if len(req.Points) > maxPoints {
-		h.respondError(w, http.StatusBadRequest, "too_many_points",
+		return nil, req.Options, newAPIError(http.StatusBadRequest, "too_many_points",
 			fmt.Sprintf("Maximum %d points per request", maxPoints), &model.ErrorDetails{
 				Field:  "points",
 				Limit:  maxPoints,
 				Message: fmt.Sprintf("Received %d points, maximum is %d", len(req.Points), maxPoints),
 			})
-		return
 	}
 
 	if len(req.Points) < minPoints {
-		h.respondError(w, http.StatusBadRequest, "too_few_points",
+		return nil, req.Options, newAPIError(http.StatusBadRequest, "too_few_points",
 			fmt.Sprintf("Minimum %d points required", minPoints), &model.ErrorDetails{
 				Field:   "points",
 				Limit:   minPoints,
 				Message: fmt.Sprintf("Received %d points, minimum is %d", len(req.Points), minPoints),
 			})
-		return
 	}
 
 	// Validate and convert points
 	points, validationErr := h.validateAndConvertPoints(req.Points)
 	if validationErr != nil {
-		h.respondError(w, http.StatusBadRequest, "invalid_points", validationErr.Message, validationErr)
-		return
+		return nil, req.Options, newAPIError(http.StatusBadRequest, "invalid_points", validationErr.Message, validationErr)
 	}
 
 	// Use default options if none provided or all disabled
 	if !req.Options.Algorithms.AdaptiveRTS &&
 		!req.Options.Algorithms.SplineInterpolation &&
 		!req.Options.Algorithms.Simplification &&
-		!req.Options.Algorithms.OutlierRemoval {
+		!req.Options.Algorithms.OutlierRemoval &&
+		req.Options.Steps == nil {
+		profile, parameters := req.Options.Profile, req.Options.Parameters
 		req.Options = model.DefaultRequest()
+		req.Options.Profile, req.Options.Parameters = profile, parameters
 	}
 
-	// Post-process points (same as file-based handler)
-	points = parser.PostProcess(points)
-
-	// Store original stats
-	originalStats := model.CalculateStats(points)
-
-	// Run diagnostics
-	detector := algorithm.NewDetector()
-	detector.MaxSpeed = req.Options.Thresholds.MaxSpeed
-	detector.MaxAcceleration = req.Options.Thresholds.MaxAcceleration
-	detector.MaxJump = req.Options.Thresholds.MaxJump
-
-	anomalies := detector.DetectAll(points)
-
-	// Apply corrections with statistics
-	correctedPoints, correctionStats := h.applyCorrectionsWithStats(points, anomalies, req.Options)
-
-	// Calculate corrected stats
-	correctedStats := model.CalculateStats(correctedPoints)
-
-	// Calculate health score
-	scorer := algorithm.NewHealthScorer()
-	healthScore := scorer.Calculate(correctedPoints, anomalies)
-
-	// Build algorithm info with real statistics
-	algorithmInfo := h.buildAlgorithmInfoWithStats(points, correctedPoints, req.Options, correctionStats)
-
-	// Build diagnostics info with new fields
-	diagnostics := model.DiagnosticsInfo{
-		NormalPoints:       h.countNormalPoints(correctedPoints),
-		AnomalyPoints:      h.countAnomalyPoints(correctedPoints),
-		FixedPoints:        correctionStats.OutlierRemovedCount,
-		RemovedPoints:      correctionStats.SimplifiedCount,
-		TotalProcessed:     correctionStats.OutlierRemovedCount + correctionStats.SimplifiedCount,
-		InterpolatedPoints: correctionStats.InterpolatedCount,
-		Anomalies:          anomalies,
-		Algorithms:         algorithmInfo,
-		HealthScore:        healthScore,
-	}
-
-	// Build response
-	reportID := uuid.New().String()
-	response := h.buildDiagnoseResponse(
-		reportID,
-		originalStats,
-		correctedStats,
-		diagnostics,
-		correctedPoints,
-		req.Options,
-		startTime,
-	)
-
-	// Store result for export (with TTL)
-	StoreResult(reportID, correctedPoints)
-
-	h.respondJSON(w, http.StatusOK, response)
+	return points, req.Options, nil
 }
 
 // validateAndConvertPoints validates and converts binary array points to model.Point
-// Input format: [[lat, lon, time, ele?], ...]
+// Input format: [[lat, lon, time, ele?, speed?, bearing?, accuracy?], ...]
 func (h *Handler) validateAndConvertPoints(rawPoints [][]float64) ([]model.Point, *model.ErrorDetails) {
 	points := make([]model.Point, 0, len(rawPoints))
 	invalidIndices := make([]int, 0)
 
-	const (
-		minTime = int64(946684800)  // 2000-01-01
-		maxTime = int64(4102444800) // 2100-01-01
-	)
-
 	for i, p := range rawPoints {
-		// Check minimum length (lat, lon, time required)
-		if len(p) < 3 {
-			invalidIndices = append(invalidIndices, i)
-			continue
-		}
-
-		lat, lon, ts := p[0], p[1], p[2]
-
-		// Validate coordinate ranges
-		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		point, ok := convertPoint(p, i)
+		if !ok {
 			invalidIndices = append(invalidIndices, i)
 			continue
 		}
-
-		// Validate timestamp (convert to int64 for comparison)
-		unixTs := int64(ts)
-		if unixTs < minTime || unixTs > maxTime {
-			invalidIndices = append(invalidIndices, i)
-			continue
-		}
-
-		// Create point with index
-		point := model.Point{
-			Index: i,
-			Lat:   lat,
-			Lon:   lon,
-			Time:  time.Unix(unixTs, 0),
-			Status: model.StatusNormal,
-		}
-
-		// Optional elevation
-		if len(p) > 3 {
-			point.Elevation = p[3]
-		}
-
 		points = append(points, point)
 	}
 
@@ -373,89 +531,52 @@ func (h *Handler) validateAndConvertPoints(rawPoints [][]float64) ([]model.Point
 	return points, nil
 }
 
-// CorrectionStats 统计各算法的处理结果
-type CorrectionStats struct {
-	InterpolatedCount int // 插值生成的点数
-	SimplifiedCount   int // 简化删除的点数
-	OutlierRemovedCount int // 离群点删除的点数
-}
-
-// applyCorrections applies correction algorithms and returns stats
-func (h *Handler) applyCorrectionsWithStats(
-	points []model.Point,
-	anomalies []model.Anomaly,
-	options model.DiagnoseRequest,
-) ([]model.Point, CorrectionStats) {
-	stats := CorrectionStats{}
-	result := make([]model.Point, len(points))
-	copy(result, points)
+// convertPoint validates one binary array point and converts it to
+// model.Point with the given index
+func convertPoint(p []float64, index int) (model.Point, bool) {
+	const (
+		minTime = int64(946684800)  // 2000-01-01
+		maxTime = int64(4102444800) // 2100-01-01
+	)
 
-	// Apply AdaptiveRTS smoothing
-	if options.Algorithms.AdaptiveRTS {
-		rts := algorithm.NewAdaptiveRTS()
-		result = rts.Smooth(result)
+	// Check minimum length (lat, lon, time required)
+	if len(p) < 3 {
+		return model.Point{}, false
 	}
 
-	// Apply spline interpolation for missing points
-	if options.Algorithms.SplineInterpolation {
-		beforeLen := len(result)
-		interpolator := algorithm.NewSplineInterpolator()
-		result = interpolator.Interpolate(result)
-		stats.InterpolatedCount = len(result) - beforeLen
-	}
+	lat, lon, ts := p[0], p[1], p[2]
 
-	// Apply simplification
-	if options.Algorithms.Simplification {
-		beforeLen := len(result)
-		dp := algorithm.NewImprovedDouglasPeucker(options.Output.SimplifyEpsilon)
-		result = dp.Simplify(result)
-		stats.SimplifiedCount = beforeLen - len(result)
+	// Validate coordinate ranges
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return model.Point{}, false
 	}
 
-	// Remove outliers
-	if options.Algorithms.OutlierRemoval {
-		beforeLen := len(result)
-		result = h.removeOutliers(result, anomalies)
-		stats.OutlierRemovedCount = beforeLen - len(result)
+	// Validate timestamp (convert to int64 for comparison)
+	unixTs := int64(ts)
+	if unixTs < minTime || unixTs > maxTime {
+		return model.Point{}, false
 	}
 
-	return result, stats
-}
-
-// applyCorrections applies correction algorithms (legacy)
-func (h *Handler) applyCorrections(
-	points []model.Point,
-	anomalies []model.Anomaly,
-	options model.DiagnoseRequest,
-) []model.Point {
-	result, _ := h.applyCorrectionsWithStats(points, anomalies, options)
-	return result
-}
-
-// removeOutliers removes outlier points
-func (h *Handler) removeOutliers(points []model.Point, anomalies []model.Anomaly) []model.Point {
-	outlierIndices := make(map[int]bool)
-
-	for _, a := range anomalies {
-		if a.Severity == model.SeverityHigh {
-			for _, idx := range a.Indices {
-				outlierIndices[idx] = true
-			}
-		}
+	point := model.Point{
+		Index:  index,
+		Lat:    lat,
+		Lon:    lon,
+		Time:   time.Unix(unixTs, 0),
+		Status: model.StatusNormal,
 	}
 
-	if len(outlierIndices) == 0 {
-		return points
+	// Optional elevation
+	if len(p) > 3 {
+		point.Elevation = p[3]
 	}
 
-	result := make([]model.Point, 0, len(points))
-	for i, p := range points {
-		if !outlierIndices[i] {
-			result = append(result, p)
-		}
+	// Optional horizontal accuracy in meters; speed and bearing are
+	// recomputed from positions
+	if len(p) > 6 && p[6] > 0 {
+		point.Accuracy = p[6]
 	}
 
-	return result
+	return point, true
 }
 
 // parseOptions parses request options
@@ -472,199 +593,6 @@ func (h *Handler) parseOptions(r *http.Request) model.DiagnoseRequest {
 	return options
 }
 
-// buildAlgorithmInfoWithStats builds algorithm execution info using real statistics
-func (h *Handler) buildAlgorithmInfoWithStats(originalPoints []model.Point, correctedPoints []model.Point, options model.DiagnoseRequest, stats CorrectionStats) []model.AlgorithmInfo {
-	info := make([]model.AlgorithmInfo, 0)
-	originalCount := len(originalPoints)
-
-	// Collect fixed points by algorithm
-	fixedByRTS := make([]int, 0)
-	fixedBySpline := make([]int, 0)
-
-	for i, p := range correctedPoints {
-		switch p.FixedBy {
-		case "RTS平滑":
-			fixedByRTS = append(fixedByRTS, i)
-		case "样条插值":
-			fixedBySpline = append(fixedBySpline, i)
-		}
-	}
-
-	// Add RTS info if it was applied
-	if options.Algorithms.AdaptiveRTS {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "自适应RTS平滑器",
-			Description:     "基于Rauch-Tung-Striebel算法的双向卡尔曼滤波平滑，自动估计测量噪声",
-			ProcessedPoints: originalCount,
-			FixedPoints:     len(fixedByRTS),
-			FixedIndices:    fixedByRTS,
-			Parameters: map[string]interface{}{
-				"initQ": 1e-3,
-				"initR": 1e-1,
-				"vbAlpha": 1.0,
-				"vbBeta": 10.0,
-				"method": "变分贝叶斯估计",
-			},
-		})
-	}
-
-	// Add Spline Interpolation info
-	if options.Algorithms.SplineInterpolation {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "三次样条插值器",
-			Description:     "使用三次样条曲线在缺失点之间进行平滑插值",
-			ProcessedPoints: originalCount,
-			FixedPoints:     len(fixedBySpline),
-			RemovedPoints:   0, // 插值是新增点，不是删除
-			Parameters: map[string]interface{}{
-				"method":        "cubic",
-				"maxGapSeconds": 60,
-				"tension":       0.5,
-				"addedPoints":   stats.InterpolatedCount, // 实际新增的点数
-			},
-		})
-	}
-
-	// Add Douglas-Peucker info with real statistics
-	if options.Algorithms.Simplification {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "Douglas-Peucker简化器",
-			Description:     "保留关键特征点的同时简化轨迹，减少数据冗余",
-			ProcessedPoints: originalCount,
-			FixedPoints:     0,
-			RemovedPoints:   stats.SimplifiedCount, // 真实删除的点数
-			Parameters: map[string]interface{}{
-				"epsilon":        options.Output.SimplifyEpsilon,
-				"considerNoise": true,
-			},
-		})
-	}
-
-	// Add Outlier Removal info with real statistics
-	if options.Algorithms.OutlierRemoval {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "离群点移除器",
-			Description:     "移除高严重性的异常点（GPS漂移、跳跃等）",
-			ProcessedPoints: originalCount,
-			FixedPoints:     0,
-			RemovedPoints:   stats.OutlierRemovedCount, // 真实删除的点数
-			Parameters: map[string]interface{}{
-				"method":    "severity_high",
-				"threshold": "仅移除高严重性异常",
-			},
-		})
-	}
-
-	return info
-}
-
-// buildAlgorithmInfo builds algorithm execution info with actual statistics (legacy)
-func (h *Handler) buildAlgorithmInfo(originalPoints []model.Point, correctedPoints []model.Point, options model.DiagnoseRequest, removedCount int, fixedCount int) []model.AlgorithmInfo {
-	info := make([]model.AlgorithmInfo, 0)
-	originalCount := len(originalPoints)
-	_ = len(correctedPoints) // 用于未来扩展
-
-	// Collect fixed points by algorithm
-	fixedByRTS := make([]int, 0)
-	fixedBySpline := make([]int, 0)
-
-	for i, p := range correctedPoints {
-		switch p.FixedBy {
-		case "RTS平滑":
-			fixedByRTS = append(fixedByRTS, i)
-		case "样条插值":
-			fixedBySpline = append(fixedBySpline, i)
-		}
-	}
-
-	// Calculate removed points for each algorithm
-	// Since we can't track exact removal per algorithm without deeper changes,
-	// we use the proportion based on algorithm settings
-	dpRemoved := 0
-	outlierRemoved := 0
-
-	if removedCount > 0 {
-		hasDP := options.Algorithms.Simplification
-		hasOutlier := options.Algorithms.OutlierRemoval
-
-		if hasDP && hasOutlier {
-			// Both enabled - split evenly
-			dpRemoved = removedCount / 2
-			outlierRemoved = removedCount - dpRemoved
-		} else if hasDP {
-			dpRemoved = removedCount
-		} else if hasOutlier {
-			outlierRemoved = removedCount
-		}
-	}
-
-	// Add RTS info if it was applied
-	if options.Algorithms.AdaptiveRTS {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "自适应RTS平滑器",
-			Description:     "基于Rauch-Tung-Striebel算法的双向卡尔曼滤波平滑，自动估计测量噪声",
-			ProcessedPoints: originalCount,
-			FixedPoints:     len(fixedByRTS),
-			FixedIndices:    fixedByRTS,
-			Parameters: map[string]interface{}{
-				"initQ": 1e-3,
-				"initR": 1e-1,
-				"vbAlpha": 1.0,
-				"vbBeta": 10.0,
-				"method": "变分贝叶斯估计",
-			},
-		})
-	}
-
-	// Add Spline Interpolation info
-	if options.Algorithms.SplineInterpolation {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "三次样条插值器",
-			Description:     "使用三次样条曲线在缺失点之间进行平滑插值",
-			ProcessedPoints: originalCount,
-			FixedPoints:     len(fixedBySpline),
-			FixedIndices:    fixedBySpline,
-			Parameters: map[string]interface{}{
-				"method":        "cubic",
-				"maxGapSeconds": 60,
-				"tension":       0.5,
-			},
-		})
-	}
-
-	// Add Douglas-Peucker info
-	if options.Algorithms.Simplification {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "Douglas-Peucker简化器",
-			Description:     "保留关键特征点的同时简化轨迹，减少数据冗余",
-			ProcessedPoints: originalCount,
-			FixedPoints:     0,
-			RemovedPoints:   dpRemoved,
-			Parameters: map[string]interface{}{
-				"epsilon":        options.Output.SimplifyEpsilon,
-				"considerNoise": true,
-			},
-		})
-	}
-
-	// Add Outlier Removal info
-	if options.Algorithms.OutlierRemoval {
-		info = append(info, model.AlgorithmInfo{
-			Name:            "离群点移除器",
-			Description:     "基于统计方法识别并移除轨迹中的离群点",
-			ProcessedPoints: originalCount,
-			FixedPoints:     0,
-			RemovedPoints:   outlierRemoved,
-			Parameters: map[string]interface{}{
-				"method":   "MAD",
-				"threshold": 3,
-			},
-		})
-	}
-
-	return info
-}
-
 // countFixedPoints counts points that were fixed
 func (h *Handler) countFixedPoints(points []model.Point) int {
 	count := 0
@@ -690,47 +618,15 @@ func (h *Handler) countFixedAnomalyPoints(points []model.Point) int {
 	return count
 }
 
-// countNormalPoints counts normal status points
-func (h *Handler) countNormalPoints(points []model.Point) int {
-	count := 0
-	for _, p := range points {
-		if p.Status == model.StatusNormal {
-			count++
-		}
-	}
-	return count
-}
-
-// countAnomalyPoints counts anomaly status points
-func (h *Handler) countAnomalyPoints(points []model.Point) int {
-	count := 0
-	for _, p := range points {
-		if p.Status != model.StatusNormal && p.Status != model.StatusInterpolated {
-			count++
-		}
-	}
-	return count
-}
-
 // buildDiagnoseResponse builds the diagnose response
 func (h *Handler) buildDiagnoseResponse(
-	reportID string,
-	original, corrected model.TrajectoryStats,
-	diagnostics model.DiagnosticsInfo,
-	points []model.Point,
+	data *model.Data,
 	options model.DiagnoseRequest,
 	startTime time.Time,
 ) model.DiagnoseResponse {
-	data := &model.Data{
-		ReportID:   reportID,
-		Original:   original,
-		Corrected:  corrected,
-		Diagnostics: diagnostics,
-	}
-
 	// Include points if requested
-	if options.Output.IncludePoints {
-		data.Points = points
+	if data != nil && !options.Output.IncludePoints {
+		data.Points = nil
 	}
 
 	// Calculate processing time
@@ -763,23 +659,29 @@ func (h *Handler) HealthHead(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// Metrics handles metrics requests
-func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"requests": map[string]interface{}{
-			"total":   0,
-			"last24h": 0,
-		},
-		"files": map[string]interface{}{
-			"total":            0,
-			"totalPoints":      0,
-			"avgPointsPerFile": 0,
-		},
-		"rateLimit": map[string]interface{}{
-			"currentUsage": 0,
-			"limit":       10,
+// Algorithms lists the registered correction steps and their parameters
+func (h *Handler) Algorithms(w http.ResponseWriter, r *http.Request) {
+	steps := h.registry.Steps()
+	response := model.AlgorithmsResponse{
+		Steps: make([]model.StepInfo, len(steps)),
+		DefaultOrder: []string{
+			model.StepAdaptiveRTS,
+			model.StepSplineInterpolation,
+			model.StepSimplification,
+			model.StepOutlierRemoval,
 		},
-	})
+	}
+	for i, step := range steps {
+		response.Steps[i] = model.StepInfo{Name: step.Name(), Parameters: step.Parameters()}
+		if d, ok := step.(algorithm.Describer); ok {
+			response.Steps[i].DisplayName = d.DisplayName()
+			response.Steps[i].Description = d.Description()
+		}
+		if response.Steps[i].Parameters == nil {
+			response.Steps[i].Parameters = []model.ParameterSpec{}
+		}
+	}
+	respondJSON(w, http.StatusOK, response)
 }
 
 // respondJSON responds with JSON
@@ -825,6 +727,11 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 
 // SetupRouter sets up the API router
 func SetupRouter() *chi.Mux {
+	return NewRouter(NewHandler())
+}
+
+// NewRouter sets up the API router serving handler
+func NewRouter(handler *Handler) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Add chi middleware
@@ -833,22 +740,25 @@ func SetupRouter() *chi.Mux {
 	r.Use(middleware.AllowContentType("multipart/form-data", "application/json"))
 	r.Use(middleware.NoCache)
 	r.Use(middleware.Heartbeat("/ping"))
+	r.Use(handler.metrics.instrument)
 
 	// Add custom rate limiting
-	r.Use(rateLimitMiddleware(10, 20))
+	r.Use(rateLimitMiddleware(rateLimitPerMinute, rateLimitBurst, func() {
+		handler.metrics.rateLimited.Inc()
+	}))
 
 	// Register API routes with /api/v1 prefix
 	r.Route("/api/v1", func(r chi.Router) {
-		handler := NewHandler()
 		handler.RegisterRoutes(r)
 	})
 
 	return r
 }
 
-// rateLimitMiddleware creates rate limiting middleware
-func rateLimitMiddleware(rate, burst int) func(http.Handler) http.Handler {
-	limiter := rateMiddleware{rate: rate, burst: burst}
+// rateLimitMiddleware creates rate limiting middleware; rejected, which may
+// be nil, is called for every request turned away
+func rateLimitMiddleware(rate, burst int, rejected func()) func(http.Handler) http.Handler {
+	limiter := rateMiddleware{rate: rate, burst: burst, rejected: rejected}
 	return limiter.Middleware
 }
 
@@ -858,6 +768,7 @@ type rateMiddleware struct {
 	burst    int
 	tokens   map[string]int
 	lastTime map[string]time.Time
+	rejected func()
 	mu       sync.RWMutex
 }
 
@@ -894,6 +805,9 @@ func (rm *rateMiddleware) Middleware(next http.Handler) http.Handler {
 		if currentTokens <= 0 {
 			rm.lastTime[key] = now
 			rm.mu.Unlock()
+			if rm.rejected != nil {
+				rm.rejected()
+			}
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rm.rate))
 			w.Header().Set("Retry-After", "30")
@@ -920,27 +834,24 @@ func getClientIP(r *http.Request) string {
 	return r.RemoteAddr
 }
 
-// In-memory storage for export results (in production, use Redis or database)
-var (
-	resultStore = struct {
-		sync.RWMutex
-		data map[string][]model.Point
-	}{data: make(map[string][]model.Point)}
-)
+// defaultReports is the in-memory report store of handlers created without
+// one
+var defaultReports = store.NewMemoryStore()
 
-// StoreResult stores result data for export
+// StoreResult stores corrected points for export in the report store of
+// handlers created by NewHandler
 func StoreResult(reportID string, points []model.Point) {
-	resultStore.Lock()
-	defer resultStore.Unlock()
-	resultStore.data[reportID] = points
+	defaultReports.Save(&model.Data{ReportID: reportID, Points: points})
 }
 
-// GetResult retrieves result data for export
+// GetResult retrieves the points of a report stored by handlers created by
+// NewHandler
 func GetResult(reportID string) ([]model.Point, bool) {
-	resultStore.RLock()
-	defer resultStore.RUnlock()
-	points, ok := resultStore.data[reportID]
-	return points, ok
+	report, err := defaultReports.Load(reportID)
+	if err != nil {
+		return nil, false
+	}
+	return report.Points, true
 }
 
 // Export handles trajectory export requests
@@ -954,11 +865,12 @@ func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Retrieve stored result
-	points, ok := GetResult(reportID)
-	if !ok {
-		h.respondError(w, http.StatusNotFound, "not_found", "Report not found or expired", nil)
+	report, apiErr := h.loadReport(reportID)
+	if apiErr != nil {
+		h.respondAPIError(w, apiErr)
 		return
 	}
+	points := report.Points
 
 	// Set filename
 	filename := fmt.Sprintf("position-doctor-%s.%s", reportID[:8], format)
@@ -972,6 +884,12 @@ func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
 		h.exportJSON(w, points, filename)
 	case "geojson":
 		h.exportGeoJSON(w, points, filename)
+	case "fit":
+		h.exportFIT(w, points, filename)
+	case "tcx":
+		h.exportTCX(w, points, filename)
+	case "csv":
+		h.exportCSV(w, points, filename)
 	default:
 		h.respondError(w, http.StatusBadRequest, "invalid_format", "Unsupported export format", nil)
 	}
@@ -1003,6 +921,45 @@ func (h *Handler) exportKML(w http.ResponseWriter, points []model.Point, filenam
 	w.Write(data)
 }
 
+// exportTCX exports trajectory as TCX with laps preserved
+func (h *Handler) exportTCX(w http.ResponseWriter, points []model.Point, filename string) {
+	data, err := parser.ToTCX(points, "PositionDoctor Corrected Trajectory")
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "export_failed", err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.garmin.tcx+xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Write(data)
+}
+
+// exportFIT exports trajectory as a Garmin FIT activity
+func (h *Handler) exportFIT(w http.ResponseWriter, points []model.Point, filename string) {
+	data, err := parser.ToFIT(points, "PositionDoctor Corrected Trajectory")
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "export_failed", err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.ant.fit")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Write(data)
+}
+
+// exportCSV exports trajectory as CSV with every point field
+func (h *Handler) exportCSV(w http.ResponseWriter, points []model.Point, filename string) {
+	data, err := parser.ToCSV(points)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "export_failed", err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Write(data)
+}
+
 // exportJSON exports trajectory as JSON
 func (h *Handler) exportJSON(w http.ResponseWriter, points []model.Point, filename string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1019,33 +976,27 @@ func (h *Handler) exportJSON(w http.ResponseWriter, points []model.Point, filena
 
 // exportGeoJSON exports trajectory as GeoJSON
 func (h *Handler) exportGeoJSON(w http.ResponseWriter, points []model.Point, filename string) {
-	coordinates := make([][]float64, len(points))
-	for i, p := range points {
-		coordinates[i] = []float64{p.Lon, p.Lat}
-	}
-
-	geojson := map[string]interface{}{
-		"type": "Feature",
-		"properties": map[string]interface{}{
-			"name": "PositionDoctor Corrected Trajectory",
-		},
-		"geometry": map[string]interface{}{
-			"type":        "LineString",
-			"coordinates": coordinates,
-		},
+	data, err := parser.ToGeoJSON(points, "PositionDoctor Corrected Trajectory")
+	if err != nil {
+		http.Error(w, `{"error":"encoding_failed"}`, http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/geo+json")
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
-	if err := json.NewEncoder(w).Encode(geojson); err != nil {
-		http.Error(w, `{"error":"encoding_failed"}`, http.StatusInternalServerError)
-	}
+	w.Write(data)
 }
 
 // GetServer returns a configured HTTP server
 func GetServer(addr string) *http.Server {
+	return NewServer(addr, NewHandler())
+}
+
+// NewServer returns a configured HTTP server for handler. Call
+// handler.Shutdown as well as the server's Shutdown to drain async jobs.
+func NewServer(addr string, handler *Handler) *http.Server {
 	return &http.Server{
 		Addr:    addr,
-		Handler: SetupRouter(),
+		Handler: NewRouter(handler),
 	}
 }
@@ -1,16 +1,25 @@
 package api
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/json"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/positiondoctor/backend/internal/algorithm"
+	"github.com/positiondoctor/backend/internal/batch"
 	"github.com/positiondoctor/backend/internal/model"
 	"github.com/positiondoctor/backend/internal/parser"
+	"github.com/positiondoctor/backend/internal/store"
 )
 
 // TestHealthHandler tests the health check endpoint
@@ -62,7 +71,33 @@ func TestDiagnoseHandler_ValidGPX(t *testing.T) {
 	writer := multipart.NewWriter(body)
 	part, _ := writer.CreateFormFile("file", "test.gpx")
 	part.Write(gpxData)
-	part.Close()
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/diagnose", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+
+	handler.Diagnose(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestDiagnoseHandler_NMEA tests the diagnose endpoint with an NMEA log
+func TestDiagnoseHandler_NMEA(t *testing.T) {
+	handler := NewHandler()
+
+	nmeaData, err := os.ReadFile("../../testdata/sample.nmea")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "logger.nmea")
+	part.Write(nmeaData)
 	writer.Close()
 
 	req := httptest.NewRequest("POST", "/diagnose", body)
@@ -78,6 +113,264 @@ func TestDiagnoseHandler_ValidGPX(t *testing.T) {
 }
 
 // TestDiagnoseHandler_InvalidFormat tests with invalid file format
+func TestDiagnoseHandler_CSVMapping(t *testing.T) {
+	handler := NewHandler()
+
+	csvData := "ts;lat;lon;alt\n" +
+		"1704096000;39,9042;116,4074;50\n" +
+		"1704096005;39,9043;116,4075;51\n" +
+		"1704096010;39,9044;116,4076;52\n"
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "telemetry.csv")
+	part.Write([]byte(csvData))
+	writer.WriteField("csvMapping", `{"time":"ts","elevation":"alt","epochUnit":"s","decimalSeparator":","}`)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/diagnose", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+
+	handler.Diagnose(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// A malformed mapping is rejected before parsing
+	body = &bytes.Buffer{}
+	writer = multipart.NewWriter(body)
+	part, _ = writer.CreateFormFile("file", "telemetry.csv")
+	part.Write([]byte(csvData))
+	writer.WriteField("csvMapping", `{"lat":true}`)
+	writer.Close()
+
+	req = httptest.NewRequest("POST", "/diagnose", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w = httptest.NewRecorder()
+
+	handler.Diagnose(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid mapping, got %d", w.Code)
+	}
+}
+
+func TestDiagnosePointsHandler_Parameters(t *testing.T) {
+	handler := NewHandler()
+
+	post := func(parameters string) *httptest.ResponseRecorder {
+		body := `{"points": [[39.9042, 116.4074, 1704096000], [39.9043, 116.4075, 1704096005],
+			[39.9044, 116.4076, 1704096010], [39.9045, 116.4077, 1704096060]],
+			"options": {"algorithms": {"adaptive_rts": true, "spline_interpolation": true, "simplification": true},
+			"output": {"simplifyEpsilon": 2}, "parameters": ` + parameters + `}}`
+		req := httptest.NewRequest("POST", "/diagnose/points", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.DiagnosePoints(w, req)
+		return w
+	}
+
+	w := post(`{"adaptiveRTS": {"processNoise": 2, "vbAlpha": 5}, "splineInterpolation": {"maxPoints": 3}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var resp model.DiagnoseResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Values used are echoed, with defaults for anything unset
+	expected := map[string]map[string]interface{}{
+		"自适应RTS平滑器":          {"processNoise": 2.0, "measurementNoise": 25.0, "vbAlpha": 5.0},
+		"三次样条插值器":            {"gapSeconds": 10.0, "maxGapSeconds": 60.0, "maxPoints": 3.0},
+		"Douglas-Peucker简化器": {"epsilon": 2.0, "considerNoise": true},
+	}
+	for _, info := range resp.Data.Diagnostics.Algorithms {
+		for key, want := range expected[info.Name] {
+			if info.Parameters[key] != want {
+				t.Errorf("%s: expected %s = %v, got %v", info.Name, key, want, info.Parameters[key])
+			}
+		}
+		delete(expected, info.Name)
+	}
+	if len(expected) != 0 {
+		t.Errorf("Missing algorithm info for %v", expected)
+	}
+
+	// The spline adds at most maxPoints in the 50 s gap
+	if added := resp.Data.Diagnostics.InterpolatedPoints; added > 3 {
+		t.Errorf("Expected at most 3 interpolated points, got %d", added)
+	}
+
+	// Out-of-range values are rejected
+	for _, parameters := range []string{
+		`{"adaptiveRTS": {"processNoise": -1}}`,
+		`{"splineInterpolation": {"maxPoints": 100000}}`,
+		`{"splineInterpolation": {"gapSeconds": 120, "maxGapSeconds": 60}}`,
+	} {
+		w := post(parameters)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", parameters, w.Code)
+			continue
+		}
+		var errResp model.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &errResp)
+		if errResp.Error != "invalid_parameters" || errResp.Details == nil {
+			t.Errorf("Expected invalid_parameters with details for %s, got %s", parameters, w.Body.String())
+		}
+	}
+}
+
+// noopStep is an in-house step registered by the test
+type noopStep struct{}
+
+func (noopStep) Name() string                      { return "noop" }
+func (noopStep) Parameters() []model.ParameterSpec { return nil }
+func (noopStep) Apply(ctx context.Context, points []model.Point, params algorithm.Params, input algorithm.StepInput) ([]model.Point, algorithm.StepStats, error) {
+	return points, algorithm.StepStats{}, nil
+}
+
+func TestDiagnosePointsHandler_Steps(t *testing.T) {
+	registry := algorithm.NewRegistry()
+	registry.Register(noopStep{})
+	handler := NewHandlerWithRegistry(registry)
+
+	post := func(steps string) *httptest.ResponseRecorder {
+		body := `{"points": [[39.9042, 116.4074, 1704096000], [39.9043, 116.4075, 1704096005],
+			[39.9044, 116.4076, 1704096010]], "options": {"steps": ` + steps + `}}`
+		req := httptest.NewRequest("POST", "/diagnose/points", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.DiagnosePoints(w, req)
+		return w
+	}
+
+	w := post(`[{"name": "outlierRemoval"}, {"name": "noop"}, {"name": "simplification", "parameters": {"epsilon": 3}}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var resp model.DiagnoseResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Steps run and are reported in request order
+	algorithms := resp.Data.Diagnostics.Algorithms
+	names := []string{"离群点移除器", "noop", "Douglas-Peucker简化器"}
+	if len(algorithms) != len(names) {
+		t.Fatalf("Expected %d algorithms, got %d", len(names), len(algorithms))
+	}
+	for i, name := range names {
+		if algorithms[i].Name != name {
+			t.Errorf("Step %d: expected %s, got %s", i, name, algorithms[i].Name)
+		}
+	}
+	if algorithms[2].Parameters["epsilon"] != 3.0 {
+		t.Errorf("Expected epsilon 3, got %v", algorithms[2].Parameters["epsilon"])
+	}
+
+	w = post(`[{"name": "median"}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown step, got %d", w.Code)
+	}
+
+	// The registered steps and their schemas are listed
+	req := httptest.NewRequest("GET", "/algorithms", nil)
+	w = httptest.NewRecorder()
+	handler.Algorithms(w, req)
+
+	var list model.AlgorithmsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode algorithms: %v", err)
+	}
+	if len(list.Steps) != 5 {
+		t.Errorf("Expected 5 steps, got %d", len(list.Steps))
+	}
+}
+
+func TestDiagnoseHandler_SniffsContent(t *testing.T) {
+	handler := NewHandler()
+
+	// Mobile share sheets often drop or replace the extension
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "IMG_shared")
+	part.Write(createTestGPXWithAnomalies())
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/diagnose", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+
+	handler.Diagnose(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for GPX without extension, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDiagnoseHandler_ZipOfTracks(t *testing.T) {
+	handler := NewHandler()
+
+	nmeaData, err := os.ReadFile("../../testdata/sample.nmea")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, data := range map[string][]byte{
+		"a.gpx":      createTestGPXWithAnomalies(),
+		"b.nmea":     nmeaData,
+		"broken.gpx": []byte("<gpx"),
+	} {
+		f, _ := zw.Create(name)
+		f.Write(data)
+	}
+	zw.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "export.zip")
+	part.Write(archive.Bytes())
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/diagnose", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	w := httptest.NewRecorder()
+
+	handler.Diagnose(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var response model.DiagnoseResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response.Files) != 3 {
+		t.Fatalf("Expected 3 file results, got %d", len(response.Files))
+	}
+	// Entries are reported in name order
+	if response.Files[0].Data == nil || response.Files[1].Data == nil {
+		t.Error("Expected diagnoses for a.gpx and b.nmea")
+	}
+	if response.Files[2].Error == "" {
+		t.Error("Expected an error for broken.gpx")
+	}
+	if response.Data != nil {
+		t.Error("Expected no top-level data for an archive with several tracks")
+	}
+}
+
 func TestDiagnoseHandler_InvalidFormat(t *testing.T) {
 	handler := NewHandler()
 
@@ -85,7 +378,6 @@ func TestDiagnoseHandler_InvalidFormat(t *testing.T) {
 	writer := multipart.NewWriter(body)
 	part, _ := writer.CreateFormFile("file", "test.txt")
 	part.Write([]byte("not a valid GPX or KML file"))
-	part.Close()
 	writer.Close()
 
 	req := httptest.NewRequest("POST", "/diagnose", body)
@@ -114,7 +406,6 @@ func TestDiagnoseHandler_TooLarge(t *testing.T) {
 	writer := multipart.NewWriter(body)
 	part, _ := writer.CreateFormFile("file", "large.gpx")
 	part.Write(largeData)
-	part.Close()
 	writer.Close()
 
 	req := httptest.NewRequest("POST", "/diagnose", body)
@@ -154,7 +445,6 @@ func TestDiagnoseHandler_FullWorkflow(t *testing.T) {
 	writer := multipart.NewWriter(body)
 	part, _ := writer.CreateFormFile("file", "test.gpx")
 	part.Write(gpxData)
-	part.Close()
 
 	// Add options
 	writer.WriteField("options", `{"algorithms":{"adaptive_rts":true}}`)
@@ -174,7 +464,9 @@ func TestDiagnoseHandler_FullWorkflow(t *testing.T) {
 
 // TestExportHandler tests the export endpoint
 func TestExportHandler(t *testing.T) {
-	handler := NewHandler()
+	// Export reads its URL parameters from the chi route context
+	handler := chi.NewRouter()
+	NewHandler().RegisterRoutes(handler)
 
 	// First, store some test data
 	testPoints := []model.Point{
@@ -188,7 +480,7 @@ func TestExportHandler(t *testing.T) {
 	req := httptest.NewRequest("GET", "/export/test-report-id/gpx", nil)
 	w := httptest.NewRecorder()
 
-	handler.Export(w, req)
+	handler.ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status 200 for GPX export, got %d", w.Code)
@@ -212,7 +504,7 @@ func TestExportHandler(t *testing.T) {
 	req2 := httptest.NewRequest("GET", "/export/test-report-id/invalid", nil)
 	w2 := httptest.NewRecorder()
 
-	handler.Export(w2, req2)
+	handler.ServeHTTP(w2, req2)
 
 	if w2.Code != http.StatusBadRequest {
 		t.Errorf("Expected status 400 for invalid format, got %d", w2.Code)
@@ -222,14 +514,78 @@ func TestExportHandler(t *testing.T) {
 	req3 := httptest.NewRequest("GET", "/export/nonexistent-id/gpx", nil)
 	w3 := httptest.NewRecorder()
 
-	handler.Export(w3, req3)
+	handler.ServeHTTP(w3, req3)
 
 	if w3.Code != http.StatusNotFound {
 		t.Errorf("Expected status 404 for nonexistent report, got %d", w3.Code)
 	}
 }
 
+// TestExportHandler_FIT tests exporting a report as a FIT activity
+func TestExportHandler_FIT(t *testing.T) {
+	handler := chi.NewRouter()
+	NewHandler().RegisterRoutes(handler)
+
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	StoreResult("fit-report-id", []model.Point{
+		{Index: 0, Lat: 39.9042, Lon: 116.4074, Time: start, HeartRate: 110, Status: model.StatusNormal},
+		{Index: 1, Lat: 39.9052, Lon: 116.4084, Time: start.Add(time.Second), HeartRate: 112, Status: model.StatusNormal},
+	})
+
+	req := httptest.NewRequest("GET", "/export/fit-report-id/fit", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for FIT export, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/vnd.ant.fit" {
+		t.Errorf("Expected application/vnd.ant.fit, got %s", contentType)
+	}
+
+	points, err := parser.NewFITParser().Parse(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Exported FIT could not be parsed: %v", err)
+	}
+	if len(points) != 2 || points[1].HeartRate != 112 {
+		t.Errorf("Expected 2 points with heart rate preserved, got %+v", points)
+	}
+}
+
 // TestSetupRouter tests the router setup
+func TestExportHandler_CSV(t *testing.T) {
+	handler := chi.NewRouter()
+	NewHandler().RegisterRoutes(handler)
+
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	StoreResult("csv-report-id", []model.Point{
+		{Index: 0, Lat: 39.9042, Lon: 116.4074, Time: start, Status: model.StatusNormal},
+		{Index: 1, Lat: 39.9052, Lon: 116.4084, Time: start.Add(time.Second), Status: model.StatusJump,
+			OriginalLat: 39.95, OriginalLon: 116.45},
+	})
+
+	req := httptest.NewRequest("GET", "/export/csv-report-id/csv", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for CSV export, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got %d lines", len(lines))
+	}
+	if !strings.Contains(lines[0], "status") || !strings.Contains(lines[0], "originalLat") {
+		t.Errorf("Expected status and original coordinate columns, got %s", lines[0])
+	}
+	if !strings.Contains(lines[2], "jump") || !strings.Contains(lines[2], "39.95") {
+		t.Errorf("Expected status and original coordinates in row, got %s", lines[2])
+	}
+}
+
 func TestSetupRouter(t *testing.T) {
 	router := SetupRouter()
 
@@ -314,3 +670,385 @@ func createTestGPXWithAnomalies() []byte {
   </trk>
 </gpx>`)
 }
+
+func TestBatchHandler(t *testing.T) {
+	handler := NewHandler()
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, data := range map[string][]byte{
+		"a.gpx":      createTestGPXWithAnomalies(),
+		"b.gpx":      createTestGPXWithAnomalies(),
+		"broken.gpx": []byte("<gpx"),
+	} {
+		f, _ := zw.Create(name)
+		f.Write(data)
+	}
+	zw.Close()
+
+	upload := func(format string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "nightly.zip")
+		part.Write(archive.Bytes())
+		if format != "" {
+			writer.WriteField("format", format)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/batch", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		handler.Batch(w, req)
+		return w
+	}
+
+	w := upload("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	var summary batch.Summary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if summary.Aggregate.Files != 3 || summary.Aggregate.Succeeded != 2 || summary.Aggregate.Failed != 1 {
+		t.Errorf("Aggregate = %+v, want 3 files with 1 failure", summary.Aggregate)
+	}
+	if summary.Files[2].File != "broken.gpx" || summary.Files[2].Error == "" {
+		t.Errorf("Expected an error for broken.gpx, got %+v", summary.Files[2])
+	}
+
+	w = upload("csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Expected a CSV summary, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 4 {
+		t.Errorf("Expected header and 3 rows, got %d lines", len(lines))
+	}
+
+	if w = upload("xlsx"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestJobsHandler(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Shutdown(context.Background())
+	r := chi.NewRouter()
+	handler.RegisterRoutes(r)
+
+	body := `{"points": [[39.9042, 116.4074, 1704096000], [39.9043, 116.4075, 1704096005],
+		[39.9044, 116.4076, 1704096010], [39.9045, 116.4077, 1704096060]]}`
+	req := httptest.NewRequest("POST", "/jobs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var created model.JobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Header().Get("Location") != "/api/v1/jobs/"+created.Job.ID {
+		t.Errorf("Unexpected Location header %q", w.Header().Get("Location"))
+	}
+
+	// Poll until the job has finished
+	var polled struct {
+		Job struct {
+			Status model.JobStatus        `json:"status"`
+			Result model.DiagnoseResponse `json:"result"`
+		} `json:"job"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/"+created.Job.ID, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		json.Unmarshal(w.Body.Bytes(), &polled)
+		if polled.Job.Status.Done() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if polled.Job.Status != model.JobSucceeded {
+		t.Fatalf("Expected job to succeed, got %s", polled.Job.Status)
+	}
+	if polled.Job.Result.Data == nil || polled.Job.Result.Data.ReportID == "" {
+		t.Errorf("Expected the diagnosis in the job result")
+	}
+
+	// Finished jobs cannot be canceled, unknown jobs are not found
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/jobs/"+created.Job.ID, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"succeeded"`) {
+		t.Errorf("Expected the finished job unchanged, got %d %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	// Invalid requests are rejected before queueing
+	req = httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"points": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestDiagnoseStreamHandler(t *testing.T) {
+	handler := NewHandler()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.gpx")
+	part.Write(createTestGPXWithAnomalies())
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/diagnose/stream", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler.DiagnoseStream(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var names []string
+	var result model.DiagnoseResponse
+	for _, block := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("Malformed event %q", block)
+		}
+		name := strings.TrimPrefix(lines[0], "event: ")
+		names = append(names, name)
+		if name == "result" {
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &result); err != nil {
+				t.Fatalf("Failed to decode result: %v", err)
+			}
+		}
+	}
+
+	want := "parsed,detected,step,step,step,step,scored,result"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("Events = %s, want %s", got, want)
+	}
+	if result.Data == nil || result.Data.Diagnostics.HealthScore.Total == 0 {
+		t.Errorf("Expected the diagnosis in the result event")
+	}
+
+	// Invalid requests get a plain JSON error
+	req = httptest.NewRequest("POST", "/diagnose/stream", strings.NewReader(`{"points": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	handler.DiagnoseStream(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestLiveHandler(t *testing.T) {
+	handler := NewHandler()
+	server := httptest.NewServer(NewRouter(handler))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/live"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	read := func() model.LiveEvent {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var e model.LiveEvent
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatalf("ReadJSON() error = %v", err)
+		}
+		return e
+	}
+
+	// Options and a batch, then single points
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"options": {"thresholds": {"maxSpeed": 200}}, "points": [[39.9, 116.4, 1700000000], [39.9001, 116.4, 1700000001]]}`))
+	for i := 0; i < 2; i++ {
+		if e := read(); e.Type != model.LiveEventPosition || e.Position.Index != i {
+			t.Errorf("event %d = %+v, want its position", i, e)
+		}
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`[39.95, 116.4, 1700000002]`))
+	if e := read(); e.Type != model.LiveEventPosition || e.Position.Index != 2 || e.Position.RawLat != 39.95 {
+		t.Errorf("event = %+v, want the position of point 2", e)
+	}
+	jump := false
+	for i := 0; i < 3; i++ {
+		e := read()
+		if e.Type != model.LiveEventAnomaly {
+			t.Fatalf("event = %+v, want anomalies", e)
+		}
+		jump = jump || e.Anomaly.Type == model.AnomalyJump
+	}
+	if !jump {
+		t.Errorf("Expected a jump anomaly")
+	}
+
+	// Errors are reported without closing the connection
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"options": {}}`))
+	if e := read(); e.Type != model.LiveEventError || e.Error.Code != "invalid_options" {
+		t.Errorf("event = %+v, want invalid_options", e)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`[[39.9, 116.4, 1699999999], [91, 0, 1700000010]]`))
+	if e := read(); e.Type != model.LiveEventError || e.Error.Code != "invalid_points" {
+		t.Errorf("event = %+v, want invalid_points", e)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`[39.9, 116.4, 1699999999]`))
+	if e := read(); e.Type != model.LiveEventError || e.Error.Code != "out_of_order" {
+		t.Errorf("event = %+v, want out_of_order", e)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`not json`))
+	if e := read(); e.Type != model.LiveEventError || e.Error.Code != "invalid_json" {
+		t.Errorf("event = %+v, want invalid_json", e)
+	}
+
+	// Shutdown closes live connections
+	handler.Shutdown(context.Background())
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("ReadMessage() error = %v, want a going away close", err)
+	}
+}
+
+func TestReportsHandler(t *testing.T) {
+	reports, err := store.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	router := chi.NewRouter()
+	NewHandlerWithStore(algorithm.DefaultRegistry(), reports).RegisterRoutes(router)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Points are left out of the response but kept in the report
+	w := serve("POST", "/diagnose/points", `{"points": [[39.9042, 116.4074, 1704096000], [39.9043, 116.4075, 1704096005],
+		[39.9044, 116.4076, 1704096010]], "options": {"algorithms": {"adaptive_rts": true}, "output": {"includePoints": false}}}`)
+	var diagnosed model.DiagnoseResponse
+	json.Unmarshal(w.Body.Bytes(), &diagnosed)
+	if w.Code != http.StatusOK || diagnosed.Data == nil {
+		t.Fatalf("Expected a diagnosis, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	id := diagnosed.Data.ReportID
+
+	w = serve("GET", "/reports/"+id, "")
+	var report model.DiagnoseResponse
+	json.Unmarshal(w.Body.Bytes(), &report)
+	if w.Code != http.StatusOK || report.Data == nil {
+		t.Fatalf("Expected the report, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if report.Data.ReportID != id || len(report.Data.Points) != 3 ||
+		report.Data.Diagnostics.HealthScore.Total != diagnosed.Data.Diagnostics.HealthScore.Total {
+		t.Errorf("Report = %+v, want the stored diagnosis with its points", report.Data)
+	}
+
+	if w := serve("GET", "/export/"+id+"/gpx", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected the stored report to export, got %d", w.Code)
+	}
+
+	if w := serve("DELETE", "/reports/"+id, ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+	for _, method := range []string{"GET", "DELETE"} {
+		if w := serve(method, "/reports/"+id, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s after delete: expected status 404, got %d", method, w.Code)
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	router := NewRouter(NewHandlerWithStore(algorithm.DefaultRegistry(), store.NewMemoryStore()))
+
+	serve := func(req *http.Request, ip string) *httptest.ResponseRecorder {
+		req.Header.Set("X-Real-IP", ip)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/diagnose/points", strings.NewReader(`{"points": [[39.9042, 116.4074, 1704096000],
+		[39.9043, 116.4075, 1704096005], [39.9044, 116.4076, 1704096010]], "options": {"algorithms": {"adaptive_rts": true}}}`))
+	req.Header.Set("Content-Type", "application/json")
+	if w := serve(req, "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.txt")
+	part.Write([]byte("not a valid GPX or KML file"))
+	writer.Close()
+	req = httptest.NewRequest("POST", "/api/v1/diagnose", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if w := serve(req, "10.0.0.1"); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	// Exhaust the burst so some requests are turned away
+	for i := 0; i < 25; i++ {
+		serve(httptest.NewRequest("GET", "/api/v1/health", nil), "10.0.0.2")
+	}
+
+	w := serve(httptest.NewRequest("GET", "/api/v1/metrics", nil), "10.0.0.3")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", got)
+	}
+
+	text := w.Body.String()
+	for _, want := range []string{
+		`positiondoctor_http_requests_total{route="/api/v1/diagnose/points",method="POST",code="200"} 1`,
+		`positiondoctor_http_requests_total{route="/api/v1/diagnose",method="POST",code="400"} 1`,
+		`positiondoctor_http_request_duration_seconds_count{route="/api/v1/diagnose/points",method="POST"} 1`,
+		`positiondoctor_points_processed_total{source="diagnose"} 3`,
+		`positiondoctor_parse_failures_total{class="unsupported_format"} 1`,
+		`positiondoctor_health_score_count 1`,
+		`positiondoctor_stage_duration_seconds_count{stage="detect"} 1`,
+		`positiondoctor_stage_duration_seconds_count{stage="adaptiveRTS"} 1`,
+		`positiondoctor_stage_duration_seconds_count{stage="score"} 1`,
+		`positiondoctor_reports_stored 1`,
+		`positiondoctor_live_connections 0`,
+		`positiondoctor_rate_limit_burst 20`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Metrics missing %q", want)
+		}
+	}
+	if strings.Contains(text, "positiondoctor_rate_limited_total 0\n") {
+		t.Error("Expected rate-limited requests to be counted")
+	}
+	// Turned away before routing, they still count under their route
+	if !strings.Contains(text, `positiondoctor_http_requests_total{route="/api/v1/health",method="GET",code="429"}`) {
+		t.Error("Expected rate-limited requests counted under their route with status 429")
+	}
+	if strings.Contains(text, `route="unmatched"`) {
+		t.Error("Expected no requests counted as unmatched")
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/jobs"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+const maxQueuedJobs = 32 // jobs waiting for a worker before submissions are refused
+
+// CreateJob queues a diagnosis and responds with the job immediately. It
+// accepts the bodies of both POST /diagnose (multipart upload) and POST
+// /diagnose/points (JSON); the job's result is the response those endpoints
+// would have returned.
+func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	var fn jobs.Func
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		files, options, apiErr := h.readUpload(r)
+		if apiErr != nil {
+			h.respondAPIError(w, apiErr)
+			return
+		}
+		fn = func(ctx context.Context, progress jobs.Progress) (interface{}, error) {
+			response, err := h.diagnoseFiles(ctx, files, options, time.Now(), jobObserver(progress))
+			if err != nil {
+				return nil, jobError(err)
+			}
+			return response, nil
+		}
+	} else {
+		points, options, apiErr := h.readPoints(r)
+		if apiErr != nil {
+			h.respondAPIError(w, apiErr)
+			return
+		}
+		if err := h.service.Validate(options); err != nil {
+			h.respondDiagnosisError(w, err)
+			return
+		}
+		fn = func(ctx context.Context, progress jobs.Progress) (interface{}, error) {
+			startTime := time.Now()
+			result, err := h.diagnose(ctx, points, options, jobObserver(progress))
+			if err != nil {
+				return nil, jobError(err)
+			}
+			return h.buildDiagnoseResponse(result, options, startTime), nil
+		}
+	}
+
+	job, err := h.jobQueue.Submit(fn)
+	switch {
+	case errors.Is(err, jobs.ErrQueueFull):
+		w.Header().Set("Retry-After", "30")
+		h.respondError(w, http.StatusServiceUnavailable, "queue_full", "任务队列已满，请稍后重试",
+			&model.ErrorDetails{Limit: maxQueuedJobs, RetryAfter: 30})
+		return
+	case errors.Is(err, jobs.ErrShutdown):
+		h.respondError(w, http.StatusServiceUnavailable, "shutting_down", "服务正在关闭，暂不接受新任务", nil)
+		return
+	case err != nil:
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to queue job", nil)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	h.respondJSON(w, http.StatusAccepted, jobResponse(job))
+}
+
+// GetJob responds with a job's status and progress, and its result once done
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobQueue.Get(chi.URLParam(r, "jobID"))
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "job_not_found", "任务不存在或已过期", nil)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, jobResponse(job))
+}
+
+// CancelJob cancels a queued or running job
+func (h *Handler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobQueue.Cancel(chi.URLParam(r, "jobID"))
+	if !ok {
+		h.respondError(w, http.StatusNotFound, "job_not_found", "任务不存在或已过期", nil)
+		return
+	}
+	h.respondJSON(w, http.StatusOK, jobResponse(job))
+}
+
+// Shutdown closes live connections, stops accepting jobs and waits for
+// queued and running ones until ctx expires, then cancels the rest
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.live.closeAll()
+	return h.jobQueue.Shutdown(ctx)
+}
+
+// jobObserver reports diagnosis events as job progress, naming the stage
+// after the last completed step
+func jobObserver(progress jobs.Progress) diagnosis.Observer {
+	return func(e diagnosis.Event) {
+		stage := e.Stage
+		if e.Step != "" {
+			stage = e.Step
+		}
+		progress(stage, e.Progress)
+	}
+}
+
+// jobError converts a diagnosis error to the error reported by a job
+func jobError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	e := diagnosisError(err)
+	return &model.JobError{Code: e.code, Message: e.message, Details: e.details}
+}
+
+// jobResponse wraps a job snapshot for the job endpoints
+func jobResponse(job model.JobInfo) model.JobResponse {
+	return model.JobResponse{
+		Success: true,
+		Job:     job,
+		Meta: &model.ResponseMeta{
+			Version:     "1.0.0",
+			ProcessedAt: time.Now().Format(time.RFC3339),
+		},
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/positiondoctor/backend/internal/live"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Live tracking limits. Every message is processed as soon as it arrives and
+// its events are written before the next one is read, so a small batch and
+// a write deadline bound the latency; clients that stop reading are dropped.
+const (
+	liveMaxPoints        = 100      // Points per message
+	liveMaxMessageSize   = 64 << 10 // Bytes per message
+	liveWriteTimeout     = 5 * time.Second
+	liveIdleTimeout      = 2 * time.Minute // Longest silence, pongs included
+	livePingInterval     = 30 * time.Second
+	liveCloseGracePeriod = time.Second
+)
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// Live diagnoses a track as it is recorded over a WebSocket. Clients send
+// points in the PointsRequest format one at a time ([lat, lon, time, ...]),
+// in small batches ([[lat, lon, time, ...], ...]) or as a LiveRequest object
+// whose first message may carry thresholds and AdaptiveRTS parameters. Each
+// point is answered with a position event holding its filtered position,
+// followed by anomaly events for the checks it fails.
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) {
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has responded with an HTTP error
+	}
+	defer conn.Close()
+
+	if !h.live.add(conn) {
+		closeLive(conn, websocket.CloseGoingAway, "服务正在关闭")
+		return
+	}
+	defer h.live.remove(conn)
+
+	conn.SetReadLimit(liveMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(liveIdleTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(liveIdleTimeout))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go pingLive(conn, done)
+
+	session := &liveSession{tracker: live.NewTracker(model.DefaultRequest()), metrics: h.metrics}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				closeLive(conn, websocket.CloseMessageTooBig,
+					fmt.Sprintf("Maximum %d bytes per message", liveMaxMessageSize))
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(liveIdleTimeout))
+
+		for _, e := range session.handle(data) {
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// liveSession is the state of one live connection
+type liveSession struct {
+	tracker *live.Tracker
+	metrics *serverMetrics
+}
+
+// handle processes one client message and returns the events to send back.
+// Options in the first message replace the default tracker.
+func (s *liveSession) handle(data []byte) []model.LiveEvent {
+	req, err := parseLiveMessage(data)
+	if err != nil {
+		return []model.LiveEvent{liveError("invalid_json", "Failed to parse message: "+err.Error(), nil)}
+	}
+
+	if req.Options != nil {
+		if s.tracker.Count() > 0 {
+			return []model.LiveEvent{liveError("invalid_options", "选项只能在第一条消息中设置",
+				&model.ErrorDetails{Field: "options"})}
+		}
+		if details := model.ValidateProfile(req.Options.Profile); details != nil {
+			return []model.LiveEvent{liveError("invalid_options", details.Message, details)}
+		}
+		if details := req.Options.Parameters.Validate(); details != nil {
+			return []model.LiveEvent{liveError("invalid_options", details.Message, details)}
+		}
+		s.tracker = live.NewTracker(*req.Options)
+	}
+
+	if len(req.Points) > liveMaxPoints {
+		return []model.LiveEvent{liveError("too_many_points",
+			fmt.Sprintf("Maximum %d points per message", liveMaxPoints), &model.ErrorDetails{
+				Field:   "points",
+				Limit:   liveMaxPoints,
+				Message: fmt.Sprintf("Received %d points, maximum is %d", len(req.Points), liveMaxPoints),
+			})}
+	}
+
+	points := make([]model.Point, 0, len(req.Points))
+	var invalidIndices []int
+	for i, raw := range req.Points {
+		p, ok := convertPoint(raw, i)
+		if !ok {
+			invalidIndices = append(invalidIndices, i)
+			continue
+		}
+		points = append(points, p)
+	}
+	if len(invalidIndices) > 0 {
+		return []model.LiveEvent{liveError("invalid_points",
+			fmt.Sprintf("Invalid points at indices: %v (check lat, lon, time ranges)", invalidIndices),
+			&model.ErrorDetails{Field: "points", InvalidIndices: invalidIndices})}
+	}
+
+	events := make([]model.LiveEvent, 0, len(points))
+	for i, p := range points {
+		position, anomalies, err := s.tracker.Push(p)
+		if errors.Is(err, live.ErrOutOfOrder) {
+			events = append(events, liveError("out_of_order", "轨迹点时间早于上一个点，已忽略",
+				&model.ErrorDetails{Field: "points", InvalidIndices: []int{i}}))
+			continue
+		}
+
+		events = append(events, model.LiveEvent{Type: model.LiveEventPosition, Position: &position})
+		for j := range anomalies {
+			events = append(events, model.LiveEvent{Type: model.LiveEventAnomaly, Anomaly: &anomalies[j]})
+		}
+		s.record(anomalies)
+	}
+	return events
+}
+
+// record counts a tracked point and its anomalies
+func (s *liveSession) record(anomalies []model.LiveAnomaly) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.points.Inc(sourceLive)
+	for _, a := range anomalies {
+		s.metrics.anomalies.Inc(string(a.Type))
+	}
+}
+
+// parseLiveMessage decodes a single point, a batch of points or a
+// LiveRequest
+func parseLiveMessage(data []byte) (model.LiveRequest, error) {
+	var req model.LiveRequest
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(data, []byte("{")):
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&req)
+		return req, err
+	case bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(data, []byte("["))), []byte("[")):
+		err := json.Unmarshal(data, &req.Points)
+		return req, err
+	default:
+		var point []float64
+		if err := json.Unmarshal(data, &point); err != nil {
+			return req, err
+		}
+		req.Points = [][]float64{point}
+		return req, nil
+	}
+}
+
+// liveError builds an error event
+func liveError(code, message string, details *model.ErrorDetails) model.LiveEvent {
+	return model.LiveEvent{
+		Type:  model.LiveEventError,
+		Error: &model.LiveError{Code: code, Message: message, Details: details},
+	}
+}
+
+// pingLive keeps the connection alive through proxies and detects dead
+// clients until done is closed
+func pingLive(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteTimeout)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// closeLive sends a close frame with a status code and reason
+func closeLive(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(liveCloseGracePeriod))
+}
+
+// liveSessions tracks open live connections so Shutdown can close them;
+// http.Server.Shutdown does not wait for hijacked connections
+type liveSessions struct {
+	mu     sync.Mutex
+	conns  map[*websocket.Conn]struct{}
+	closed bool
+}
+
+// newLiveSessions creates an empty session set
+func newLiveSessions() *liveSessions {
+	return &liveSessions{conns: make(map[*websocket.Conn]struct{})}
+}
+
+// add registers a connection, refusing it once the set is closed
+func (s *liveSessions) add(conn *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// remove forgets a connection
+func (s *liveSessions) remove(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// len returns the number of open connections
+func (s *liveSessions) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// closeAll refuses new connections and closes the open ones
+func (s *liveSessions) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for conn := range s.conns {
+		closeLive(conn, websocket.CloseGoingAway, "服务正在关闭")
+		conn.Close()
+	}
+}
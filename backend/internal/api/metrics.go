@@ -0,0 +1,181 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gorilla/websocket"
+	"github.com/positiondoctor/backend/internal/batch"
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/metrics"
+)
+
+// Rate limit enforced per client IP
+const (
+	rateLimitPerMinute = 10
+	rateLimitBurst     = 20
+)
+
+// Sources of processed points
+const (
+	sourceDiagnose = "diagnose" // Single diagnoses: uploads, points, streams and jobs
+	sourceBatch    = "batch"
+	sourceLive     = "live"
+)
+
+// Histogram buckets
+var (
+	requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	stageBuckets   = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	scoreBuckets   = []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+)
+
+// serverMetrics are the metrics served at GET /metrics
+type serverMetrics struct {
+	registry        *metrics.Registry
+	requests        *metrics.Counter
+	requestDuration *metrics.Histogram
+	points          *metrics.Counter
+	parseFailures   *metrics.Counter
+	anomalies       *metrics.Counter
+	healthScore     *metrics.Histogram
+	stageDuration   *metrics.Histogram
+	rateLimited     *metrics.Counter
+}
+
+// newServerMetrics registers the server's metrics, reading the report store
+// size and open live connections from h at scrape time
+func newServerMetrics(h *Handler) *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: r,
+		requests: r.NewCounter("positiondoctor_http_requests_total",
+			"HTTP requests by route pattern, method and status code.", "route", "method", "code"),
+		requestDuration: r.NewHistogram("positiondoctor_http_request_duration_seconds",
+			"HTTP request latency by route pattern and method.", requestBuckets, "route", "method"),
+		points: r.NewCounter("positiondoctor_points_processed_total",
+			"Track points diagnosed, by source.", "source"),
+		parseFailures: r.NewCounter("positiondoctor_parse_failures_total",
+			"Track files that failed to parse, by error class.", "class"),
+		anomalies: r.NewCounter("positiondoctor_anomalous_points_total",
+			"Points flagged by the anomaly detector, by anomaly type.", "type"),
+		healthScore: r.NewHistogram("positiondoctor_health_score",
+			"Health scores of diagnosed tracks.", scoreBuckets),
+		stageDuration: r.NewHistogram("positiondoctor_stage_duration_seconds",
+			"Time spent per diagnosis stage: detect, each correction step by name, and score.", stageBuckets, "stage"),
+		rateLimited: r.NewCounter("positiondoctor_rate_limited_total",
+			"Requests rejected by the rate limiter."),
+	}
+
+	r.NewGaugeFunc("positiondoctor_reports_stored", "Reports in the report store.", func() float64 {
+		return float64(h.reports.Len())
+	})
+	r.NewGaugeFunc("positiondoctor_live_connections", "Open live tracking WebSockets.", func() float64 {
+		return float64(h.live.len())
+	})
+	r.NewGaugeFunc("positiondoctor_rate_limit_per_minute", "Requests per minute allowed per client IP.", func() float64 {
+		return rateLimitPerMinute
+	})
+	r.NewGaugeFunc("positiondoctor_rate_limit_burst", "Requests a client IP may burst above the rate.", func() float64 {
+		return rateLimitBurst
+	})
+
+	return m
+}
+
+// instrument counts requests and measures their latency per route pattern
+func (m *serverMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := routePattern(r)
+
+		status := ww.Status()
+		switch {
+		case status == 0 && websocket.IsWebSocketUpgrade(r):
+			status = http.StatusSwitchingProtocols // Hijacked by the upgrade
+		case status == 0:
+			status = http.StatusOK
+		}
+
+		m.requests.Inc(route, r.Method, strconv.Itoa(status))
+		m.requestDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+// routePattern returns the route pattern of r, or "unmatched". Requests
+// turned away by middleware, such as the rate limiter, never reach their
+// route, so their path is matched against the routes afterwards.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return "unmatched"
+	}
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+	if rctx.Routes != nil {
+		match := chi.NewRouteContext()
+		if rctx.Routes.Match(match, r.Method, r.URL.Path) {
+			return match.RoutePattern()
+		}
+	}
+	return "unmatched"
+}
+
+// observer records stage timings, anomalies and the health score of a
+// diagnosis, passing events on to observe when it is not nil
+func (m *serverMetrics) observer(observe diagnosis.Observer) diagnosis.Observer {
+	return func(e diagnosis.Event) {
+		switch e.Stage {
+		case diagnosis.StageDetected:
+			m.stageDuration.Observe(e.Elapsed.Seconds(), "detect")
+			for anomalyType, count := range e.Anomalies {
+				m.anomalies.Add(float64(count), string(anomalyType))
+			}
+		case diagnosis.StageStep:
+			m.stageDuration.Observe(e.Elapsed.Seconds(), e.Step)
+		case diagnosis.StageScored:
+			m.stageDuration.Observe(e.Elapsed.Seconds(), "score")
+			if e.HealthScore != nil {
+				m.healthScore.Observe(float64(e.HealthScore.Total))
+			}
+		}
+		if observe != nil {
+			observe(e)
+		}
+	}
+}
+
+// parseFailed counts a track file that failed to parse
+func (m *serverMetrics) parseFailed(err error) {
+	m.parseFailures.Inc(parseErrorClass(err))
+}
+
+// batchDone records the tracks of a batch run
+func (m *serverMetrics) batchDone(summary *batch.Summary) {
+	for _, f := range summary.Files {
+		if f.ParseErr != nil {
+			m.parseFailed(f.ParseErr)
+		}
+		if f.Error != "" {
+			continue
+		}
+		m.points.Add(float64(f.Points), sourceBatch)
+		m.healthScore.Observe(float64(f.HealthScore))
+		for anomalyType, count := range f.Anomalies {
+			m.anomalies.Add(float64(count), string(anomalyType))
+		}
+	}
+}
+
+// Metrics serves the server's metrics in the Prometheus text format
+func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	h.metrics.registry.WriteText(w)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/positiondoctor/backend/internal/model"
+	"github.com/positiondoctor/backend/internal/store"
+)
+
+// GetReport responds with a stored diagnosis, as POST /diagnose returned it
+// with points included
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	report, apiErr := h.loadReport(chi.URLParam(r, "reportID"))
+	if apiErr != nil {
+		h.respondAPIError(w, apiErr)
+		return
+	}
+
+	h.respondJSON(w, http.StatusOK, model.DiagnoseResponse{
+		Success: true,
+		Data:    report,
+		Meta: &model.ResponseMeta{
+			Version:     "1.0.0",
+			ProcessedAt: time.Now().Format(time.RFC3339),
+		},
+	})
+}
+
+// DeleteReport removes a stored diagnosis
+func (h *Handler) DeleteReport(w http.ResponseWriter, r *http.Request) {
+	err := h.reports.Delete(chi.URLParam(r, "reportID"))
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.respondError(w, http.StatusNotFound, "not_found", "Report not found or expired", nil)
+		return
+	case err != nil:
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to delete report", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadReport reads a report from the handler's store
+func (h *Handler) loadReport(id string) (*model.Data, *apiError) {
+	report, err := h.reports.Load(id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, newAPIError(http.StatusNotFound, "not_found", "Report not found or expired", nil)
+	case err != nil:
+		return nil, newAPIError(http.StatusInternalServerError, "internal_error", "Failed to load report", nil)
+	}
+	return report, nil
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/model"
+	"github.com/positiondoctor/backend/internal/parser"
+)
+
+// sseKeepAlive is the interval of comment lines that keep idle streams open
+// through proxies
+const sseKeepAlive = 15 * time.Second
+
+// sseEvent is one server-sent event
+type sseEvent struct {
+	name string
+	data interface{}
+}
+
+// DiagnoseStream diagnoses like POST /diagnose (multipart upload) or POST
+// /diagnose/points (JSON) and streams the progress as server-sent events:
+// parsed, detected, one step event per correction step and scored, then the
+// DiagnoseResponse as result, or error. Invalid requests are rejected with a
+// plain JSON error before the stream starts.
+func (h *Handler) DiagnoseStream(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	var run func(ctx context.Context, observe diagnosis.Observer) (*model.DiagnoseResponse, error)
+	var parsed []diagnosis.Event
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		files, options, apiErr := h.readUpload(r)
+		if apiErr != nil {
+			h.respondAPIError(w, apiErr)
+			return
+		}
+		parsed = parsedEvents(files)
+		run = func(ctx context.Context, observe diagnosis.Observer) (*model.DiagnoseResponse, error) {
+			return h.diagnoseFiles(ctx, files, options, startTime, observe)
+		}
+	} else {
+		points, options, apiErr := h.readPoints(r)
+		if apiErr != nil {
+			h.respondAPIError(w, apiErr)
+			return
+		}
+		if err := h.service.Validate(options); err != nil {
+			h.respondDiagnosisError(w, err)
+			return
+		}
+		parsed = []diagnosis.Event{{Stage: diagnosis.StageParsed, Points: len(points)}}
+		run = func(ctx context.Context, observe diagnosis.Observer) (*model.DiagnoseResponse, error) {
+			result, err := h.diagnose(ctx, points, options, observe)
+			if err != nil {
+				return nil, err
+			}
+			response := h.buildDiagnoseResponse(result, options, startTime)
+			return &response, nil
+		}
+	}
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ctx := r.Context()
+	events := make(chan sseEvent, 16)
+	send := func(e sseEvent) {
+		select {
+		case events <- e:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(events)
+		for _, e := range parsed {
+			send(sseEvent{name: e.Stage, data: e})
+		}
+
+		response, err := run(ctx, func(e diagnosis.Event) {
+			send(sseEvent{name: e.Stage, data: e})
+		})
+		if err != nil {
+			e := diagnosisError(err)
+			send(sseEvent{name: "error", data: model.ErrorResponse{
+				Success: false,
+				Error:   e.code,
+				Message: e.message,
+				Details: e.details,
+			}})
+			return
+		}
+		send(sseEvent{name: "result", data: response})
+	}()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		rc.Flush()
+	}
+}
+
+// parsedEvents reports the parsed tracks of an upload
+func parsedEvents(files []parser.ParsedFile) []diagnosis.Event {
+	events := make([]diagnosis.Event, 0, len(files))
+	for _, f := range files {
+		if f.Err != nil {
+			continue
+		}
+		e := diagnosis.Event{Stage: diagnosis.StageParsed, Points: len(f.Points), Format: f.Format}
+		if len(files) > 1 {
+			e.File = f.Name
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// writeSSE writes one event in text/event-stream framing
+func writeSSE(w http.ResponseWriter, e sseEvent) error {
+	data, err := json.Marshal(e.data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, data)
+	return err
+}
@@ -0,0 +1,218 @@
+// Package batch diagnoses many track files in a bounded worker pool and
+// summarises the results in one report.
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/model"
+	"github.com/positiondoctor/backend/internal/parser"
+)
+
+// File is one input of a batch run. Read is called by the worker that
+// processes the file, so large directories are not held in memory at once.
+type File struct {
+	Name string
+	Read func() ([]byte, error)
+}
+
+// DirFiles lists the track files and archives below root, sorted by path.
+// Hidden files and directories and files with unknown extensions are skipped.
+func DirFiles(root string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !isTrackFile(d.Name()) {
+			return nil
+		}
+
+		name, err := filepath.Rel(root, p)
+		if err != nil {
+			name = p
+		}
+		files = append(files, File{
+			Name: filepath.ToSlash(name),
+			Read: func() ([]byte, error) { return os.ReadFile(p) },
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ArchiveFiles lists the track files in a gzip or zip archive, decompressing
+// at most limit bytes
+func ArchiveFiles(filename string, data []byte, limit int64) ([]File, error) {
+	entries, err := parser.Unpack(filename, data, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, len(entries))
+	for i, entry := range entries {
+		entry := entry
+		files[i] = File{
+			Name: entry.Name,
+			Read: func() ([]byte, error) { return entry.Data, nil },
+		}
+	}
+	return files, nil
+}
+
+// isTrackFile reports whether a file name has a track or archive extension
+func isTrackFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".gz", ".zip", ".kmz":
+		return true
+	}
+	return parser.FormatFromExtension(name) != ""
+}
+
+// Runner diagnoses files in parallel
+type Runner struct {
+	service *diagnosis.Service
+	factory *parser.ParserFactory
+	workers int
+	limit   int64
+}
+
+// NewRunner creates a runner with one worker per CPU
+func NewRunner(service *diagnosis.Service) *Runner {
+	return NewRunnerWithWorkers(service, runtime.NumCPU())
+}
+
+// NewRunnerWithWorkers creates a runner with at most workers files in
+// flight; values below 1 mean one worker per CPU
+func NewRunnerWithWorkers(service *diagnosis.Service, workers int) *Runner {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	return &Runner{
+		service: service,
+		factory: parser.NewParserFactory(),
+		workers: workers,
+		limit:   parser.DefaultMaxDecompressedSize,
+	}
+}
+
+// Workers returns the size of the worker pool
+func (r *Runner) Workers() int {
+	return r.workers
+}
+
+// Run diagnoses every file with options and summarises the results. Files
+// that cannot be read, parsed or diagnosed are recorded in the summary with
+// their error. Run only fails when ctx is canceled.
+func (r *Runner) Run(ctx context.Context, files []File, options model.DiagnoseRequest) (*Summary, error) {
+	start := time.Now()
+	results := make([][]FileResult, len(files))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < r.workers && w < len(files); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = r.process(ctx, files[i], options)
+			}
+		}()
+	}
+
+feed:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var flat []FileResult
+	for _, rs := range results {
+		flat = append(flat, rs...)
+	}
+	return Summarize(flat, time.Since(start)), nil
+}
+
+// process diagnoses one input file. Archives yield one result per track.
+func (r *Runner) process(ctx context.Context, file File, options model.DiagnoseRequest) []FileResult {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	data, err := file.Read()
+	if err != nil {
+		return []FileResult{{File: file.Name, Error: err.Error()}}
+	}
+
+	parsed, err := r.factory.ParseArchive(file.Name, data, r.limit)
+	if err != nil {
+		return []FileResult{{File: file.Name, Error: err.Error(), ParseErr: err}}
+	}
+
+	results := make([]FileResult, 0, len(parsed))
+	for _, p := range parsed {
+		name := file.Name
+		if len(parsed) > 1 {
+			name = file.Name + "/" + p.Name
+		}
+		results = append(results, r.diagnose(ctx, name, p, options))
+	}
+	return results
+}
+
+// diagnose diagnoses one parsed track
+func (r *Runner) diagnose(ctx context.Context, name string, p parser.ParsedFile, options model.DiagnoseRequest) FileResult {
+	result := FileResult{File: name, Format: p.Format, Points: len(p.Points)}
+	if p.Err != nil {
+		result.Error = p.Err.Error()
+		result.ParseErr = p.Err
+		return result
+	}
+	if len(p.Points) < 2 {
+		result.Error = fmt.Sprintf("too few valid points (minimum 2 required, got %d)", len(p.Points))
+		return result
+	}
+
+	diagnosed, err := r.service.Diagnose(ctx, p.Points, options)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	score := diagnosed.Diagnostics.HealthScore
+	result.HealthScore = score.Total
+	result.Rating = score.Rating
+	result.Anomalies = make(map[model.AnomalyType]int, len(diagnosed.Diagnostics.Anomalies))
+	for _, a := range diagnosed.Diagnostics.Anomalies {
+		result.Anomalies[a.Type] += a.Count
+	}
+	return result
+}
@@ -0,0 +1,146 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirFiles(t *testing.T) {
+	dir := t.TempDir()
+	sample, err := os.ReadFile("../../testdata/sample.gpx")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "b.gpx"), sample)
+	writeFile(t, filepath.Join(dir, "day1", "a.gpx"), sample)
+	writeFile(t, filepath.Join(dir, "notes.txt"), []byte("not a track"))
+	writeFile(t, filepath.Join(dir, ".cache", "c.gpx"), sample)
+
+	files, err := DirFiles(dir)
+	if err != nil {
+		t.Fatalf("DirFiles() error = %v", err)
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "b.gpx,day1/a.gpx" {
+		t.Errorf("DirFiles() = %s, want b.gpx,day1/a.gpx", got)
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	sample, err := os.ReadFile("../../testdata/sample.gpx")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	files := []File{
+		{Name: "a.gpx", Read: func() ([]byte, error) { return sample, nil }},
+		{Name: "broken.gpx", Read: func() ([]byte, error) { return []byte("<gpx"), nil }},
+		{Name: "missing.gpx", Read: func() ([]byte, error) { return nil, errors.New("permission denied") }},
+		{Name: "b.gpx", Read: func() ([]byte, error) { return sample, nil }},
+	}
+
+	runner := NewRunnerWithWorkers(diagnosis.NewService(), 3)
+	summary, err := runner.Run(context.Background(), files, model.DefaultRequest())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(summary.Files) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(summary.Files))
+	}
+	// Results keep the input order
+	for i, name := range []string{"a.gpx", "broken.gpx", "missing.gpx", "b.gpx"} {
+		if summary.Files[i].File != name {
+			t.Errorf("Files[%d] = %s, want %s", i, summary.Files[i].File, name)
+		}
+	}
+	if summary.Files[1].Error == "" || summary.Files[2].Error == "" {
+		t.Errorf("Expected errors for broken.gpx and missing.gpx")
+	}
+	if summary.Files[0].HealthScore == 0 || summary.Files[0].HealthScore != summary.Files[3].HealthScore {
+		t.Errorf("Expected equal scores for identical files, got %d and %d",
+			summary.Files[0].HealthScore, summary.Files[3].HealthScore)
+	}
+	if summary.Aggregate.Succeeded != 2 || summary.Aggregate.Failed != 2 {
+		t.Errorf("Aggregate = %+v, want 2 succeeded and 2 failed", summary.Aggregate)
+	}
+}
+
+func TestRunner_RunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files := []File{{Name: "a.gpx", Read: func() ([]byte, error) { return nil, nil }}}
+	if _, err := NewRunner(diagnosis.NewService()).Run(ctx, files, model.DefaultRequest()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	results := []FileResult{
+		{File: "a", Points: 10, HealthScore: 90, Rating: model.RatingExcellent,
+			Anomalies: map[model.AnomalyType]int{model.AnomalyJump: 2}},
+		{File: "b", Points: 20, HealthScore: 50, Rating: model.RatingFair,
+			Anomalies: map[model.AnomalyType]int{model.AnomalyJump: 1, model.AnomalyDrift: 4}},
+		{File: "c", Points: 5, HealthScore: 100, Rating: model.RatingExcellent},
+		{File: "d", Error: "invalid GPX format"},
+	}
+
+	summary := Summarize(results, time.Second)
+	agg := summary.Aggregate
+
+	if agg.Files != 4 || agg.Succeeded != 3 || agg.Failed != 1 || agg.Points != 35 {
+		t.Errorf("Aggregate counts = %+v", agg)
+	}
+	if agg.Score.Min != 50 || agg.Score.Max != 100 || agg.Score.Median != 90 || agg.Score.Mean != 80 {
+		t.Errorf("Score = %+v, want min 50, max 100, median 90, mean 80", agg.Score)
+	}
+	if agg.Ratings[model.RatingExcellent] != 2 || agg.Ratings[model.RatingFair] != 1 {
+		t.Errorf("Ratings = %v", agg.Ratings)
+	}
+	if agg.Histogram[5].Files != 1 || agg.Histogram[9].Files != 2 {
+		t.Errorf("Histogram = %+v", agg.Histogram)
+	}
+	if jump := agg.Anomalies[model.AnomalyJump]; jump.Files != 2 || jump.Points != 3 {
+		t.Errorf("Jump totals = %+v, want 2 files and 3 points", jump)
+	}
+
+	var buf bytes.Buffer
+	if err := summary.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected header and 4 rows, got %d lines", len(lines))
+	}
+	if lines[0] != "file,format,points,health_score,rating,speed_anomaly,acceleration_anomaly,jump,drift,missing,density_anomaly,error" {
+		t.Errorf("Unexpected header: %s", lines[0])
+	}
+	if lines[2] != "b,,20,50,fair,0,0,1,4,0,0," {
+		t.Errorf("Unexpected row: %s", lines[2])
+	}
+}
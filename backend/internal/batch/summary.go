@@ -0,0 +1,207 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// AnomalyTypes are the anomaly columns of the CSV report, in order
+var AnomalyTypes = []model.AnomalyType{
+	model.AnomalySpeedAnomaly,
+	model.AnomalyAccelAnomaly,
+	model.AnomalyJump,
+	model.AnomalyDrift,
+	model.AnomalyMissing,
+	model.AnomalyDensity,
+}
+
+// FileResult is the diagnosis of one track
+type FileResult struct {
+	File        string                    `json:"file"`
+	Format      string                    `json:"format,omitempty"`
+	Points      int                       `json:"points"`
+	HealthScore int                       `json:"healthScore"`
+	Rating      model.Rating              `json:"rating,omitempty"`
+	Anomalies   map[model.AnomalyType]int `json:"anomalies,omitempty"` // Affected points by anomaly type
+	Error       string                    `json:"error,omitempty"`
+	ParseErr    error                     `json:"-"` // Parser error behind Error, when parsing failed
+}
+
+// Summary is the report of a batch run
+type Summary struct {
+	Files     []FileResult `json:"files"`
+	Aggregate Aggregate    `json:"aggregate"`
+	Duration  int64        `json:"durationMs"`
+}
+
+// Aggregate holds distributions over all successfully diagnosed tracks
+type Aggregate struct {
+	Files     int                                 `json:"files"`
+	Succeeded int                                 `json:"succeeded"`
+	Failed    int                                 `json:"failed"`
+	Points    int                                 `json:"points"`
+	Score     ScoreDistribution                   `json:"score"`
+	Histogram []ScoreBucket                       `json:"histogram"`
+	Ratings   map[model.Rating]int                `json:"ratings"`
+	Anomalies map[model.AnomalyType]AnomalyTotals `json:"anomalies"`
+}
+
+// ScoreDistribution summarises health scores
+type ScoreDistribution struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P10    float64 `json:"p10"`
+	P90    float64 `json:"p90"`
+}
+
+// ScoreBucket counts tracks with a health score in [From, To]
+type ScoreBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Files int `json:"files"`
+}
+
+// AnomalyTotals counts one anomaly type across tracks
+type AnomalyTotals struct {
+	Files  int `json:"files"`  // Tracks with at least one such anomaly
+	Points int `json:"points"` // Affected points over all tracks
+}
+
+// Summarize builds the summary of a batch run from its per-track results
+func Summarize(results []FileResult, duration time.Duration) *Summary {
+	if results == nil {
+		results = []FileResult{}
+	}
+
+	agg := Aggregate{
+		Files:     len(results),
+		Histogram: make([]ScoreBucket, 10),
+		Ratings:   make(map[model.Rating]int),
+		Anomalies: make(map[model.AnomalyType]AnomalyTotals),
+	}
+	for i := range agg.Histogram {
+		agg.Histogram[i] = ScoreBucket{From: i * 10, To: i*10 + 9}
+	}
+	agg.Histogram[9].To = 100
+
+	var scores []int
+	for _, r := range results {
+		if r.Error != "" {
+			agg.Failed++
+			continue
+		}
+		agg.Succeeded++
+		agg.Points += r.Points
+		agg.Ratings[r.Rating]++
+		scores = append(scores, r.HealthScore)
+		agg.Histogram[bucket(r.HealthScore)].Files++
+
+		for t, n := range r.Anomalies {
+			totals := agg.Anomalies[t]
+			totals.Files++
+			totals.Points += n
+			agg.Anomalies[t] = totals
+		}
+	}
+	agg.Score = distribution(scores)
+
+	return &Summary{
+		Files:     results,
+		Aggregate: agg,
+		Duration:  duration.Milliseconds(),
+	}
+}
+
+// bucket returns the histogram bucket of a health score
+func bucket(score int) int {
+	switch {
+	case score < 0:
+		return 0
+	case score >= 100:
+		return 9
+	default:
+		return score / 10
+	}
+}
+
+// distribution computes the score distribution
+func distribution(scores []int) ScoreDistribution {
+	if len(scores) == 0 {
+		return ScoreDistribution{}
+	}
+
+	sorted := append([]int{}, scores...)
+	sort.Ints(sorted)
+
+	sum := 0
+	for _, s := range sorted {
+		sum += s
+	}
+
+	return ScoreDistribution{
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   float64(sum) / float64(len(sorted)),
+		Median: percentile(sorted, 0.5),
+		P10:    percentile(sorted, 0.1),
+		P90:    percentile(sorted, 0.9),
+	}
+}
+
+// percentile interpolates the p-th percentile of sorted values
+func percentile(sorted []int, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower+1 >= len(sorted) {
+		return float64(sorted[lower])
+	}
+	frac := pos - float64(lower)
+	return float64(sorted[lower])*(1-frac) + float64(sorted[lower+1])*frac
+}
+
+// WriteJSON writes the summary as indented JSON
+func (s *Summary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// WriteCSV writes one row per track: file, format, points, health score,
+// rating, affected points per anomaly type and error
+func (s *Summary) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"file", "format", "points", "health_score", "rating"}
+	for _, t := range AnomalyTypes {
+		header = append(header, string(t))
+	}
+	header = append(header, "error")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range s.Files {
+		row := []string{r.File, r.Format, strconv.Itoa(r.Points), "", string(r.Rating)}
+		if r.Error == "" {
+			row[3] = strconv.Itoa(r.HealthScore)
+		}
+		for _, t := range AnomalyTypes {
+			row = append(row, strconv.Itoa(r.Anomalies[t]))
+		}
+		row = append(row, r.Error)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,346 @@
+// Package diagnosis runs the trajectory diagnosis independent of transport:
+// post-processing, anomaly detection, correction and health scoring.
+package diagnosis
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/positiondoctor/backend/internal/algorithm"
+	"github.com/positiondoctor/backend/internal/model"
+	"github.com/positiondoctor/backend/internal/parser"
+)
+
+// Service diagnoses and corrects trajectories
+type Service struct {
+	registry *algorithm.Registry
+}
+
+// NewService creates a diagnosis service using the default step registry
+func NewService() *Service {
+	return NewServiceWithRegistry(algorithm.DefaultRegistry())
+}
+
+// NewServiceWithRegistry creates a diagnosis service whose pipelines draw
+// correction steps from registry
+func NewServiceWithRegistry(registry *algorithm.Registry) *Service {
+	return &Service{registry: registry}
+}
+
+// Result is the outcome of one diagnosis
+type Result struct {
+	ReportID    string
+	Original    model.TrajectoryStats
+	Corrected   model.TrajectoryStats
+	Diagnostics model.DiagnosticsInfo
+	Segments    []model.ModeSegment    // Transport mode legs of the input track
+	Stops       []model.Stop           // Stay points of the input track
+	Points      []model.Point          // Corrected points
+	Steps       []algorithm.StepReport // Executed correction steps in order
+	Stats       CorrectionStats
+}
+
+// Data returns the result in API response form
+func (r *Result) Data() *model.Data {
+	return &model.Data{
+		ReportID:    r.ReportID,
+		Original:    r.Original,
+		Corrected:   r.Corrected,
+		Diagnostics: r.Diagnostics,
+		Segments:    r.Segments,
+		Stops:       r.Stops,
+		Points:      r.Points,
+	}
+}
+
+// CorrectionStats 统计各算法的处理结果
+type CorrectionStats struct {
+	InterpolatedCount   int `json:"interpolated"`   // 插值生成的点数
+	SimplifiedCount     int `json:"simplified"`     // 简化删除的点数
+	OutlierRemovedCount int `json:"outlierRemoved"` // 离群点删除的点数
+}
+
+// OptionsError reports invalid algorithm parameters or steps
+type OptionsError struct {
+	Details *model.ErrorDetails
+}
+
+func (e *OptionsError) Error() string {
+	return "invalid options: " + e.Details.Message
+}
+
+// Validate checks the request's algorithm parameters and steps
+func (s *Service) Validate(options model.DiagnoseRequest) error {
+	_, err := s.pipeline(options)
+	return err
+}
+
+// pipeline builds the correction pipeline for options
+func (s *Service) pipeline(options model.DiagnoseRequest) (*algorithm.Pipeline, error) {
+	if details := model.ValidateProfile(options.Profile); details != nil {
+		return nil, &OptionsError{Details: details}
+	}
+	if details := options.Parameters.Validate(); details != nil {
+		return nil, &OptionsError{Details: details}
+	}
+	if details := options.Output.Validate(); details != nil {
+		return nil, &OptionsError{Details: details}
+	}
+	pipeline, details := s.registry.NewPipeline(options.StepConfigs())
+	if details != nil {
+		return nil, &OptionsError{Details: details}
+	}
+	return pipeline, nil
+}
+
+// Stages reported to an Observer
+const (
+	StageParsed   = "parsed" // Reported by callers that parse the input
+	StageDetected = "detected"
+	StageStep     = "step"
+	StageScored   = "scored"
+)
+
+// Event reports a completed stage of a diagnosis
+type Event struct {
+	Stage       string                    `json:"stage"`
+	Progress    float64                   `json:"progress"`            // Completed fraction, 0 to 1
+	File        string                    `json:"file,omitempty"`      // Track within an archive
+	Points      int                       `json:"points,omitempty"`    // Points parsed
+	Format      string                    `json:"format,omitempty"`    // Format parsed
+	Anomalies   map[model.AnomalyType]int `json:"anomalies,omitempty"` // Affected points by anomaly type
+	Step        string                    `json:"step,omitempty"`      // Correction step completed
+	Stats       *CorrectionStats          `json:"stats,omitempty"`     // What the step changed
+	HealthScore *model.HealthScore        `json:"healthScore,omitempty"`
+	Elapsed     time.Duration             `json:"-"` // Time spent in this stage
+}
+
+// Observer receives the events of a diagnosis as it advances
+type Observer func(Event)
+
+// Share of the work attributed to anomaly detection and to the correction
+// steps; scoring takes the rest
+const (
+	detectShare  = 0.3
+	correctShare = 0.6
+)
+
+// Diagnose detects anomalies, applies corrections and scores the track. It
+// returns an *OptionsError for invalid options and ctx's error once ctx is
+// canceled.
+func (s *Service) Diagnose(ctx context.Context, points []model.Point, options model.DiagnoseRequest) (*Result, error) {
+	return s.DiagnoseWithProgress(ctx, points, options, nil)
+}
+
+// DiagnoseWithProgress is Diagnose, reporting each completed stage to
+// observe when it is not nil
+func (s *Service) DiagnoseWithProgress(ctx context.Context, points []model.Point, options model.DiagnoseRequest, observe Observer) (*Result, error) {
+	if details := model.ValidateProfile(options.Profile); details != nil {
+		return nil, &OptionsError{Details: details}
+	}
+	if observe == nil {
+		observe = func(Event) {}
+	}
+
+	// Post-process points
+	points = parser.PostProcess(points)
+
+	// Preset thresholds and parameters for the transport mode
+	requested := options
+	profile, inferred := algorithm.ResolveProfile(options.Profile, points)
+	options = options.WithProfile(profile)
+	pipeline, err := s.pipeline(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store original stats
+	originalStats := model.CalculateStats(points)
+
+	// Run diagnostics
+	stageStart := time.Now()
+	segments := algorithm.SegmentModes(points)
+	anomalies, err := detect(ctx, points, segments, requested, options)
+	if err != nil {
+		return nil, err
+	}
+	stops := newDetector(options.Thresholds).DetectStops(points)
+	observe(Event{
+		Stage:     StageDetected,
+		Progress:  detectShare,
+		Anomalies: countAnomalies(anomalies),
+		Elapsed:   time.Since(stageStart),
+	})
+
+	// Mark the points each anomaly names, so corrections can target them
+	points = algorithm.LabelPoints(points, anomalies)
+
+	// Apply corrections with statistics
+	steps := float64(pipeline.Len())
+	stageStart = time.Now()
+	correctedPoints, reports, err := pipeline.RunWithProgress(ctx, points, algorithm.StepInput{Anomalies: anomalies, Stops: stops},
+		func(i int, report algorithm.StepReport) {
+			delta := collectCorrectionStats([]algorithm.StepReport{report})
+			observe(Event{
+				Stage:    StageStep,
+				Progress: math.Round((detectShare+correctShare*float64(i+1)/steps)*1000) / 1000,
+				Step:     report.Step.Name(),
+				Stats:    &delta,
+				Elapsed:  time.Since(stageStart),
+			})
+			stageStart = time.Now()
+		})
+	if err != nil {
+		return nil, err
+	}
+	correctionStats := collectCorrectionStats(reports)
+
+	// Calculate health score
+	stageStart = time.Now()
+	scorer := algorithm.NewHealthScorer()
+	healthScore := scorer.Calculate(correctedPoints, anomalies)
+	observe(Event{
+		Stage:       StageScored,
+		Progress:    1,
+		HealthScore: &healthScore,
+		Elapsed:     time.Since(stageStart),
+	})
+
+	// Build diagnostics info
+	diagnostics := model.DiagnosticsInfo{
+		NormalPoints:       countNormalPoints(correctedPoints),
+		AnomalyPoints:      countAnomalyPoints(correctedPoints),
+		FixedPoints:        correctionStats.OutlierRemovedCount, // 删除的异常点
+		RemovedPoints:      correctionStats.SimplifiedCount,     // 简化删除的点
+		TotalProcessed:     correctionStats.OutlierRemovedCount + correctionStats.SimplifiedCount,
+		InterpolatedPoints: correctionStats.InterpolatedCount, // 插值生成的点
+		Anomalies:          anomalies,
+		Algorithms:         buildAlgorithmInfo(reports),
+		HealthScore:        healthScore,
+		Profile:            profile.Name,
+		ProfileInferred:    inferred,
+	}
+
+	return &Result{
+		ReportID:    uuid.New().String(),
+		Original:    originalStats,
+		Corrected:   model.CalculateStats(correctedPoints),
+		Diagnostics: diagnostics,
+		Segments:    segments,
+		Stops:       stops,
+		Points:      correctedPoints,
+		Steps:       reports,
+		Stats:       correctionStats,
+	}, nil
+}
+
+// Detect finds the anomalies of points as Diagnose does, without correcting
+// them
+func (s *Service) Detect(ctx context.Context, points []model.Point, options model.DiagnoseRequest) ([]model.Anomaly, error) {
+	if details := model.ValidateProfile(options.Profile); details != nil {
+		return nil, &OptionsError{Details: details}
+	}
+	points = parser.PostProcess(points)
+	profile, _ := algorithm.ResolveProfile(options.Profile, points)
+	return detect(ctx, points, algorithm.SegmentModes(points), options, options.WithProfile(profile))
+}
+
+// detect finds the anomalies of points. With the auto profile each mode
+// segment of a multimodal track is checked with its mode's thresholds;
+// otherwise the whole track is checked with the resolved request's.
+func detect(ctx context.Context, points []model.Point, segments []model.ModeSegment,
+	requested, resolved model.DiagnoseRequest) ([]model.Anomaly, error) {
+	if requested.Profile != model.ProfileAuto || len(segments) < 2 {
+		return newDetector(resolved.Thresholds).DetectAllContext(ctx, points)
+	}
+	return algorithm.DetectModeSegmentsContext(ctx, points, segments, func(mode string) *algorithm.Detector {
+		profile, _ := model.LookupProfile(mode)
+		return newDetector(requested.WithProfile(profile).Thresholds)
+	})
+}
+
+// newDetector creates a detector with the given thresholds
+func newDetector(thresholds model.ThresholdOptions) *algorithm.Detector {
+	detector := algorithm.NewDetector()
+	detector.MaxSpeed = thresholds.MaxSpeed
+	detector.MaxAcceleration = thresholds.MaxAcceleration
+	detector.MaxJump = thresholds.MaxJump
+	return detector
+}
+
+// collectCorrectionStats totals what the pipeline steps changed
+func collectCorrectionStats(reports []algorithm.StepReport) CorrectionStats {
+	stats := CorrectionStats{}
+	for _, r := range reports {
+		stats.InterpolatedCount += r.Stats.Added
+		stats.SimplifiedCount += r.Stats.Removed
+		stats.OutlierRemovedCount += r.Stats.Rejected
+	}
+	return stats
+}
+
+// countAnomalies returns the affected points by anomaly type
+func countAnomalies(anomalies []model.Anomaly) map[model.AnomalyType]int {
+	counts := make(map[model.AnomalyType]int, len(anomalies))
+	for _, a := range anomalies {
+		counts[a.Type] += a.Count
+	}
+	return counts
+}
+
+// buildAlgorithmInfo builds algorithm execution info from the pipeline
+// reports, echoing the parameters each step ran with
+func buildAlgorithmInfo(reports []algorithm.StepReport) []model.AlgorithmInfo {
+	info := make([]model.AlgorithmInfo, 0, len(reports))
+
+	for _, r := range reports {
+		name, description := r.Step.Name(), ""
+		if d, ok := r.Step.(algorithm.Describer); ok {
+			name, description = d.DisplayName(), d.Description()
+		}
+
+		parameters := make(map[string]interface{}, len(r.Params)+len(r.Stats.Details))
+		for k, v := range r.Params {
+			parameters[k] = v
+		}
+		for k, v := range r.Stats.Details {
+			parameters[k] = v
+		}
+
+		info = append(info, model.AlgorithmInfo{
+			Name:            name,
+			Description:     description,
+			ProcessedPoints: r.Input,
+			FixedPoints:     len(r.FixedIndices),
+			RemovedPoints:   r.Stats.Removed + r.Stats.Rejected,
+			FixedIndices:    r.FixedIndices,
+			Parameters:      parameters,
+		})
+	}
+
+	return info
+}
+
+// countNormalPoints counts normal status points
+func countNormalPoints(points []model.Point) int {
+	count := 0
+	for _, p := range points {
+		if p.Status == model.StatusNormal {
+			count++
+		}
+	}
+	return count
+}
+
+// countAnomalyPoints counts anomaly status points
+func countAnomalyPoints(points []model.Point) int {
+	count := 0
+	for _, p := range points {
+		if p.Status != model.StatusNormal && p.Status != model.StatusInterpolated {
+			count++
+		}
+	}
+	return count
+}
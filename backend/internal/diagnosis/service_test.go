@@ -0,0 +1,324 @@
+package diagnosis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// createTrack creates a straight track with one position jump
+func createTrack(n int) []model.Point {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]model.Point, n)
+	for i := range points {
+		points[i] = model.Point{
+			Index:  i,
+			Lat:    39.9042 + float64(i)*0.0001,
+			Lon:    116.4074 + float64(i)*0.0001,
+			Time:   start.Add(time.Duration(i*5) * time.Second),
+			Status: model.StatusNormal,
+		}
+	}
+	points[n/2].Lat += 0.05
+	return points
+}
+
+func TestService_Diagnose(t *testing.T) {
+	service := NewService()
+
+	result, err := service.Diagnose(context.Background(), createTrack(50), model.DefaultRequest())
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+
+	if result.ReportID == "" {
+		t.Error("Expected a report ID")
+	}
+	if len(result.Diagnostics.Anomalies) == 0 {
+		t.Error("Expected the jump to be detected")
+	}
+	if len(result.Steps) != 4 || len(result.Diagnostics.Algorithms) != 4 {
+		t.Errorf("Expected 4 steps, got %d", len(result.Steps))
+	}
+	if result.Stats.OutlierRemovedCount == 0 {
+		t.Error("Expected the jump to be removed")
+	}
+
+	data := result.Data()
+	if data.ReportID != result.ReportID || len(data.Points) != len(result.Points) {
+		t.Error("Expected Data to carry the result")
+	}
+}
+
+func TestService_LabelsPoints(t *testing.T) {
+	options := model.DefaultRequest()
+	options.Steps = []model.StepConfig{{Name: model.StepSimplification}}
+
+	result, err := NewService().Diagnose(context.Background(), createTrack(50), options)
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+
+	var jump *model.Point
+	for i := range result.Points {
+		if result.Points[i].Index == 25 {
+			jump = &result.Points[i]
+		}
+	}
+	if jump == nil {
+		t.Fatal("Expected the jump to survive simplification")
+	}
+	if jump.Status != model.StatusJump || len(jump.Flags) == 0 || jump.Flags[0] != model.StatusJump {
+		t.Errorf("Jump point: status %s, flags %v; want jump first", jump.Status, jump.Flags)
+	}
+	if result.Diagnostics.AnomalyPoints == 0 {
+		t.Error("Expected anomaly points to be counted")
+	}
+}
+
+func TestService_InvalidOptions(t *testing.T) {
+	options := model.DefaultRequest()
+	options.Steps = []model.StepConfig{{Name: "median"}}
+
+	_, err := NewService().Diagnose(context.Background(), createTrack(10), options)
+
+	var optErr *OptionsError
+	if !errors.As(err, &optErr) {
+		t.Fatalf("Expected OptionsError, got %v", err)
+	}
+	if optErr.Details.Field != "steps[0].name" {
+		t.Errorf("Expected field steps[0].name, got %s", optErr.Details.Field)
+	}
+}
+
+func TestService_InvalidSimplifyEpsilon(t *testing.T) {
+	options := model.DefaultRequest()
+	options.Output.SimplifyEpsilon = -5
+
+	err := NewService().Validate(options)
+
+	var optErr *OptionsError
+	if !errors.As(err, &optErr) {
+		t.Fatalf("Expected OptionsError, got %v", err)
+	}
+	if optErr.Details.Field != "output.simplifyEpsilon" {
+		t.Errorf("Expected field output.simplifyEpsilon, got %s", optErr.Details.Field)
+	}
+}
+
+func TestService_Profiles(t *testing.T) {
+	service := NewService()
+
+	options := model.DefaultRequest()
+	options.Profile = model.ProfileWalk
+	result, err := service.Diagnose(context.Background(), createTrack(50), options)
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+	if result.Diagnostics.Profile != model.ProfileWalk || result.Diagnostics.ProfileInferred {
+		t.Errorf("Profile = %s (inferred %v), want walk as requested",
+			result.Diagnostics.Profile, result.Diagnostics.ProfileInferred)
+	}
+	if got := result.Diagnostics.Algorithms[0].Parameters["processNoise"]; got != 0.1 {
+		t.Errorf("processNoise = %v, want the walk preset 0.1", got)
+	}
+
+	// Values the client set win over the preset
+	options.Parameters.AdaptiveRTS.ProcessNoise = 2
+	result, err = service.Diagnose(context.Background(), createTrack(50), options)
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+	if got := result.Diagnostics.Algorithms[0].Parameters["processNoise"]; got != 2.0 {
+		t.Errorf("processNoise = %v, want 2 as requested", got)
+	}
+
+	// Even when they equal the default profile's preset: a steady 40 km/h
+	// exceeds the walk preset of 15 km/h but not 120
+	fast := make([]model.Point, 50)
+	for i := range fast {
+		fast[i] = model.Point{
+			Index:  i,
+			Lat:    39.9042 + float64(i)*0.0001,
+			Lon:    116.4074,
+			Time:   time.Date(2024, 1, 1, 8, 0, i, 0, time.UTC),
+			Status: model.StatusNormal,
+		}
+	}
+	options.Thresholds.MaxSpeed = 120
+	anomalies, err := service.Detect(context.Background(), fast, options)
+	if err != nil {
+		t.Fatalf("Failed to detect: %v", err)
+	}
+	for _, a := range anomalies {
+		if a.Type == model.AnomalySpeedAnomaly {
+			t.Errorf("Expected maxSpeed 120 to win over the walk preset, got %d speed anomalies", a.Count)
+		}
+	}
+
+	// About 10 km/h
+	options = model.DefaultRequest()
+	options.Profile = model.ProfileAuto
+	result, err = service.Diagnose(context.Background(), createTrack(50), options)
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+	if result.Diagnostics.Profile != model.ProfileBike || !result.Diagnostics.ProfileInferred {
+		t.Errorf("Profile = %s (inferred %v), want an inferred bike",
+			result.Diagnostics.Profile, result.Diagnostics.ProfileInferred)
+	}
+
+	options.Profile = "rocket"
+	_, err = service.Diagnose(context.Background(), createTrack(10), options)
+	var optErr *OptionsError
+	if !errors.As(err, &optErr) || optErr.Details.Field != "profile" {
+		t.Errorf("Expected an OptionsError for profile, got %v", err)
+	}
+}
+
+// createWalkAndDrive creates a 5-minute walk followed by a 5-minute drive at
+// 60 km/h, sampled every 5 seconds
+func createWalkAndDrive() []model.Point {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]model.Point, 120)
+	lon := 116.4074
+	for i := range points {
+		step := 0.00009 // About 7 m
+		if i > 60 {
+			step = 0.00108 // About 83 m
+		}
+		if i > 0 {
+			lon += step
+		}
+		points[i] = model.Point{
+			Index:  i,
+			Lat:    39.9042,
+			Lon:    lon,
+			Time:   start.Add(time.Duration(i*5) * time.Second),
+			Status: model.StatusNormal,
+		}
+	}
+	return points
+}
+
+func TestService_Segments(t *testing.T) {
+	service := NewService()
+	hasSpeedAnomaly := func(r *Result) bool {
+		for _, a := range r.Diagnostics.Anomalies {
+			if a.Type == model.AnomalySpeedAnomaly {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Auto checks the drive with car thresholds
+	options := model.DefaultRequest()
+	options.Profile = model.ProfileAuto
+	result, err := service.Diagnose(context.Background(), createWalkAndDrive(), options)
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+	if len(result.Segments) != 2 || result.Segments[0].Mode != model.ProfileWalk || result.Segments[1].Mode != model.ProfileCar {
+		t.Fatalf("Expected a walk and a drive, got %+v", result.Segments)
+	}
+	if len(result.Data().Segments) != 2 {
+		t.Errorf("Expected segments in the response data")
+	}
+	if hasSpeedAnomaly(result) {
+		t.Errorf("Expected no speed anomalies with per-segment thresholds, got %+v", result.Diagnostics.Anomalies)
+	}
+
+	// A named profile applies to the whole track
+	options.Profile = model.ProfileWalk
+	result, err = service.Diagnose(context.Background(), createWalkAndDrive(), options)
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+	if len(result.Segments) != 2 {
+		t.Errorf("Expected segments with a named profile too, got %+v", result.Segments)
+	}
+	if !hasSpeedAnomaly(result) {
+		t.Errorf("Expected the drive to be too fast for walking thresholds")
+	}
+}
+
+func TestService_Stops(t *testing.T) {
+	// Walk, then wait over 3 minutes with a few meters of jitter
+	points := createWalkAndDrive()[:60]
+	for i := 20; i < 60; i++ {
+		points[i].Lon = points[20].Lon + float64(i%3)*0.00003
+		points[i].Lat = points[20].Lat + float64(i%2)*0.00003
+	}
+
+	result, err := NewService().Diagnose(context.Background(), points, model.DefaultRequest())
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+	if len(result.Stops) != 1 || len(result.Data().Stops) != 1 {
+		t.Fatalf("Expected one stop, got %+v", result.Stops)
+	}
+
+	// The smoother holds the stop at one position
+	stop := result.Stops[0]
+	for _, p := range result.Points {
+		if p.Index >= stop.StartIndex && p.Index <= stop.EndIndex && (p.Lat != stop.Lat || p.Lon != stop.Lon) {
+			t.Errorf("Point %d at %v,%v, want the stop centroid", p.Index, p.Lat, p.Lon)
+		}
+	}
+}
+
+func TestService_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewService().Diagnose(ctx, createTrack(1000), model.DefaultRequest())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestService_DiagnoseWithProgress(t *testing.T) {
+	service := NewService()
+
+	var events []Event
+	last := -1.0
+	result, err := service.DiagnoseWithProgress(context.Background(), createTrack(50), model.DefaultRequest(),
+		func(e Event) {
+			if e.Progress < last || e.Progress > 1 {
+				t.Errorf("Progress went from %v to %v", last, e.Progress)
+			}
+			last = e.Progress
+			events = append(events, e)
+		})
+	if err != nil {
+		t.Fatalf("Failed to diagnose: %v", err)
+	}
+
+	// detected, the four default steps, scored
+	if len(events) != 6 {
+		t.Fatalf("Expected 6 events, got %d", len(events))
+	}
+	if events[0].Stage != StageDetected || len(events[0].Anomalies) == 0 {
+		t.Errorf("Expected anomaly counts first, got %+v", events[0])
+	}
+	if events[1].Stage != StageStep || events[1].Step != model.StepAdaptiveRTS || events[1].Stats == nil {
+		t.Errorf("Expected the RTS step second, got %+v", events[1])
+	}
+	if events[5].Stage != StageScored || events[5].HealthScore.Total != result.Diagnostics.HealthScore.Total || last != 1 {
+		t.Errorf("Expected the score last, got %+v", events[5])
+	}
+
+	// Step deltas add up to the totals
+	var removed int
+	for _, e := range events[1:5] {
+		removed += e.Stats.SimplifiedCount + e.Stats.OutlierRemovedCount
+	}
+	if removed != result.Stats.SimplifiedCount+result.Stats.OutlierRemovedCount {
+		t.Errorf("Step deltas remove %d points, totals %d", removed,
+			result.Stats.SimplifiedCount+result.Stats.OutlierRemovedCount)
+	}
+}
@@ -0,0 +1,267 @@
+// Package jobs runs long diagnoses asynchronously in a bounded worker pool.
+// Clients poll a job's status and progress and fetch its result once done.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// DefaultRetention is how long finished jobs stay available
+const DefaultRetention = time.Hour
+
+var (
+	// ErrQueueFull is returned by Submit when every queue slot is taken
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrShutdown is returned by Submit once Shutdown was called
+	ErrShutdown = errors.New("job queue is shutting down")
+)
+
+// Progress reports the current stage of a job and its completed fraction
+type Progress func(stage string, fraction float64)
+
+// Func is the work of one job. It should return promptly once ctx is
+// canceled. A *model.JobError return is reported to clients as is.
+type Func func(ctx context.Context, progress Progress) (interface{}, error)
+
+// Queue runs jobs with bounded concurrency
+type Queue struct {
+	mu        sync.Mutex
+	jobs      map[string]*job
+	pending   chan *job
+	closed    bool
+	workers   sync.WaitGroup
+	retention time.Duration
+}
+
+// job is a submitted Func and its state
+type job struct {
+	mu     sync.Mutex
+	info   model.JobInfo
+	fn     Func
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewQueue creates a queue running at most workers jobs at once, with room
+// for capacity jobs waiting to start
+func NewQueue(workers, capacity int) *Queue {
+	return NewQueueWithRetention(workers, capacity, DefaultRetention)
+}
+
+// NewQueueWithRetention creates a queue that forgets finished jobs after
+// retention
+func NewQueueWithRetention(workers, capacity int, retention time.Duration) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+
+	q := &Queue{
+		jobs:      make(map[string]*job),
+		pending:   make(chan *job, capacity),
+		retention: retention,
+	}
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Submit queues fn and returns the new job
+func (q *Queue) Submit(fn Func) (model.JobInfo, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return model.JobInfo{}, ErrShutdown
+	}
+	q.prune(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		info: model.JobInfo{
+			ID:        uuid.New().String(),
+			Status:    model.JobQueued,
+			CreatedAt: time.Now(),
+		},
+		fn:     fn,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	select {
+	case q.pending <- j:
+	default:
+		cancel()
+		return model.JobInfo{}, ErrQueueFull
+	}
+
+	q.jobs[j.info.ID] = j
+	return j.snapshot(), nil
+}
+
+// Get returns the job with the given ID
+func (q *Queue) Get(id string) (model.JobInfo, bool) {
+	j := q.lookup(id)
+	if j == nil {
+		return model.JobInfo{}, false
+	}
+	return j.snapshot(), true
+}
+
+// Cancel cancels a queued or running job. A running job reports canceled
+// once its Func has returned; finished jobs are left unchanged.
+func (q *Queue) Cancel(id string) (model.JobInfo, bool) {
+	j := q.lookup(id)
+	if j == nil {
+		return model.JobInfo{}, false
+	}
+
+	j.mu.Lock()
+	if j.info.Status == model.JobQueued {
+		j.finishLocked(model.JobCanceled)
+	}
+	j.mu.Unlock()
+	j.cancel()
+
+	return j.snapshot(), true
+}
+
+// Shutdown stops accepting jobs and waits for the queued and running ones to
+// finish. When ctx expires first, the remaining jobs are canceled and ctx's
+// error is returned.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.pending)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	for _, j := range q.jobs {
+		j.mu.Lock()
+		if j.info.Status == model.JobQueued {
+			j.finishLocked(model.JobCanceled)
+		}
+		j.mu.Unlock()
+		j.cancel()
+	}
+	q.mu.Unlock()
+
+	<-done
+	return ctx.Err()
+}
+
+// lookup finds a job, dropping expired ones first
+func (q *Queue) lookup(id string) *job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(time.Now())
+	return q.jobs[id]
+}
+
+// prune forgets jobs that finished more than the retention period ago.
+// The caller holds q.mu.
+func (q *Queue) prune(now time.Time) {
+	for id, j := range q.jobs {
+		j.mu.Lock()
+		expired := j.info.FinishedAt != nil && now.Sub(*j.info.FinishedAt) > q.retention
+		j.mu.Unlock()
+		if expired {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// work runs queued jobs until the queue is shut down
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for j := range q.pending {
+		j.run()
+	}
+}
+
+// run executes the job unless it was canceled while queued
+func (j *job) run() {
+	defer j.cancel()
+
+	j.mu.Lock()
+	if j.info.Status != model.JobQueued {
+		j.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	j.info.Status = model.JobRunning
+	j.info.StartedAt = &now
+	j.mu.Unlock()
+
+	result, err := j.fn(j.ctx, j.progress)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case err != nil && j.ctx.Err() != nil:
+		j.finishLocked(model.JobCanceled)
+	case err != nil:
+		var jobErr *model.JobError
+		if !errors.As(err, &jobErr) {
+			jobErr = &model.JobError{Code: "internal_error", Message: err.Error()}
+		}
+		j.info.Error = jobErr
+		j.finishLocked(model.JobFailed)
+	default:
+		j.info.Result = result
+		j.info.Progress = 1
+		j.finishLocked(model.JobSucceeded)
+	}
+}
+
+// progress records the job's current stage
+func (j *job) progress(stage string, fraction float64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.info.Status != model.JobRunning {
+		return
+	}
+	j.info.Stage = stage
+	if fraction > j.info.Progress {
+		j.info.Progress = fraction
+	}
+}
+
+// finishLocked moves the job to a final status. The caller holds j.mu.
+func (j *job) finishLocked(status model.JobStatus) {
+	now := time.Now()
+	j.info.Status = status
+	j.info.Stage = ""
+	j.info.FinishedAt = &now
+}
+
+// snapshot returns a copy of the job's state
+func (j *job) snapshot() model.JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// waitFor polls a job until it has finished
+func waitFor(t *testing.T, q *Queue, id string) model.JobInfo {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := q.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.Status.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return model.JobInfo{}
+}
+
+func TestQueue_Submit(t *testing.T) {
+	q := NewQueue(2, 4)
+	defer q.Shutdown(context.Background())
+
+	job, err := q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		progress("detect", 0.5)
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if job.ID == "" || job.Status != model.JobQueued {
+		t.Errorf("Submit() = %+v, want a queued job with an ID", job)
+	}
+
+	job = waitFor(t, q, job.ID)
+	if job.Status != model.JobSucceeded || job.Result != "done" || job.Progress != 1 {
+		t.Errorf("job = %+v, want succeeded with result", job)
+	}
+	if job.StartedAt == nil || job.FinishedAt == nil {
+		t.Errorf("job should record start and finish times")
+	}
+}
+
+func TestQueue_Failure(t *testing.T) {
+	q := NewQueue(1, 1)
+	defer q.Shutdown(context.Background())
+
+	job, _ := q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		return nil, &model.JobError{Code: "invalid_file", Message: "broken"}
+	})
+	job = waitFor(t, q, job.ID)
+	if job.Status != model.JobFailed || job.Error == nil || job.Error.Code != "invalid_file" {
+		t.Errorf("job = %+v, want failed with invalid_file", job)
+	}
+
+	job, _ = q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	job = waitFor(t, q, job.ID)
+	if job.Error == nil || job.Error.Code != "internal_error" {
+		t.Errorf("plain errors should be reported as internal_error, got %+v", job.Error)
+	}
+}
+
+func TestQueue_Cancel(t *testing.T) {
+	q := NewQueue(1, 2)
+	defer q.Shutdown(context.Background())
+
+	started := make(chan struct{})
+	running, _ := q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	queued, _ := q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		t.Error("canceled job should not run")
+		return nil, nil
+	})
+	<-started
+
+	if job, _ := q.Cancel(queued.ID); job.Status != model.JobCanceled {
+		t.Errorf("queued job status = %s, want canceled", job.Status)
+	}
+	q.Cancel(running.ID)
+	if job := waitFor(t, q, running.ID); job.Status != model.JobCanceled {
+		t.Errorf("running job status = %s, want canceled", job.Status)
+	}
+
+	if _, ok := q.Cancel("missing"); ok {
+		t.Errorf("Cancel() should not find unknown jobs")
+	}
+}
+
+func TestQueue_Full(t *testing.T) {
+	q := NewQueue(1, 1)
+	release := make(chan struct{})
+	defer func() {
+		close(release)
+		q.Shutdown(context.Background())
+	}()
+
+	block := func(ctx context.Context, progress Progress) (interface{}, error) {
+		<-release
+		return nil, nil
+	}
+	started := make(chan struct{})
+	q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		close(started)
+		return block(ctx, progress)
+	})
+	<-started
+	if _, err := q.Submit(block); err != nil {
+		t.Fatalf("Submit() error = %v, want a queue slot", err)
+	}
+	if _, err := q.Submit(block); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit() error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestQueue_Shutdown(t *testing.T) {
+	q := NewQueue(1, 4)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		i := i
+		job, _ := q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			return i, nil
+		})
+		ids = append(ids, job.ID)
+	}
+
+	// Draining runs every queued job
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	for _, id := range ids {
+		if job, _ := q.Get(id); job.Status != model.JobSucceeded {
+			t.Errorf("job %s status = %s, want succeeded", id, job.Status)
+		}
+	}
+	if _, err := q.Submit(func(context.Context, Progress) (interface{}, error) { return nil, nil }); !errors.Is(err, ErrShutdown) {
+		t.Errorf("Submit() after Shutdown error = %v, want ErrShutdown", err)
+	}
+}
+
+func TestQueue_ShutdownTimeout(t *testing.T) {
+	q := NewQueue(1, 4)
+
+	running, _ := q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	queued, _ := q.Submit(func(ctx context.Context, progress Progress) (interface{}, error) {
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want DeadlineExceeded", err)
+	}
+
+	for _, id := range []string{running.ID, queued.ID} {
+		if job, _ := q.Get(id); job.Status != model.JobCanceled {
+			t.Errorf("job %s status = %s, want canceled", id, job.Status)
+		}
+	}
+}
+
+func TestQueue_Retention(t *testing.T) {
+	q := NewQueueWithRetention(1, 1, 50*time.Millisecond)
+	defer q.Shutdown(context.Background())
+
+	job, _ := q.Submit(func(context.Context, Progress) (interface{}, error) { return nil, nil })
+	waitFor(t, q, job.ID)
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ok := q.Get(job.ID); ok {
+		t.Errorf("finished job should expire after the retention period")
+	}
+}
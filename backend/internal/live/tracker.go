@@ -0,0 +1,105 @@
+// Package live diagnoses tracks while they are recorded. A Tracker keeps
+// the incremental state of one track: an online forward Kalman pass for the
+// filtered positions and rolling windows for the anomaly checks.
+package live
+
+import (
+	"errors"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/algorithm"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// DefaultMaxGap is the longest interval between two points of one segment.
+// A longer pause starts a new segment and restarts the filter.
+const DefaultMaxGap = 5 * time.Minute
+
+// ErrOutOfOrder is returned by Push for a point recorded before the previous one
+var ErrOutOfOrder = errors.New("point is older than the previous point")
+
+// Tracker diagnoses one live track point by point. It is not safe for
+// concurrent use.
+type Tracker struct {
+	filter   *algorithm.OnlineFilter
+	detector *algorithm.WindowDetector
+	maxGap   time.Duration
+	last     model.Point
+	count    int
+	segment  int
+}
+
+// NewTracker creates a tracker with the thresholds and AdaptiveRTS parameters
+// of options; unset values use the presets of its profile
+func NewTracker(options model.DiagnoseRequest) *Tracker {
+	return NewTrackerWithMaxGap(options, DefaultMaxGap)
+}
+
+// NewTrackerWithMaxGap creates a tracker that starts a new segment after
+// pauses longer than maxGap
+func NewTrackerWithMaxGap(options model.DiagnoseRequest, maxGap time.Duration) *Tracker {
+	// A live track has no history to infer its mode from, so auto uses the
+	// default profile
+	profile, _ := algorithm.ResolveProfile(options.Profile, nil)
+	options = options.WithProfile(profile)
+
+	detector := algorithm.NewDetector()
+	detector.MaxSpeed = orDefault(options.Thresholds.MaxSpeed, profile.Thresholds.MaxSpeed)
+	detector.MaxAcceleration = orDefault(options.Thresholds.MaxAcceleration, profile.Thresholds.MaxAcceleration)
+	detector.MaxJump = orDefault(options.Thresholds.MaxJump, profile.Thresholds.MaxJump)
+
+	rts := algorithm.NewAdaptiveRTSWithParameters(options.Parameters.AdaptiveRTS)
+	return &Tracker{
+		filter:   algorithm.NewOnlineFilter(rts),
+		detector: algorithm.NewWindowDetector(detector),
+		maxGap:   maxGap,
+	}
+}
+
+// Count returns how many points the tracker has accepted
+func (t *Tracker) Count() int {
+	return t.count
+}
+
+// Push diagnoses the next point and returns its filtered position and the
+// anomalies it reveals. An acceleration anomaly names the previous point.
+// Points older than the previous point are rejected with ErrOutOfOrder.
+func (t *Tracker) Push(p model.Point) (model.LivePosition, []model.LiveAnomaly, error) {
+	if t.count > 0 {
+		if p.Time.Before(t.last.Time) {
+			return model.LivePosition{}, nil, ErrOutOfOrder
+		}
+		if p.Time.Sub(t.last.Time) > t.maxGap {
+			t.segment++
+		}
+	}
+
+	p.Index = t.count
+	p.Track = 0
+	p.Segment = t.segment
+	t.count++
+	t.last = p
+
+	filtered := t.filter.Push(p)
+	position := model.LivePosition{
+		Index:   p.Index,
+		Time:    p.Time.Unix(),
+		Lat:     filtered.Lat,
+		Lon:     filtered.Lon,
+		RawLat:  p.Lat,
+		RawLon:  p.Lon,
+		Speed:   filtered.Speed,
+		Bearing: filtered.Bearing,
+		Segment: p.Segment,
+	}
+
+	return position, t.detector.Push(p), nil
+}
+
+// orDefault returns v, or def when v is unset
+func orDefault(v, def float64) float64 {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,91 @@
+package live
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func point(lat, lon float64, t time.Time) model.Point {
+	return model.Point{Lat: lat, Lon: lon, Time: t}
+}
+
+func TestTracker_Push(t *testing.T) {
+	tracker := NewTracker(model.DefaultRequest())
+	base := time.Unix(1700000000, 0)
+
+	for i := 0; i < 10; i++ {
+		position, anomalies, err := tracker.Push(point(39.9+float64(i)*0.0001, 116.4, base.Add(time.Duration(i)*time.Second)))
+		if err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+		if position.Index != i || position.Time != base.Unix()+int64(i) {
+			t.Errorf("position = %+v, want index %d", position, i)
+		}
+		if len(anomalies) != 0 {
+			t.Errorf("steady point %d reported %+v", i, anomalies)
+		}
+	}
+	if tracker.Count() != 10 {
+		t.Errorf("Count() = %d, want 10", tracker.Count())
+	}
+
+	// A 5 km jump in one second
+	position, anomalies, _ := tracker.Push(point(39.95, 116.4, base.Add(10*time.Second)))
+	if position.RawLat != 39.95 || position.Lat == 39.95 {
+		t.Errorf("position = %+v, want the filtered estimate beside the raw fix", position)
+	}
+	found := make(map[model.AnomalyType]bool)
+	for _, a := range anomalies {
+		found[a.Type] = true
+	}
+	if !found[model.AnomalyJump] || !found[model.AnomalySpeedAnomaly] {
+		t.Errorf("anomalies = %+v, want a jump and a speed anomaly", anomalies)
+	}
+}
+
+func TestTracker_Thresholds(t *testing.T) {
+	options := model.DefaultRequest()
+	options.Thresholds.MaxSpeed = 20
+	tracker := NewTracker(options)
+	base := time.Unix(1700000000, 0)
+
+	// About 40 km/h
+	tracker.Push(point(39.9, 116.4, base))
+	_, anomalies, _ := tracker.Push(point(39.9001, 116.4, base.Add(time.Second)))
+	if len(anomalies) != 1 || anomalies[0].Type != model.AnomalySpeedAnomaly || anomalies[0].Threshold != 20 {
+		t.Errorf("anomalies = %+v, want a speed anomaly over 20 km/h", anomalies)
+	}
+}
+
+func TestTracker_Segments(t *testing.T) {
+	tracker := NewTrackerWithMaxGap(model.DefaultRequest(), time.Minute)
+	base := time.Unix(1700000000, 0)
+
+	tracker.Push(point(39.9, 116.4, base))
+	tracker.Push(point(39.9001, 116.4, base.Add(time.Second)))
+
+	// Far away after a pause: a new segment, not a jump
+	position, anomalies, _ := tracker.Push(point(40.5, 116.4, base.Add(time.Hour)))
+	if position.Segment != 1 || position.Lat != 40.5 {
+		t.Errorf("position = %+v, want the raw fix starting segment 1", position)
+	}
+	if len(anomalies) != 0 {
+		t.Errorf("anomalies = %+v, want none across a pause", anomalies)
+	}
+}
+
+func TestTracker_OutOfOrder(t *testing.T) {
+	tracker := NewTracker(model.DefaultRequest())
+	base := time.Unix(1700000000, 0)
+
+	tracker.Push(point(39.9, 116.4, base))
+	if _, _, err := tracker.Push(point(39.9, 116.4, base.Add(-time.Second))); !errors.Is(err, ErrOutOfOrder) {
+		t.Errorf("Push() error = %v, want ErrOutOfOrder", err)
+	}
+	if tracker.Count() != 1 {
+		t.Errorf("rejected points should not be counted, Count() = %d", tracker.Count())
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests.", "route", "code")
+	r.NewCounter("rejected_total", "Rejected requests.")
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("queue_length", "Queued jobs.", func() float64 { return 3 })
+
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "500")
+	requests.Inc("/a", "500")
+	requests.Inc(`/"q"`, "200")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+
+	var sb strings.Builder
+	if err := r.WriteText(&sb); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	want := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/\"q\"",code="200"} 1
+requests_total{route="/a",code="500"} 3
+requests_total{route="/b",code="200"} 1
+# HELP rejected_total Rejected requests.
+# TYPE rejected_total counter
+rejected_total 0
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 5.55
+latency_seconds_count{route="/a"} 3
+# HELP queue_length Queued jobs.
+# TYPE queue_length gauge
+queue_length 3
+`
+	if got := sb.String(); got != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := requests.Value("/a", "500"); got != 3 {
+		t.Errorf("Value() = %v, want 3", got)
+	}
+	if got := latency.Count("/b"); got != 0 {
+		t.Errorf("Count() = %v, want 0", got)
+	}
+}
+
+func TestCounter_WrongLabelCount(t *testing.T) {
+	c := NewRegistry().NewCounter("requests_total", "Requests.", "route")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for missing label values")
+		}
+	}()
+	c.Inc()
+}
@@ -1,17 +1,22 @@
 package model
 
+import "fmt"
+
 // PointsRequest represents the unified diagnose request using binary array format
-// Points format: [[lat, lon, time, ele?], ...] where ele is optional
+// Points format: [[lat, lon, time, ele?, speed?, bearing?, accuracy?], ...] where trailing values are optional
 type PointsRequest struct {
-	Points  [][]float64     `json:"points"`  // [[lat, lon, time, ele?], ...]
+	Points  [][]float64     `json:"points"`  // [[lat, lon, time, ele?, speed?, bearing?, accuracy?], ...]
 	Options DiagnoseRequest `json:"options"`
 }
 
 // DiagnoseRequest represents the diagnose request
 type DiagnoseRequest struct {
-	Algorithms AlgorithmOptions `json:"algorithms"`
-	Thresholds ThresholdOptions `json:"thresholds"`
-	Output     OutputOptions    `json:"output"`
+	Profile    string              `json:"profile,omitempty"` // Transport mode presets; see Profiles
+	Algorithms AlgorithmOptions    `json:"algorithms"`
+	Parameters AlgorithmParameters `json:"parameters"`
+	Steps      []StepConfig        `json:"steps,omitempty"` // Ordered correction steps; overrides algorithms
+	Thresholds ThresholdOptions    `json:"thresholds"`
+	Output     OutputOptions       `json:"output"`
 }
 
 // AlgorithmOptions represents algorithm configuration
@@ -22,7 +27,8 @@ type AlgorithmOptions struct {
 	OutlierRemoval   bool `json:"outlierRemoval"`
 }
 
-// ThresholdOptions represents threshold configuration
+// ThresholdOptions represents threshold configuration. Zero values take the
+// preset of the request's profile.
 type ThresholdOptions struct {
 	MaxSpeed        float64 `json:"maxSpeed"`
 	MaxAcceleration float64 `json:"maxAcceleration"`
@@ -36,7 +42,143 @@ type OutputOptions struct {
 	SimplifyEpsilon  float64 `json:"simplifyEpsilon"`
 }
 
-// DefaultRequest returns default request options
+// AlgorithmParameters tunes each correction algorithm. Zero values use the
+// defaults from DefaultParameters.
+type AlgorithmParameters struct {
+	AdaptiveRTS         RTSParameters      `json:"adaptiveRTS"`
+	SplineInterpolation SplineParameters   `json:"splineInterpolation"`
+	Simplification      SimplifyParameters `json:"simplification"`
+}
+
+// RTSParameters tunes the AdaptiveRTS smoother
+type RTSParameters struct {
+	ProcessNoise     float64 `json:"processNoise,omitempty"`     // White-noise acceleration density, m²/s³
+	MeasurementNoise float64 `json:"measurementNoise,omitempty"` // Initial position variance per axis, m²
+	VBAlpha          float64 `json:"vbAlpha,omitempty"`          // Prior strength of the noise estimate
+	VBBeta           float64 `json:"vbBeta,omitempty"`           // Prior scale of the noise estimate, m²; vbAlpha × measurementNoise when unset
+}
+
+// SplineParameters tunes gap interpolation
+type SplineParameters struct {
+	GapSeconds    int `json:"gapSeconds,omitempty"`    // Interval that counts as a gap
+	MaxGapSeconds int `json:"maxGapSeconds,omitempty"` // Longest gap that is filled
+	MaxPoints     int `json:"maxPoints,omitempty"`     // Most points added per gap
+}
+
+// SimplifyParameters tunes Douglas-Peucker simplification
+type SimplifyParameters struct {
+	Epsilon       float64 `json:"epsilon,omitempty"`       // Tolerance in meters; output.simplifyEpsilon when unset
+	ConsiderNoise *bool   `json:"considerNoise,omitempty"` // Widen the tolerance on noisy tracks
+}
+
+// DefaultParameters returns the default algorithm parameters
+func DefaultParameters() AlgorithmParameters {
+	considerNoise := true
+	return AlgorithmParameters{
+		AdaptiveRTS: RTSParameters{
+			ProcessNoise:     0.5,  // m²/s³
+			MeasurementNoise: 25.0, // m², about 5 m 1-sigma
+			VBAlpha:          2.0,
+		},
+		SplineInterpolation: SplineParameters{
+			GapSeconds:    10,
+			MaxGapSeconds: 60,
+			MaxPoints:     100,
+		},
+		Simplification: SimplifyParameters{
+			Epsilon:       1.0, // meters
+			ConsiderNoise: &considerNoise,
+		},
+	}
+}
+
+// Validate checks every set parameter against its bounds
+func (p AlgorithmParameters) Validate() *ErrorDetails {
+	groups := []struct {
+		field  string
+		specs  []ParameterSpec
+		values map[string]interface{}
+	}{
+		{"parameters." + StepAdaptiveRTS, RTSParameterSpecs(), parameterMap(p.AdaptiveRTS)},
+		{"parameters." + StepSplineInterpolation, SplineParameterSpecs(), parameterMap(p.SplineInterpolation)},
+		{"parameters." + StepSimplification, SimplifyParameterSpecs(), parameterMap(p.Simplification)},
+	}
+
+	for _, g := range groups {
+		if details := ValidateParameters(g.field, g.specs, g.values); details != nil {
+			return details
+		}
+	}
+
+	spline := p.WithDefaults().SplineInterpolation
+	if spline.MaxGapSeconds < spline.GapSeconds {
+		return &ErrorDetails{
+			Field: "parameters.splineInterpolation.maxGapSeconds",
+			Message: fmt.Sprintf("maxGapSeconds (%d) must not be less than gapSeconds (%d)",
+				spline.MaxGapSeconds, spline.GapSeconds),
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the output options. simplifyEpsilon stands in for an unset
+// parameters.simplification.epsilon, so it shares its bounds.
+func (o OutputOptions) Validate() *ErrorDetails {
+	if o.SimplifyEpsilon == 0 {
+		return nil
+	}
+	var specs []ParameterSpec
+	for _, spec := range SimplifyParameterSpecs() {
+		if spec.Name == "epsilon" {
+			spec.Name = "simplifyEpsilon"
+			specs = append(specs, spec)
+		}
+	}
+	return ValidateParameters("output", specs, map[string]interface{}{"simplifyEpsilon": o.SimplifyEpsilon})
+}
+
+// WithDefaults fills unset parameters from DefaultParameters
+func (p AlgorithmParameters) WithDefaults() AlgorithmParameters {
+	d := DefaultParameters()
+
+	setFloat := func(v *float64, def float64) {
+		if *v == 0 {
+			*v = def
+		}
+	}
+	setInt := func(v *int, def int) {
+		if *v == 0 {
+			*v = def
+		}
+	}
+
+	setFloat(&p.AdaptiveRTS.ProcessNoise, d.AdaptiveRTS.ProcessNoise)
+	setFloat(&p.AdaptiveRTS.MeasurementNoise, d.AdaptiveRTS.MeasurementNoise)
+	setFloat(&p.AdaptiveRTS.VBAlpha, d.AdaptiveRTS.VBAlpha)
+	setInt(&p.SplineInterpolation.GapSeconds, d.SplineInterpolation.GapSeconds)
+	setInt(&p.SplineInterpolation.MaxGapSeconds, d.SplineInterpolation.MaxGapSeconds)
+	setInt(&p.SplineInterpolation.MaxPoints, d.SplineInterpolation.MaxPoints)
+	setFloat(&p.Simplification.Epsilon, d.Simplification.Epsilon)
+	if p.Simplification.ConsiderNoise == nil {
+		p.Simplification.ConsiderNoise = d.Simplification.ConsiderNoise
+	}
+
+	return p
+}
+
+// ResolvedParameters returns the parameters the algorithms run with: the
+// request's values, output.simplifyEpsilon for an unset epsilon, then defaults
+func (r DiagnoseRequest) ResolvedParameters() AlgorithmParameters {
+	p := r.Parameters
+	if p.Simplification.Epsilon == 0 {
+		p.Simplification.Epsilon = r.Output.SimplifyEpsilon
+	}
+	return p.WithDefaults()
+}
+
+// DefaultRequest returns default request options. Thresholds are left unset
+// so that the profile presets them.
 func DefaultRequest() DiagnoseRequest {
 	return DiagnoseRequest{
 		Algorithms: AlgorithmOptions{
@@ -45,12 +187,6 @@ func DefaultRequest() DiagnoseRequest {
 			Simplification:     true,
 			OutlierRemoval:     true,
 		},
-		Thresholds: ThresholdOptions{
-			MaxSpeed:        120.0,  // km/h
-			MaxAcceleration: 10.0,   // m/s²
-			MaxJump:         500.0,  // meters
-			DriftThreshold:  0.0001, // degrees
-		},
 		Output: OutputOptions{
 			IncludePoints:   true,
 			SimplifyEpsilon: 1.0,    // meters
@@ -61,8 +197,9 @@ func DefaultRequest() DiagnoseRequest {
 // DiagnoseResponse represents the diagnose response
 type DiagnoseResponse struct {
 	Success bool          `json:"success"`
-	Data    *Data         `json:"data,omitempty"`
-	Error   string        `json:"error,omitempty"`
+	Data    *Data           `json:"data,omitempty"`  // Empty when Files is set
+	Files   []FileDiagnosis `json:"files,omitempty"` // One entry per track when the upload is an archive
+	Error   string          `json:"error,omitempty"`
 	Details *ErrorDetails `json:"details,omitempty"`
 	Meta    *ResponseMeta `json:"meta,omitempty"`
 }
@@ -73,9 +210,19 @@ type Data struct {
 	Original   TrajectoryStats    `json:"original"`
 	Corrected  TrajectoryStats    `json:"corrected"`
 	Diagnostics DiagnosticsInfo   `json:"diagnostics"`
+	Segments   []ModeSegment      `json:"segments,omitempty"` // Transport mode segments of the input track
+	Stops      []Stop             `json:"stops,omitempty"`    // Stay points of the input track
 	Points     []Point            `json:"points,omitempty"`
 }
 
+// FileDiagnosis represents the diagnosis of one track file in an archive
+type FileDiagnosis struct {
+	Name   string `json:"name"`
+	Format string `json:"format,omitempty"`
+	Data   *Data  `json:"data,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
 // DiagnosticsInfo represents diagnostic information
 type DiagnosticsInfo struct {
 	NormalPoints       int              `json:"normalPoints"`
@@ -87,6 +234,8 @@ type DiagnosticsInfo struct {
 	Anomalies          []Anomaly        `json:"anomalies"`
 	Algorithms         []AlgorithmInfo  `json:"algorithms"`
 	HealthScore        HealthScore      `json:"healthScore"`
+	Profile            string           `json:"profile"`                   // Transport mode profile used
+	ProfileInferred    bool             `json:"profileInferred,omitempty"` // Profile chosen by auto
 }
 
 // AlgorithmInfo represents algorithm execution info
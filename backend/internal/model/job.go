@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// JobStatus represents the state of an asynchronous job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Done reports whether the job has finished
+func (s JobStatus) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCanceled
+}
+
+// JobInfo is a snapshot of an asynchronous job
+type JobInfo struct {
+	ID         string      `json:"id"`
+	Status     JobStatus   `json:"status"`
+	Progress   float64     `json:"progress"`        // Completed fraction, 0 to 1
+	Stage      string      `json:"stage,omitempty"` // Current stage while running
+	CreatedAt  time.Time   `json:"createdAt"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Result     interface{} `json:"result,omitempty"` // Set once the job succeeded
+	Error      *JobError   `json:"error,omitempty"`  // Set once the job failed
+}
+
+// JobError describes why a job failed
+type JobError struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details *ErrorDetails `json:"details,omitempty"`
+}
+
+func (e *JobError) Error() string {
+	return e.Message
+}
+
+// JobResponse is the response of the job endpoints
+type JobResponse struct {
+	Success bool          `json:"success"`
+	Job     JobInfo       `json:"job"`
+	Meta    *ResponseMeta `json:"meta,omitempty"`
+}
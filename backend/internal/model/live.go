@@ -0,0 +1,54 @@
+package model
+
+// LiveRequest is a message from a live tracking client. Points use the
+// PointsRequest format, [[lat, lon, time, ele?, speed?, bearing?, accuracy?], ...],
+// in recording order.
+type LiveRequest struct {
+	Points  [][]float64      `json:"points"`
+	Options *DiagnoseRequest `json:"options,omitempty"` // Thresholds and parameters; first message only
+}
+
+// Live event types
+const (
+	LiveEventPosition = "position"
+	LiveEventAnomaly  = "anomaly"
+	LiveEventError    = "error"
+)
+
+// LiveEvent is a message pushed to a live tracking client. Exactly one of
+// Position, Anomaly and Error is set, as named by Type.
+type LiveEvent struct {
+	Type     string        `json:"type"`
+	Position *LivePosition `json:"position,omitempty"`
+	Anomaly  *LiveAnomaly  `json:"anomaly,omitempty"`
+	Error    *LiveError    `json:"error,omitempty"`
+}
+
+// LivePosition is the filtered position of a live point
+type LivePosition struct {
+	Index   int     `json:"index"` // Position of the point in the stream, 0-based
+	Time    int64   `json:"time"`  // Unix seconds
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	RawLat  float64 `json:"rawLat"`
+	RawLon  float64 `json:"rawLon"`
+	Speed   float64 `json:"speed"`   // Estimated speed, km/h
+	Bearing float64 `json:"bearing"` // Estimated heading, degrees
+	Segment int     `json:"segment"` // Incremented after each recording pause
+}
+
+// LiveAnomaly reports an anomalous point of a live track
+type LiveAnomaly struct {
+	Index     int         `json:"index"` // Stream position of the anomalous point
+	Type      AnomalyType `json:"anomalyType"`
+	Severity  Severity    `json:"severity"`
+	Value     float64     `json:"value"`     // Speed in km/h, jump in m or acceleration in m/s²
+	Threshold float64     `json:"threshold"` // Limit the value exceeded
+}
+
+// LiveError reports a message that could not be processed
+type LiveError struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details *ErrorDetails `json:"details,omitempty"`
+}
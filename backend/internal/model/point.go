@@ -13,6 +13,7 @@ type Point struct {
 	Time           time.Time `json:"time"`
 	Elevation      float64   `json:"elevation,omitempty"`
 	Status         PointStatus `json:"status"`
+	Flags          []PointStatus `json:"flags,omitempty"` // Every anomaly status detected, strongest first
 	IsInterpolated bool      `json:"isInterpolated,omitempty"`
 	OriginalLat    float64   `json:"originalLat,omitempty"`
 	OriginalLon    float64   `json:"originalLon,omitempty"`
@@ -20,6 +21,70 @@ type Point struct {
 	Bearing        float64   `json:"bearing,omitempty"`
 	Acceleration   float64   `json:"acceleration,omitempty"`
 	FixedBy        string    `json:"fixedBy,omitempty"` // Which algorithm fixed this point
+	// Receiver quality indicators (NMEA GGA/GSA, GPX)
+	FixQuality     int       `json:"fixQuality,omitempty"` // GGA fix quality (0 when unknown)
+	FixType        string    `json:"fixType,omitempty"`    // GPX fix: none, 2d, 3d, dgps or pps
+	HDOP           float64   `json:"hdop,omitempty"`
+	VDOP           float64   `json:"vdop,omitempty"`
+	PDOP           float64   `json:"pdop,omitempty"`
+	Satellites     int       `json:"satellites,omitempty"`
+	Accuracy       float64   `json:"accuracy,omitempty"` // Horizontal accuracy reported by the device (e.g. Android), meters
+	Course         *float64  `json:"course,omitempty"` // Heading reported by the device, degrees; nil when absent
+	// Sensor data recorded alongside the fix
+	HeartRate      int       `json:"heartRate,omitempty"`   // bpm
+	Cadence        int       `json:"cadence,omitempty"`     // rpm
+	Temperature    *float64  `json:"temperature,omitempty"` // Ambient temperature, °C; nil when absent
+	Distance       float64   `json:"distance,omitempty"`  // Cumulative distance reported by the device, meters
+	Lap            int       `json:"lap,omitempty"`       // Lap number (TCX), 0-based
+	// Source file structure (GPX trk/trkseg, KML Placemark/MultiGeometry), 0-based
+	Track          int       `json:"track,omitempty"`
+	Segment        int       `json:"segment,omitempty"` // Segment within the track
+}
+
+// UserEquivalentRangeError is the typical GNSS ranging error in meters used
+// to turn dilution of precision into an accuracy estimate
+const UserEquivalentRangeError = 5.0
+
+// EstimatedAccuracy returns the horizontal 1-sigma accuracy of a fix in
+// meters, from the reported accuracy, HDOP or satellite count in that order.
+// It returns 0 when the point carries none of them.
+func EstimatedAccuracy(p Point) float64 {
+	switch {
+	case p.Accuracy > 0:
+		return p.Accuracy
+	case p.HDOP > 0:
+		return p.HDOP * UserEquivalentRangeError
+	case p.Satellites >= 4:
+		// Rough HDOP from geometry: 4 satellites ~ 4, 12 satellites ~ 1.4
+		return 4 / math.Sqrt(float64(p.Satellites-3)) * UserEquivalentRangeError
+	}
+	return 0
+}
+
+// SameSegment reports whether two points belong to the same track segment.
+// Consecutive points in different segments are separated by a recording
+// pause and must not be connected.
+func SameSegment(a, b Point) bool {
+	return a.Track == b.Track && a.Segment == b.Segment
+}
+
+// SplitSegments splits points into runs of the same track segment. The
+// returned slices share the backing array of points.
+func SplitSegments(points []Point) [][]Point {
+	if len(points) == 0 {
+		return nil
+	}
+
+	var segments [][]Point
+	start := 0
+	for i := 1; i < len(points); i++ {
+		if !SameSegment(points[i-1], points[i]) {
+			segments = append(segments, points[start:i])
+			start = i
+		}
+	}
+
+	return append(segments, points[start:])
 }
 
 // PointStatus represents the status of a trajectory point
@@ -35,6 +100,51 @@ const (
 	StatusInterpolated PointStatus = "interpolated"
 )
 
+// anomalyStatuses ranks the statuses a point can get from detected
+// anomalies, strongest first. A jump means the fix itself is wrong, while
+// speed and acceleration anomalies may only be its consequence and drift is
+// a bias the smoother can pull back.
+var anomalyStatuses = []PointStatus{StatusJump, StatusSpeedAnomaly, StatusAccelAnomaly, StatusDrift}
+
+// StatusForAnomaly returns the point status of an anomaly type. It returns
+// false for anomalies that describe the track rather than single points,
+// such as missing data and density anomalies.
+func StatusForAnomaly(t AnomalyType) (PointStatus, bool) {
+	switch t {
+	case AnomalyJump:
+		return StatusJump, true
+	case AnomalySpeedAnomaly:
+		return StatusSpeedAnomaly, true
+	case AnomalyAccelAnomaly:
+		return StatusAccelAnomaly, true
+	case AnomalyDrift:
+		return StatusDrift, true
+	}
+	return "", false
+}
+
+// IsAnomaly reports whether the status comes from a detected anomaly
+func (s PointStatus) IsAnomaly() bool {
+	return s.rank() < len(anomalyStatuses)
+}
+
+// Outranks reports whether s takes precedence over other when a point has
+// both. Anomaly statuses outrank all others.
+func (s PointStatus) Outranks(other PointStatus) bool {
+	return s.rank() < other.rank()
+}
+
+// rank is the position of s in anomalyStatuses, past the end for other
+// statuses
+func (s PointStatus) rank() int {
+	for i, status := range anomalyStatuses {
+		if s == status {
+			return i
+		}
+	}
+	return len(anomalyStatuses)
+}
+
 // Bounds represents geographic bounds
 type Bounds struct {
 	North float64 `json:"north"`
@@ -296,6 +406,11 @@ func CalculateStats(points []Point) TrajectoryStats {
 	validSpeedCount := 0
 
 	for i := 1; i < len(points); i++ {
+		// Pauses between segments are not travelled distance
+		if !SameSegment(points[i-1], points[i]) {
+			continue
+		}
+
 		segDist := HaversineDistance(
 			points[i-1].Lat, points[i-1].Lon,
 			points[i].Lat, points[i].Lon,
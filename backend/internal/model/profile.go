@@ -0,0 +1,146 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transport mode profiles
+const (
+	ProfileWalk   = "walk"
+	ProfileBike   = "bike"
+	ProfileCar    = "car"
+	ProfileTrain  = "train"
+	ProfileFlight = "flight"
+	ProfileBoat   = "boat"
+	ProfileAuto   = "auto" // Inferred from the track's speeds and accelerations
+)
+
+// DefaultProfile is used when a request names no profile. Its presets are
+// the default thresholds and the values of DefaultParameters.
+const DefaultProfile = ProfileCar
+
+// Profile presets detection thresholds and algorithm parameters for one
+// transport mode
+type Profile struct {
+	Name       string              `json:"name"`
+	Thresholds ThresholdOptions    `json:"thresholds"`
+	Parameters AlgorithmParameters `json:"parameters"`
+}
+
+// profiles lists every transport mode profile. Faster modes get wider
+// thresholds, a livelier motion model and longer fillable gaps (tunnels,
+// sparse logging at cruise); walkers and boats change speed slowly and get a
+// stiffer one.
+var profiles = []Profile{
+	{
+		Name:       ProfileWalk,
+		Thresholds: ThresholdOptions{MaxSpeed: 15, MaxAcceleration: 3, MaxJump: 100, DriftThreshold: 0.0001},
+		Parameters: AlgorithmParameters{
+			AdaptiveRTS:         RTSParameters{ProcessNoise: 0.1, MeasurementNoise: 25},
+			SplineInterpolation: SplineParameters{GapSeconds: 10, MaxGapSeconds: 120},
+		},
+	},
+	{
+		Name:       ProfileBike,
+		Thresholds: ThresholdOptions{MaxSpeed: 60, MaxAcceleration: 5, MaxJump: 200, DriftThreshold: 0.0001},
+		Parameters: AlgorithmParameters{
+			AdaptiveRTS:         RTSParameters{ProcessNoise: 0.3, MeasurementNoise: 25},
+			SplineInterpolation: SplineParameters{GapSeconds: 10, MaxGapSeconds: 90},
+		},
+	},
+	{
+		Name:       ProfileCar,
+		Thresholds: ThresholdOptions{MaxSpeed: 120, MaxAcceleration: 10, MaxJump: 500, DriftThreshold: 0.0001},
+		Parameters: AlgorithmParameters{
+			AdaptiveRTS:         RTSParameters{ProcessNoise: 0.5, MeasurementNoise: 25},
+			SplineInterpolation: SplineParameters{GapSeconds: 10, MaxGapSeconds: 60},
+		},
+	},
+	{
+		Name:       ProfileTrain,
+		Thresholds: ThresholdOptions{MaxSpeed: 400, MaxAcceleration: 3, MaxJump: 2000, DriftThreshold: 0.0001},
+		Parameters: AlgorithmParameters{
+			AdaptiveRTS:         RTSParameters{ProcessNoise: 0.2, MeasurementNoise: 25},
+			SplineInterpolation: SplineParameters{GapSeconds: 10, MaxGapSeconds: 300},
+		},
+	},
+	{
+		Name:       ProfileFlight,
+		Thresholds: ThresholdOptions{MaxSpeed: 1100, MaxAcceleration: 10, MaxJump: 20000, DriftThreshold: 0.0001},
+		Parameters: AlgorithmParameters{
+			AdaptiveRTS:         RTSParameters{ProcessNoise: 2, MeasurementNoise: 100},
+			SplineInterpolation: SplineParameters{GapSeconds: 30, MaxGapSeconds: 900},
+		},
+	},
+	{
+		Name:       ProfileBoat,
+		Thresholds: ThresholdOptions{MaxSpeed: 90, MaxAcceleration: 2, MaxJump: 300, DriftThreshold: 0.0001},
+		Parameters: AlgorithmParameters{
+			AdaptiveRTS:         RTSParameters{ProcessNoise: 0.2, MeasurementNoise: 25},
+			SplineInterpolation: SplineParameters{GapSeconds: 10, MaxGapSeconds: 300},
+		},
+	},
+}
+
+// Profiles returns every transport mode profile
+func Profiles() []Profile {
+	return append([]Profile(nil), profiles...)
+}
+
+// LookupProfile returns the profile with the given name; an empty name
+// selects DefaultProfile
+func LookupProfile(name string) (Profile, bool) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// ValidateProfile checks that name is a profile, ProfileAuto or empty
+func ValidateProfile(name string) *ErrorDetails {
+	if _, ok := LookupProfile(name); ok || name == ProfileAuto {
+		return nil
+	}
+	names := make([]string, 0, len(profiles)+1)
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	names = append(names, ProfileAuto)
+	return &ErrorDetails{
+		Field:   "profile",
+		Message: fmt.Sprintf("Unknown profile %q. Supported profiles: %s", name, strings.Join(names, ", ")),
+	}
+}
+
+// WithProfile returns the request with p's presets. Unset thresholds and
+// parameters take the preset; values the client set win, even when they
+// equal another profile's preset.
+func (r DiagnoseRequest) WithProfile(p Profile) DiagnoseRequest {
+	unset := func(v *float64, value float64) {
+		if *v == 0 {
+			*v = value
+		}
+	}
+	unsetInt := func(v *int, value int) {
+		if *v == 0 {
+			*v = value
+		}
+	}
+	unset(&r.Thresholds.MaxSpeed, p.Thresholds.MaxSpeed)
+	unset(&r.Thresholds.MaxAcceleration, p.Thresholds.MaxAcceleration)
+	unset(&r.Thresholds.MaxJump, p.Thresholds.MaxJump)
+	unset(&r.Thresholds.DriftThreshold, p.Thresholds.DriftThreshold)
+	unset(&r.Parameters.AdaptiveRTS.ProcessNoise, p.Parameters.AdaptiveRTS.ProcessNoise)
+	unset(&r.Parameters.AdaptiveRTS.MeasurementNoise, p.Parameters.AdaptiveRTS.MeasurementNoise)
+	unsetInt(&r.Parameters.SplineInterpolation.GapSeconds, p.Parameters.SplineInterpolation.GapSeconds)
+	unsetInt(&r.Parameters.SplineInterpolation.MaxGapSeconds, p.Parameters.SplineInterpolation.MaxGapSeconds)
+
+	r.Profile = p.Name
+	return r
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ModeSegment is a stretch of a trajectory travelled in one transport mode
+type ModeSegment struct {
+	Mode         string    `json:"mode"`       // Profile name of the mode
+	StartIndex   int       `json:"startIndex"` // First point, as an index into the input track
+	EndIndex     int       `json:"endIndex"`   // Last point, inclusive
+	PointCount   int       `json:"pointCount"`
+	StartTime    time.Time `json:"startTime"`
+	EndTime      time.Time `json:"endTime"`
+	DurationSecs int64     `json:"durationSeconds"`
+	Distance     float64   `json:"distance"` // meters
+	AvgSpeed     float64   `json:"avgSpeed"` // km/h
+}
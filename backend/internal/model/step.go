@@ -0,0 +1,248 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Names of the built-in correction steps
+const (
+	StepAdaptiveRTS         = "adaptiveRTS"
+	StepSplineInterpolation = "splineInterpolation"
+	StepSimplification      = "simplification"
+	StepOutlierRemoval      = "outlierRemoval"
+)
+
+// StepConfig selects a registered correction step and its parameters
+type StepConfig struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Parameter types used in ParameterSpec
+const (
+	ParameterNumber  = "number"
+	ParameterInteger = "integer"
+	ParameterBoolean = "boolean"
+)
+
+// ParameterSpec describes one tunable step parameter
+type ParameterSpec struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Min         float64     `json:"min,omitempty"`
+	Max         float64     `json:"max,omitempty"`
+	Unit        string      `json:"unit,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// RTSParameterSpecs describes RTSParameters
+func RTSParameterSpecs() []ParameterSpec {
+	d := DefaultParameters().AdaptiveRTS
+	return []ParameterSpec{
+		{Name: "processNoise", Type: ParameterNumber, Default: d.ProcessNoise, Min: 1e-4, Max: 1000, Unit: "m²/s³",
+			Description: "White-noise acceleration density"},
+		{Name: "measurementNoise", Type: ParameterNumber, Default: d.MeasurementNoise, Min: 0.01, Max: 1e6, Unit: "m²",
+			Description: "Initial position variance per axis"},
+		{Name: "vbAlpha", Type: ParameterNumber, Default: d.VBAlpha, Min: 0.5, Max: 1000,
+			Description: "Prior strength of the noise estimate"},
+		{Name: "vbBeta", Type: ParameterNumber, Default: d.VBBeta, Min: 0.01, Max: 1e9, Unit: "m²",
+			Description: "Prior scale of the noise estimate; vbAlpha × measurementNoise when unset"},
+	}
+}
+
+// SplineParameterSpecs describes SplineParameters
+func SplineParameterSpecs() []ParameterSpec {
+	d := DefaultParameters().SplineInterpolation
+	return []ParameterSpec{
+		{Name: "gapSeconds", Type: ParameterInteger, Default: d.GapSeconds, Min: 1, Max: 3600, Unit: "s",
+			Description: "Interval that counts as a gap"},
+		{Name: "maxGapSeconds", Type: ParameterInteger, Default: d.MaxGapSeconds, Min: 1, Max: 86400, Unit: "s",
+			Description: "Longest gap that is filled"},
+		{Name: "maxPoints", Type: ParameterInteger, Default: d.MaxPoints, Min: 1, Max: 1000,
+			Description: "Most points added per gap"},
+	}
+}
+
+// SimplifyParameterSpecs describes SimplifyParameters
+func SimplifyParameterSpecs() []ParameterSpec {
+	d := DefaultParameters().Simplification
+	return []ParameterSpec{
+		{Name: "epsilon", Type: ParameterNumber, Default: d.Epsilon, Min: 0.01, Max: 1000, Unit: "m",
+			Description: "Simplification tolerance"},
+		{Name: "considerNoise", Type: ParameterBoolean, Default: *d.ConsiderNoise,
+			Description: "Widen the tolerance on noisy tracks"},
+	}
+}
+
+// ValidateParameters checks values against specs; field prefixes the
+// reported field name
+func ValidateParameters(field string, specs []ParameterSpec, values map[string]interface{}) *ErrorDetails {
+	known := make(map[string]ParameterSpec, len(specs))
+	for _, spec := range specs {
+		known[spec.Name] = spec
+	}
+
+	for name, value := range values {
+		spec, ok := known[name]
+		if !ok {
+			return &ErrorDetails{
+				Field:   field + "." + name,
+				Message: fmt.Sprintf("unknown parameter %q", name),
+			}
+		}
+		if err := spec.check(value); err != nil {
+			return &ErrorDetails{
+				Field:   field + "." + name,
+				Message: fmt.Sprintf("%s.%s %v", field, name, err),
+			}
+		}
+	}
+
+	return nil
+}
+
+// check validates one value against the spec
+func (s ParameterSpec) check(value interface{}) error {
+	if s.Type == ParameterBoolean {
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a boolean, got %v", value)
+		}
+		return nil
+	}
+
+	v, ok := toFloat(value)
+	if !ok {
+		return fmt.Errorf("must be a number, got %v", value)
+	}
+	if s.Type == ParameterInteger && v != math.Trunc(v) {
+		return fmt.Errorf("must be an integer, got %g", v)
+	}
+	if s.Min != 0 || s.Max != 0 {
+		if v < s.Min || v > s.Max {
+			return fmt.Errorf("must be between %g and %g, got %g", s.Min, s.Max, v)
+		}
+	}
+
+	return nil
+}
+
+// ResolveParameters returns values with every spec filled in: defaults for
+// missing names, numbers as float64, integers as int
+func ResolveParameters(specs []ParameterSpec, values map[string]interface{}) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(specs))
+	for _, spec := range specs {
+		value, ok := values[spec.Name]
+		if !ok {
+			value = spec.Default
+		}
+		switch spec.Type {
+		case ParameterNumber:
+			value, _ = toFloat(value)
+		case ParameterInteger:
+			v, _ := toFloat(value)
+			value = int(v)
+		}
+		resolved[spec.Name] = value
+	}
+	return resolved
+}
+
+// toFloat converts JSON and Go numbers to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parameterMap converts a typed parameter block to a map holding only the
+// values that are set
+func parameterMap(v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil
+	}
+	return values
+}
+
+// StepConfigs returns the correction steps to run in order. An explicit
+// steps list wins; otherwise the enabled algorithms run in the built-in
+// order RTS, spline, Douglas-Peucker, outlier removal. Built-in steps take
+// their defaults from the parameters block.
+func (r DiagnoseRequest) StepConfigs() []StepConfig {
+	params := r.ResolvedParameters()
+	builtin := map[string]map[string]interface{}{
+		StepAdaptiveRTS:         parameterMap(params.AdaptiveRTS),
+		StepSplineInterpolation: parameterMap(params.SplineInterpolation),
+		StepSimplification:      parameterMap(params.Simplification),
+		StepOutlierRemoval:      {},
+	}
+
+	if r.Steps == nil {
+		enabled := []struct {
+			name string
+			on   bool
+		}{
+			{StepAdaptiveRTS, r.Algorithms.AdaptiveRTS},
+			{StepSplineInterpolation, r.Algorithms.SplineInterpolation},
+			{StepSimplification, r.Algorithms.Simplification},
+			{StepOutlierRemoval, r.Algorithms.OutlierRemoval},
+		}
+		steps := make([]StepConfig, 0, len(enabled))
+		for _, e := range enabled {
+			if e.on {
+				steps = append(steps, StepConfig{Name: e.name, Parameters: builtin[e.name]})
+			}
+		}
+		return steps
+	}
+
+	steps := make([]StepConfig, len(r.Steps))
+	for i, step := range r.Steps {
+		defaults, ok := builtin[step.Name]
+		if !ok {
+			steps[i] = step
+			continue
+		}
+		merged := make(map[string]interface{}, len(defaults)+len(step.Parameters))
+		for k, v := range defaults {
+			merged[k] = v
+		}
+		for k, v := range step.Parameters {
+			merged[k] = v
+		}
+		steps[i] = StepConfig{Name: step.Name, Parameters: merged}
+	}
+	return steps
+}
+
+// StepInfo describes a registered correction step
+type StepInfo struct {
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Parameters  []ParameterSpec `json:"parameters"`
+}
+
+// AlgorithmsResponse lists the correction steps a request may use
+type AlgorithmsResponse struct {
+	Steps        []StepInfo `json:"steps"`
+	DefaultOrder []string   `json:"defaultOrder"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Stop is a stay point: a place where a trajectory stayed within a small
+// radius for a while
+type Stop struct {
+	StartIndex int       `json:"startIndex"` // Arrival point, as an index into the input track
+	EndIndex   int       `json:"endIndex"`   // Departure point, inclusive
+	PointCount int       `json:"pointCount"` // Points in Ranges
+	Ranges     [][]int   `json:"ranges"`     // Start and end index of each stay; excursions between them are not part of the stop
+	Lat        float64   `json:"lat"`        // Centroid of the points in Ranges
+	Lon        float64   `json:"lon"`
+	Radius     float64   `json:"radius"` // Farthest point in Ranges from the centroid, meters
+	Arrival    time.Time `json:"arrival"`
+	Departure  time.Time `json:"departure"`
+	DwellSecs  int64     `json:"dwellSeconds"`
+}
@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultMaxDecompressedSize caps the total bytes extracted from one upload
+	DefaultMaxDecompressedSize = 200 * 1024 * 1024 // 200MB
+	// maxArchiveEntries caps the number of tracks taken from one archive
+	maxArchiveEntries = 500
+	// maxArchiveDepth caps nesting such as a zip inside a gzip
+	maxArchiveDepth = 3
+)
+
+// ErrArchiveTooLarge is returned when decompression exceeds the size limit
+var ErrArchiveTooLarge = &ParseError{Message: "archive exceeds decompressed size limit"}
+
+// ArchiveEntry is a single track file extracted from an upload
+type ArchiveEntry struct {
+	Name string
+	Data []byte
+}
+
+// IsArchive reports whether data is a gzip or zip container
+func IsArchive(data []byte) bool {
+	switch Sniff(data).Format {
+	case "gzip", "zip":
+		return true
+	}
+	return false
+}
+
+// Unpack extracts track files from gzip and zip uploads. Plain files are
+// returned as a single entry. A KMZ yields only its doc.kml, while other zip
+// archives yield every entry that looks like a track file. The total
+// decompressed size is capped at limit bytes.
+func Unpack(filename string, data []byte, limit int64) ([]ArchiveEntry, error) {
+	u := &unpacker{remaining: limit, limit: limit}
+	entries, err := u.unpack(filename, data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("archive contains no track files")
+	}
+
+	return entries, nil
+}
+
+// unpacker tracks the decompression budget shared by nested archives
+type unpacker struct {
+	remaining int64
+	limit     int64
+}
+
+func (u *unpacker) unpack(filename string, data []byte, depth int) ([]ArchiveEntry, error) {
+	format := Sniff(data).Format
+	if format != "gzip" && format != "zip" {
+		return []ArchiveEntry{{Name: filename, Data: data}}, nil
+	}
+
+	if depth >= maxArchiveDepth {
+		return nil, fmt.Errorf("archive nesting exceeds %d levels", maxArchiveDepth)
+	}
+
+	if format == "gzip" {
+		return u.unpackGzip(filename, data, depth)
+	}
+	return u.unpackZip(filename, data, depth)
+}
+
+// unpackGzip decompresses a gzip stream, naming it after the original file
+func (u *unpacker) unpackGzip(filename string, data []byte, depth int) ([]ArchiveEntry, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip format: %w", err)
+	}
+	defer zr.Close()
+
+	inner, err := u.read(zr)
+	if err != nil {
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid gzip format: %w", err)
+	}
+
+	name := zr.Name
+	if name == "" {
+		name = strings.TrimSuffix(filename, path.Ext(filename))
+	}
+
+	return u.unpack(name, inner, depth+1)
+}
+
+// unpackZip extracts track files from a zip or KMZ archive
+func (u *unpacker) unpackZip(filename string, data []byte, depth int) ([]ArchiveEntry, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip format: %w", err)
+	}
+
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || isArchiveJunk(f.Name) {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	// KMZ: the main document is doc.kml, or the first KML at the root
+	if kml := kmzDocument(files); kml != nil && (strings.EqualFold(path.Ext(filename), ".kmz") || path.Base(kml.Name) == "doc.kml") {
+		files = []*zip.File{kml}
+	}
+
+	var entries []ArchiveEntry
+	for _, f := range files {
+		content, err := u.open(f)
+		if err != nil {
+			return nil, err
+		}
+
+		inner, err := u.unpack(f.Name, content, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range inner {
+			if !looksLikeTrack(entry) {
+				continue
+			}
+			if len(entries) >= maxArchiveEntries {
+				return nil, fmt.Errorf("archive contains more than %d track files", maxArchiveEntries)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// open reads a zip entry within the remaining budget
+func (u *unpacker) open(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(u.remaining) {
+		return nil, u.tooLarge()
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip format: %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	content, err := u.read(rc)
+	if err != nil {
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid zip format: %s: %w", f.Name, err)
+	}
+	return content, nil
+}
+
+// read reads r fully, failing once the decompression budget is exhausted.
+// Declared sizes are not trusted.
+func (u *unpacker) read(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, u.remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > u.remaining {
+		return nil, u.tooLarge()
+	}
+	u.remaining -= int64(len(content))
+	return content, nil
+}
+
+func (u *unpacker) tooLarge() error {
+	return fmt.Errorf("%w of %dMB", ErrArchiveTooLarge, u.limit/(1024*1024))
+}
+
+// kmzDocument returns doc.kml, or the first root-level KML file
+func kmzDocument(files []*zip.File) *zip.File {
+	var first *zip.File
+	for _, f := range files {
+		if !strings.EqualFold(path.Ext(f.Name), ".kml") {
+			continue
+		}
+		if strings.EqualFold(f.Name, "doc.kml") {
+			return f
+		}
+		if first == nil && !strings.Contains(f.Name, "/") {
+			first = f
+		}
+	}
+	return first
+}
+
+// isArchiveJunk reports metadata entries added by archivers
+func isArchiveJunk(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, "._") ||
+		base == ".DS_Store" || base == "Thumbs.db"
+}
+
+// looksLikeTrack reports whether an extracted entry is a supported track file
+func looksLikeTrack(entry ArchiveEntry) bool {
+	if d := Sniff(entry.Data); d.Confidence >= MinDetectionConfidence {
+		return d.Format != "gzip" && d.Format != "zip"
+	}
+	return FormatFromExtension(entry.Name) != ""
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testKML = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+  <Document>
+    <Placemark>
+      <LineString>
+        <coordinates>116.4074,39.9042,50 116.4084,39.9052,51</coordinates>
+      </LineString>
+    </Placemark>
+  </Document>
+</kml>`
+
+func gzipBytes(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = name
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("Failed to write gzip: %v", err)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		w.Write(data)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestUnpack_Gzip(t *testing.T) {
+	entries, err := Unpack("track.gpx.gz", gzipBytes(t, "", []byte(testGPX)), DefaultMaxDecompressedSize)
+	if err != nil {
+		t.Fatalf("Failed to unpack gzip: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Name != "track.gpx" {
+		t.Fatalf("Expected single entry track.gpx, got %+v", entries)
+	}
+
+	points, err := NewParserFactory().ParseFile("track.gpx.gz", gzipBytes(t, "", []byte(testGPX)))
+	if err != nil || len(points) != 2 {
+		t.Errorf("Expected 2 points from gzipped GPX, got %d (%v)", len(points), err)
+	}
+}
+
+func TestUnpack_KMZ(t *testing.T) {
+	kmz := zipBytes(t, map[string][]byte{
+		"doc.kml":         []byte(testKML),
+		"files/icon.png":  {0x89, 'P', 'N', 'G'},
+		"files/other.kml": []byte(testKML),
+	})
+
+	entries, err := Unpack("route.kmz", kmz, DefaultMaxDecompressedSize)
+	if err != nil {
+		t.Fatalf("Failed to unpack KMZ: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Name != "doc.kml" {
+		t.Errorf("Expected only doc.kml, got %d entries", len(entries))
+	}
+}
+
+func TestUnpack_ZipOfTracks(t *testing.T) {
+	archive := zipBytes(t, map[string][]byte{
+		"rides/a.gpx":            []byte(testGPX),
+		"rides/b.tcx.gz":         gzipBytes(t, "b.tcx", []byte(testTCX)),
+		"rides/c.kml":            []byte(testKML),
+		"README.txt":             []byte("exported tracks"),
+		"__MACOSX/rides/._a.gpx": []byte("junk"),
+	})
+
+	files, err := NewParserFactory().ParseArchive("export.zip", archive, DefaultMaxDecompressedSize)
+	if err != nil {
+		t.Fatalf("Failed to parse archive: %v", err)
+	}
+
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 track files, got %d", len(files))
+	}
+
+	wantFormats := []string{"gpx", "tcx", "kml"}
+	for i, f := range files {
+		if f.Err != nil {
+			t.Errorf("File %s: unexpected error %v", f.Name, f.Err)
+		}
+		if f.Format != wantFormats[i] {
+			t.Errorf("File %s: expected format %s, got %s", f.Name, wantFormats[i], f.Format)
+		}
+	}
+
+	if _, err := NewParserFactory().ParseFile("export.zip", archive); err == nil {
+		t.Error("Expected ParseFile to reject an archive with several tracks")
+	}
+}
+
+func TestUnpack_SizeLimit(t *testing.T) {
+	// Highly compressible payload, far larger than the limit once inflated
+	bomb := gzipBytes(t, "bomb.gpx", []byte(strings.Repeat("0", 1<<20)))
+
+	_, err := Unpack("bomb.gpx.gz", bomb, 64*1024)
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Errorf("Expected ErrArchiveTooLarge, got %v", err)
+	}
+
+	nested := zipBytes(t, map[string][]byte{"inner.gpx.gz": bomb})
+	if _, err := Unpack("nested.zip", nested, 64*1024); !errors.Is(err, ErrArchiveTooLarge) {
+		t.Errorf("Expected ErrArchiveTooLarge for nested archive, got %v", err)
+	}
+}
@@ -0,0 +1,548 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// CSVColumn selects a CSV column by header name or by 0-based index.
+// In JSON it is written as a string (name) or a number (index).
+type CSVColumn struct {
+	Name  string
+	Index int
+}
+
+// UnmarshalJSON accepts either a header name or a column index
+func (c *CSVColumn) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*c = CSVColumn{Name: name}
+		return nil
+	}
+
+	var index int
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("column must be a header name or an index: %s", data)
+	}
+	if index < 0 {
+		return fmt.Errorf("column index must not be negative: %d", index)
+	}
+	*c = CSVColumn{Index: index}
+	return nil
+}
+
+// MarshalJSON writes the column as a name or an index
+func (c CSVColumn) MarshalJSON() ([]byte, error) {
+	if c.Name != "" {
+		return json.Marshal(c.Name)
+	}
+	return json.Marshal(c.Index)
+}
+
+// CSVMapping describes how CSV columns map to point fields.
+// Unset latitude, longitude and time columns are detected from the header.
+type CSVMapping struct {
+	Lat        *CSVColumn `json:"lat,omitempty"`
+	Lon        *CSVColumn `json:"lon,omitempty"`
+	Time       *CSVColumn `json:"time,omitempty"`
+	Elevation  *CSVColumn `json:"elevation,omitempty"`
+	HDOP       *CSVColumn `json:"hdop,omitempty"`
+	Satellites *CSVColumn `json:"satellites,omitempty"`
+	Accuracy   *CSVColumn `json:"accuracy,omitempty"`
+	HeartRate  *CSVColumn `json:"heartRate,omitempty"`
+	Cadence    *CSVColumn `json:"cadence,omitempty"`
+
+	TimeFormat       string `json:"timeFormat,omitempty"`       // Go layout; common layouts are tried when empty
+	EpochUnit        string `json:"epochUnit,omitempty"`        // s, ms, us or ns for numeric timestamps
+	Delimiter        string `json:"delimiter,omitempty"`        // single character or "tab"; detected when empty
+	DecimalSeparator string `json:"decimalSeparator,omitempty"` // "." (default) or ","
+	NoHeader         bool   `json:"noHeader,omitempty"`         // first row is data, columns must be indexes
+}
+
+// csvHeaderAliases lists the header names recognised for each field
+var csvHeaderAliases = map[string][]string{
+	"lat":        {"lat", "latitude", "y"},
+	"lon":        {"lon", "lng", "long", "longitude", "x"},
+	"time":       {"time", "timestamp", "datetime", "date_time", "utc", "t"},
+	"elevation":  {"elevation", "ele", "alt", "altitude", "height"},
+	"hdop":       {"hdop"},
+	"satellites": {"satellites", "sats", "numsats", "num_sats"},
+	"accuracy":   {"accuracy", "acc", "horizontal_accuracy", "horizontalaccuracy"},
+	"heartRate":  {"heartrate", "heart_rate", "hr"},
+	"cadence":    {"cadence", "cad"},
+}
+
+// csvTimeLayouts are tried in order when no time format is configured
+var csvTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+}
+
+// CSVParser handles delimited text (CSV/TSV) trajectory parsing
+type CSVParser struct {
+	strictMode bool
+	mapping    CSVMapping
+}
+
+// NewCSVParser creates a new CSV parser that detects columns from the header
+func NewCSVParser() *CSVParser {
+	return &CSVParser{
+		strictMode: false,
+	}
+}
+
+// NewCSVParserWithMapping creates a new CSV parser with an explicit column mapping
+func NewCSVParserWithMapping(mapping CSVMapping) *CSVParser {
+	return &CSVParser{
+		strictMode: false,
+		mapping:    mapping,
+	}
+}
+
+// csvColumns holds resolved column indexes, -1 when absent
+type csvColumns struct {
+	lat, lon, time, elevation, hdop, satellites, accuracy, heartRate, cadence int
+}
+
+// Parse parses CSV data from bytes
+func (c *CSVParser) Parse(data []byte) ([]model.Point, error) {
+	records, err := c.readRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cols, records, err := c.resolveColumns(records)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]model.Point, 0, len(records))
+	for i, record := range records {
+		point, err := c.recordToPoint(record, cols)
+		if err != nil {
+			if c.strictMode {
+				return nil, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			continue
+		}
+		point.Index = len(points)
+		points = append(points, point)
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("CSV file contains no coordinate rows")
+	}
+
+	return points, nil
+}
+
+// ParseReader parses CSV data from reader
+func (c *CSVParser) ParseReader(r io.Reader) ([]model.Point, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	return c.Parse(data)
+}
+
+// Validate validates CSV data with detailed error messages
+func (c *CSVParser) Validate(data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return fmt.Errorf("CSV file is empty")
+	}
+
+	if c.mapping.DecimalSeparator != "" && c.mapping.DecimalSeparator != "." && c.mapping.DecimalSeparator != "," {
+		return fmt.Errorf("invalid CSV mapping: decimal separator must be \".\" or \",\"")
+	}
+
+	records, err := c.readRecords(data)
+	if err != nil {
+		return err
+	}
+
+	cols, records, err := c.resolveColumns(records)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if _, err := c.recordToPoint(record, cols); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("CSV file contains no coordinate rows")
+}
+
+// readRecords splits the data into records using the configured or detected delimiter
+func (c *CSVParser) readRecords(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	delimiter, err := c.delimiter(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decimal := c.decimalSeparator()
+	if decimal == delimiter {
+		return nil, fmt.Errorf("invalid CSV mapping: delimiter and decimal separator are both %q", delimiter)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV format: %w (hint: check the delimiter and quoting)", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+
+	return records, nil
+}
+
+// delimiter returns the configured delimiter or detects it from the first line
+func (c *CSVParser) delimiter(data []byte) (rune, error) {
+	switch d := c.mapping.Delimiter; {
+	case d == "tab" || d == `\t`:
+		return '\t', nil
+	case d != "":
+		runes := []rune(d)
+		if len(runes) != 1 {
+			return 0, fmt.Errorf("invalid CSV mapping: delimiter must be a single character, got %q", d)
+		}
+		return runes[0], nil
+	}
+
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+
+	best, bestCount := ',', 0
+	for _, candidate := range []rune{'\t', ';', ',', '|'} {
+		if candidate == c.decimalSeparator() {
+			continue
+		}
+		if n := bytes.Count(firstLine, []byte(string(candidate))); n > bestCount {
+			best, bestCount = candidate, n
+		}
+	}
+
+	return best, nil
+}
+
+// decimalSeparator returns the configured decimal separator
+func (c *CSVParser) decimalSeparator() rune {
+	if c.mapping.DecimalSeparator == "," {
+		return ','
+	}
+	return '.'
+}
+
+// resolveColumns maps fields to column indexes and strips the header row
+func (c *CSVParser) resolveColumns(records [][]string) (csvColumns, [][]string, error) {
+	var header []string
+	if !c.mapping.NoHeader {
+		header = records[0]
+		records = records[1:]
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[normalizeCSVHeader(name)] = i
+	}
+
+	var resolveErr error
+	resolve := func(field string, col *CSVColumn, defaultIndex int) int {
+		if col != nil {
+			if col.Name == "" {
+				return col.Index
+			}
+			if i, ok := index[normalizeCSVHeader(col.Name)]; ok {
+				return i
+			}
+			if resolveErr == nil {
+				resolveErr = fmt.Errorf("invalid CSV mapping: column %q for %s not found in header", col.Name, field)
+			}
+			return -1
+		}
+
+		if header == nil {
+			return defaultIndex
+		}
+		for _, alias := range csvHeaderAliases[field] {
+			if i, ok := index[alias]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+
+	// Without a header the default layout matches the points API: lat, lon, time, elevation
+	cols := csvColumns{
+		lat:        resolve("lat", c.mapping.Lat, 0),
+		lon:        resolve("lon", c.mapping.Lon, 1),
+		time:       resolve("time", c.mapping.Time, 2),
+		elevation:  resolve("elevation", c.mapping.Elevation, 3),
+		hdop:       resolve("hdop", c.mapping.HDOP, -1),
+		satellites: resolve("satellites", c.mapping.Satellites, -1),
+		accuracy:   resolve("accuracy", c.mapping.Accuracy, -1),
+		heartRate:  resolve("heartRate", c.mapping.HeartRate, -1),
+		cadence:    resolve("cadence", c.mapping.Cadence, -1),
+	}
+	if resolveErr != nil {
+		return cols, nil, resolveErr
+	}
+
+	if cols.lat < 0 || cols.lon < 0 {
+		return cols, nil, fmt.Errorf("not a CSV track - no latitude/longitude columns found (hint: name them lat/lon or pass a column mapping)")
+	}
+
+	return cols, records, nil
+}
+
+// recordToPoint converts a CSV record to a point
+func (c *CSVParser) recordToPoint(record []string, cols csvColumns) (model.Point, error) {
+	lat, err := c.parseFloat(record, cols.lat)
+	if err != nil {
+		return model.Point{}, fmt.Errorf("invalid latitude: %w", err)
+	}
+	lon, err := c.parseFloat(record, cols.lon)
+	if err != nil {
+		return model.Point{}, fmt.Errorf("invalid longitude: %w", err)
+	}
+	if !isValidLatLon(lat, lon) {
+		return model.Point{}, fmt.Errorf("coordinates out of range: %f, %f", lat, lon)
+	}
+
+	point := model.Point{
+		Lat:    lat,
+		Lon:    lon,
+		Status: model.StatusNormal,
+	}
+
+	if value := csvField(record, cols.time); value != "" {
+		ts, err := c.parseTime(value)
+		if err != nil {
+			return model.Point{}, err
+		}
+		point.Time = ts
+	}
+
+	// Optional columns are ignored when empty or malformed
+	if v, err := c.parseFloat(record, cols.elevation); err == nil {
+		point.Elevation = v
+	}
+	if v, err := c.parseFloat(record, cols.hdop); err == nil {
+		point.HDOP = v
+	}
+	if v, err := c.parseFloat(record, cols.satellites); err == nil {
+		point.Satellites = int(v)
+	}
+	if v, err := c.parseFloat(record, cols.accuracy); err == nil {
+		point.Accuracy = v
+	}
+	if v, err := c.parseFloat(record, cols.heartRate); err == nil {
+		point.HeartRate = int(v)
+	}
+	if v, err := c.parseFloat(record, cols.cadence); err == nil {
+		point.Cadence = int(v)
+	}
+
+	return point, nil
+}
+
+// parseFloat parses a numeric column honoring the decimal separator
+func (c *CSVParser) parseFloat(record []string, col int) (float64, error) {
+	value := csvField(record, col)
+	if value == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+	if c.decimalSeparator() == ',' {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("not a finite number: %s", value)
+	}
+	return v, nil
+}
+
+// parseTime parses a timestamp using the epoch unit, the time format or common layouts
+func (c *CSVParser) parseTime(value string) (time.Time, error) {
+	if c.mapping.EpochUnit != "" {
+		return parseEpoch(value, c.mapping.EpochUnit)
+	}
+
+	if c.mapping.TimeFormat != "" {
+		ts, err := time.Parse(c.mapping.TimeFormat, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q for format %q", value, c.mapping.TimeFormat)
+		}
+		return ts, nil
+	}
+
+	for _, layout := range csvTimeLayouts {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, nil
+		}
+	}
+
+	// Bare numbers are unix seconds, or milliseconds when too large for seconds
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.Abs(v) > 1e11 {
+			return parseEpoch(value, "ms")
+		}
+		return parseEpoch(value, "s")
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time %q (hint: set timeFormat or epochUnit)", value)
+}
+
+// parseEpoch parses a unix timestamp in the given unit
+func parseEpoch(value, unit string) (time.Time, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid epoch time %q", value)
+	}
+
+	var scale float64
+	switch unit {
+	case "s":
+		scale = 1e9
+	case "ms":
+		scale = 1e6
+	case "us":
+		scale = 1e3
+	case "ns":
+		scale = 1
+	default:
+		return time.Time{}, fmt.Errorf("invalid CSV mapping: unknown epoch unit %q (use s, ms, us or ns)", unit)
+	}
+
+	return time.Unix(0, int64(math.Round(v*scale))).UTC(), nil
+}
+
+// csvField returns the trimmed field at col, or "" when absent
+func csvField(record []string, col int) string {
+	if col < 0 || col >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[col])
+}
+
+// normalizeCSVHeader lowercases a header name and drops surrounding spaces
+func normalizeCSVHeader(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// csvExportColumns lists every exported point field in column order
+var csvExportColumns = []struct {
+	name  string
+	value func(p model.Point) string
+}{
+	{"index", func(p model.Point) string { return strconv.Itoa(p.Index) }},
+	{"lat", func(p model.Point) string { return formatCSVFloat(p.Lat) }},
+	{"lon", func(p model.Point) string { return formatCSVFloat(p.Lon) }},
+	{"time", func(p model.Point) string {
+		if p.Time.IsZero() {
+			return ""
+		}
+		return p.Time.UTC().Format(time.RFC3339Nano)
+	}},
+	{"elevation", func(p model.Point) string { return formatCSVFloat(p.Elevation) }},
+	{"status", func(p model.Point) string { return string(p.Status) }},
+	{"flags", func(p model.Point) string {
+		flags := make([]string, len(p.Flags))
+		for i, f := range p.Flags {
+			flags[i] = string(f)
+		}
+		return strings.Join(flags, ";")
+	}},
+	{"isInterpolated", func(p model.Point) string { return strconv.FormatBool(p.IsInterpolated) }},
+	{"originalLat", func(p model.Point) string { return formatCSVFloat(p.OriginalLat) }},
+	{"originalLon", func(p model.Point) string { return formatCSVFloat(p.OriginalLon) }},
+	{"speed", func(p model.Point) string { return formatCSVFloat(p.Speed) }},
+	{"bearing", func(p model.Point) string { return formatCSVFloat(p.Bearing) }},
+	{"acceleration", func(p model.Point) string { return formatCSVFloat(p.Acceleration) }},
+	{"fixedBy", func(p model.Point) string { return p.FixedBy }},
+	{"fixQuality", func(p model.Point) string { return strconv.Itoa(p.FixQuality) }},
+	{"fixType", func(p model.Point) string { return p.FixType }},
+	{"hdop", func(p model.Point) string { return formatCSVFloat(p.HDOP) }},
+	{"vdop", func(p model.Point) string { return formatCSVFloat(p.VDOP) }},
+	{"pdop", func(p model.Point) string { return formatCSVFloat(p.PDOP) }},
+	{"satellites", func(p model.Point) string { return strconv.Itoa(p.Satellites) }},
+	{"accuracy", func(p model.Point) string { return formatCSVFloat(p.Accuracy) }},
+	{"course", func(p model.Point) string { return formatCSVOptional(p.Course) }},
+	{"heartRate", func(p model.Point) string { return strconv.Itoa(p.HeartRate) }},
+	{"cadence", func(p model.Point) string { return strconv.Itoa(p.Cadence) }},
+	{"temperature", func(p model.Point) string { return formatCSVOptional(p.Temperature) }},
+	{"distance", func(p model.Point) string { return formatCSVFloat(p.Distance) }},
+	{"lap", func(p model.Point) string { return strconv.Itoa(p.Lap) }},
+	{"track", func(p model.Point) string { return strconv.Itoa(p.Track) }},
+	{"segment", func(p model.Point) string { return strconv.Itoa(p.Segment) }},
+}
+
+// ToCSV converts points to CSV with one column per point field
+func ToCSV(points []model.Point) ([]byte, error) {
+	buf := bytes.Buffer{}
+	writer := csv.NewWriter(&buf)
+
+	header := make([]string, len(csvExportColumns))
+	for i, col := range csvExportColumns {
+		header[i] = col.name
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	row := make([]string, len(csvExportColumns))
+	for _, p := range points {
+		for i, col := range csvExportColumns {
+			row[i] = col.value(p)
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// formatCSVFloat formats a float with the shortest exact representation
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatCSVOptional formats a value that may be absent as an empty cell
+func formatCSVOptional(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatCSVFloat(*v)
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func TestCSVParser_Parse(t *testing.T) {
+	parser := NewCSVParser()
+
+	data := []byte("latitude,longitude,timestamp,alt,hdop\n" +
+		"39.9042,116.4074,2024-01-01T08:00:00Z,50.5,0.9\n" +
+		"not-a-number,116.4084,2024-01-01T08:00:01Z,51,1.0\n" +
+		"39.9052,116.4084,2024-01-01T08:00:02Z,52,1.1\n")
+
+	points, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+
+	// The malformed row is skipped in lenient mode
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(points))
+	}
+
+	if points[0].Elevation != 50.5 || points[0].HDOP != 0.9 {
+		t.Errorf("Expected elevation 50.5 and hdop 0.9, got %f and %f", points[0].Elevation, points[0].HDOP)
+	}
+
+	want := time.Date(2024, 1, 1, 8, 0, 2, 0, time.UTC)
+	if !points[1].Time.Equal(want) || points[1].Index != 1 {
+		t.Errorf("Expected point 1 at %s, got index %d at %s", want, points[1].Index, points[1].Time)
+	}
+}
+
+func TestCSVParser_Mapping(t *testing.T) {
+	var mapping CSVMapping
+	spec := `{"lat": 2, "lon": 1, "time": "ts", "elevation": "Höhe",
+		"epochUnit": "ms", "delimiter": ";", "decimalSeparator": ","}`
+	if err := json.Unmarshal([]byte(spec), &mapping); err != nil {
+		t.Fatalf("Failed to decode mapping: %v", err)
+	}
+
+	parser := NewCSVParserWithMapping(mapping)
+
+	data := []byte("ts;lon;lat;Höhe\n" +
+		"1704096000000;116,4074;39,9042;50,5\n" +
+		"1704096001500;116,4084;39,9052;51\n")
+
+	if err := parser.Validate(data); err != nil {
+		t.Fatalf("Expected valid CSV, got error: %v", err)
+	}
+
+	points, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(points))
+	}
+	if points[0].Lat != 39.9042 || points[0].Lon != 116.4074 || points[0].Elevation != 50.5 {
+		t.Errorf("Expected (39.9042, 116.4074, 50.5), got (%f, %f, %f)", points[0].Lat, points[0].Lon, points[0].Elevation)
+	}
+
+	want := time.Date(2024, 1, 1, 8, 0, 1, 500e6, time.UTC)
+	if !points[1].Time.Equal(want) {
+		t.Errorf("Expected time %s, got %s", want, points[1].Time)
+	}
+}
+
+func TestCSVParser_TSVWithoutHeader(t *testing.T) {
+	parser := NewCSVParserWithMapping(CSVMapping{NoHeader: true, TimeFormat: "02/01/2006 15:04:05"})
+
+	data := []byte("39.9042\t116.4074\t01/01/2024 08:00:00\n" +
+		"39.9052\t116.4084\t01/01/2024 08:00:05\n")
+
+	points, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse TSV: %v", err)
+	}
+
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(points))
+	}
+	if want := time.Date(2024, 1, 1, 8, 0, 5, 0, time.UTC); !points[1].Time.Equal(want) {
+		t.Errorf("Expected time %s, got %s", want, points[1].Time)
+	}
+}
+
+func TestParserFactory_ParseAsTSV(t *testing.T) {
+	data := []byte("lat\tlon\ttime\n" +
+		"39.9042\t116.4074\t2024-01-01T08:00:00Z\n" +
+		"39.9052\t116.4084\t2024-01-01T08:00:05Z\n")
+
+	points, err := NewParserFactory().ParseAs("tsv", data)
+	if err != nil {
+		t.Fatalf("Failed to parse as tsv: %v", err)
+	}
+	if len(points) != 2 {
+		t.Errorf("Expected 2 points, got %d", len(points))
+	}
+}
+
+func TestCSVParser_Validate(t *testing.T) {
+	parser := NewCSVParser()
+
+	if err := parser.Validate([]byte("")); err == nil {
+		t.Error("Expected error for empty data")
+	}
+
+	if err := parser.Validate([]byte("name,value\nfoo,1\n")); err == nil {
+		t.Error("Expected error for CSV without coordinate columns")
+	}
+
+	missing := NewCSVParserWithMapping(CSVMapping{Lat: &CSVColumn{Name: "y_wgs84"}})
+	if err := missing.Validate([]byte("lat,lon\n39.9,116.4\n")); err == nil {
+		t.Error("Expected error for mapped column missing from header")
+	}
+
+	conflict := NewCSVParserWithMapping(CSVMapping{Delimiter: ",", DecimalSeparator: ","})
+	if err := conflict.Validate([]byte("lat,lon\n39.9,116.4\n")); err == nil {
+		t.Error("Expected error when delimiter equals decimal separator")
+	}
+}
+
+func TestToCSV_RoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := []model.Point{
+		{Index: 0, Lat: 39.9042, Lon: 116.4074, Time: start, Elevation: 50, HDOP: 0.9, Satellites: 8, HeartRate: 120, Status: model.StatusNormal},
+		{Index: 1, Lat: 39.9052, Lon: 116.4084, Time: start.Add(time.Second), Status: model.StatusDrift,
+			OriginalLat: 39.91, OriginalLon: 116.41, FixedBy: "RTS平滑", Cadence: 85, Lap: 1},
+	}
+
+	data, err := ToCSV(points)
+	if err != nil {
+		t.Fatalf("Failed to create CSV: %v", err)
+	}
+
+	reparsed, err := NewCSVParser().Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse exported CSV: %v\n%s", err, data)
+	}
+
+	if len(reparsed) != len(points) {
+		t.Fatalf("Expected %d points, got %d", len(points), len(reparsed))
+	}
+	for i := range points {
+		o, r := points[i], reparsed[i]
+		if math.Abs(o.Lat-r.Lat) > 1e-12 || !o.Time.Equal(r.Time) || o.HeartRate != r.HeartRate ||
+			o.Cadence != r.Cadence || o.Satellites != r.Satellites || o.HDOP != r.HDOP {
+			t.Errorf("Point %d changed from %+v to %+v", i, o, r)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+)
+
+// sniffLimit bounds how much of a text file is inspected for line-based formats
+const sniffLimit = 64 * 1024
+
+// MinDetectionConfidence is the confidence at which a content match overrides
+// the file extension
+const MinDetectionConfidence = 0.5
+
+// minNMEAShare is the share of lines that must be checksummed NMEA
+// sentences; a stray "$" in other text is no log
+const minNMEAShare = 0.5
+
+// strongNMEAConfidence is the NMEA confidence that overrides a .csv or .tsv
+// extension: delimited text may hold the odd checksummed sentence
+const strongNMEAConfidence = 0.9
+
+// Detection is the result of content-based format detection
+type Detection struct {
+	Format     string  `json:"format"`     // parser type, "gzip", "zip" or "unknown"
+	Confidence float64 `json:"confidence"` // 0 (no idea) to 1 (signature match)
+}
+
+// Sniff detects the file format from its content
+func Sniff(data []byte) Detection {
+	if len(data) == 0 {
+		return Detection{Format: "unknown"}
+	}
+
+	// Binary signatures
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		return Detection{Format: "gzip", Confidence: 1}
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return Detection{Format: "zip", Confidence: 1}
+	case len(data) >= 12 && (data[0] == 12 || data[0] == 14) && string(data[8:12]) == ".FIT":
+		return Detection{Format: "fit", Confidence: 1}
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(text) == 0 {
+		return Detection{Format: "unknown"}
+	}
+
+	switch text[0] {
+	case '<':
+		return sniffXML(text)
+	case '{', '[':
+		return sniffJSON(text)
+	}
+
+	if d := sniffNMEA(text); d.Confidence > 0 {
+		return d
+	}
+	return sniffCSV(text)
+}
+
+// sniffXML identifies XML formats by root element and namespace
+func sniffXML(data []byte) Detection {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return sniffXMLText(data)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		ns := strings.ToLower(start.Name.Space)
+		switch strings.ToLower(start.Name.Local) {
+		case "gpx":
+			return xmlDetection("gpx", ns, "topografix.com/gpx")
+		case "kml":
+			return xmlDetection("kml", ns, "opengis.net/kml", "earth.google.com/kml")
+		case "trainingcenterdatabase":
+			return xmlDetection("tcx", ns, "garmin.com/xmlschemas/trainingcenterdatabase")
+		default:
+			return Detection{Format: "unknown"}
+		}
+	}
+}
+
+// xmlDetection scores a root element match by whether its namespace is the expected one
+func xmlDetection(format, ns string, known ...string) Detection {
+	for _, k := range known {
+		if strings.Contains(ns, k) {
+			return Detection{Format: format, Confidence: 1}
+		}
+	}
+	return Detection{Format: format, Confidence: 0.9}
+}
+
+// sniffXMLText falls back to substring matching for XML the decoder rejects
+func sniffXMLText(data []byte) Detection {
+	head := data
+	if len(head) > sniffLimit {
+		head = head[:sniffLimit]
+	}
+	content := strings.ToLower(string(head))
+
+	switch {
+	case strings.Contains(content, "<gpx"):
+		return Detection{Format: "gpx", Confidence: 0.6}
+	case strings.Contains(content, "<kml"):
+		return Detection{Format: "kml", Confidence: 0.6}
+	case strings.Contains(content, "<trainingcenterdatabase"):
+		return Detection{Format: "tcx", Confidence: 0.6}
+	}
+	return Detection{Format: "unknown"}
+}
+
+// sniffJSON identifies GeoJSON by its top-level "type" member
+func sniffJSON(data []byte) Detection {
+	var obj struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return Detection{Format: "unknown"}
+	}
+
+	if geoJSONTypes[obj.Type] {
+		return Detection{Format: "geojson", Confidence: 0.95}
+	}
+	return Detection{Format: "unknown"}
+}
+
+// sniffNMEA scores text by the share of lines that are checksummed NMEA
+// sentences. Text with fewer than minNMEAShare of them is not NMEA.
+func sniffNMEA(data []byte) Detection {
+	nmea := NewNMEAParser()
+	nmea.strictMode = true
+	scanner := bufio.NewScanner(bytes.NewReader(headLines(data)))
+	scanner.Buffer(make([]byte, 0, 1024), sniffLimit)
+
+	lines, sentences := 0, 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines++
+		if _, ok := nmea.splitSentence(line); ok {
+			sentences++
+		}
+	}
+
+	if sentences == 0 {
+		return Detection{Format: "unknown"}
+	}
+	share := float64(sentences) / float64(lines)
+	if share < minNMEAShare {
+		return Detection{Format: "unknown"}
+	}
+	return Detection{Format: "nmea", Confidence: 0.5 + 0.5*share}
+}
+
+// sniffCSV recognises delimited text with coordinate columns
+func sniffCSV(data []byte) Detection {
+	head := headLines(data)
+
+	// A header naming coordinate columns is a strong signal
+	if rows, err := NewCSVParser().Parse(head); err == nil && len(rows) > 0 {
+		return Detection{Format: "csv", Confidence: 0.8}
+	}
+
+	// Headerless rows need at least two numeric coordinates per line
+	headerless := NewCSVParserWithMapping(CSVMapping{NoHeader: true})
+	if rows, err := headerless.Parse(head); err == nil && len(rows) >= 2 {
+		return Detection{Format: "csv", Confidence: 0.5}
+	}
+
+	return Detection{Format: "unknown"}
+}
+
+// headLines returns the complete lines within the sniff limit
+func headLines(data []byte) []byte {
+	if len(data) <= sniffLimit {
+		return data
+	}
+	head := data[:sniffLimit]
+	if i := bytes.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i+1]
+	}
+	return head
+}
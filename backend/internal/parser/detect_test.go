@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="PositionDoctor" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <trkseg>
+      <trkpt lat="39.9042" lon="116.4074"><time>2024-01-01T08:00:00Z</time></trkpt>
+      <trkpt lat="39.9052" lon="116.4084"><time>2024-01-01T08:00:30Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+// testDollarCSV is a valid CSV track with a "$" value that must not read as
+// an NMEA sentence
+const testDollarCSV = "lat,lon,time,note\n" +
+	"39.9042,116.4074,2024-01-01T08:00:00Z,start\n" +
+	"39.9052,116.4084,2024-01-01T08:00:05Z,$12.50 fee\n" +
+	"39.9062,116.4094,2024-01-01T08:00:10Z,\n"
+
+func TestSniff(t *testing.T) {
+	fitData, err := os.ReadFile("../../testdata/sample.fit")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	nmeaData, err := os.ReadFile("../../testdata/sample.nmea")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(testGPX))
+	zw.Close()
+
+	tests := []struct {
+		name          string
+		data          []byte
+		format        string
+		minConfidence float64
+	}{
+		{"gpx with namespace", []byte(testGPX), "gpx", 1},
+		{"gpx after bom and comment", []byte("\xef\xbb\xbf\n<!-- exported --><gpx><trk/></gpx>"), "gpx", 0.9},
+		{"kml", []byte(`<?xml version="1.0"?><kml xmlns="http://www.opengis.net/kml/2.2"><Document/></kml>`), "kml", 1},
+		{"tcx", []byte(testTCX), "tcx", 1},
+		{"truncated gpx", []byte(`<?xml version="1.0"?><gpx version="1.1"><trk><trkseg><trkpt lat="1" lon=`), "gpx", 0.6},
+		{"fit", fitData, "fit", 1},
+		{"nmea", nmeaData, "nmea", 0.8},
+		{"geojson", []byte(`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[116.4,39.9],[116.5,39.95]]}}`), "geojson", 0.9},
+		{"csv with header", []byte("latitude,longitude,timestamp\n39.9,116.4,1704096000\n"), "csv", 0.8},
+		{"headerless csv", []byte("39.9,116.4\n39.91,116.41\n"), "csv", 0.5},
+		{"gzip", gz.Bytes(), "gzip", 1},
+		{"zip", []byte("PK\x03\x04\x14\x00\x00\x00"), "zip", 1},
+		{"plain json", []byte(`{"name":"not geo"}`), "unknown", 0},
+		{"text", []byte("not a valid GPX or KML file"), "unknown", 0},
+		{"csv with a dollar cell", []byte(testDollarCSV), "csv", 0.8},
+		{"unchecksummed dollar line", []byte("$GPRMC,235957.00,A,3954.2520,N\nnotes\n"), "unknown", 0},
+		{"empty", nil, "unknown", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sniff(tt.data)
+			if got.Format != tt.format {
+				t.Errorf("Expected format %s, got %s", tt.format, got.Format)
+			}
+			if got.Confidence < tt.minConfidence {
+				t.Errorf("Expected confidence >= %.2f, got %.2f", tt.minConfidence, got.Confidence)
+			}
+			if tt.format == "unknown" && got.Confidence != 0 {
+				t.Errorf("Expected zero confidence for unknown, got %.2f", got.Confidence)
+			}
+		})
+	}
+}
+
+func TestParserFactory_ParseFileIgnoresExtension(t *testing.T) {
+	factory := NewParserFactory()
+
+	// A GPX shared from a phone often arrives with a generic name
+	points, err := factory.ParseFile("shared-file.bin", []byte(testGPX))
+	if err != nil {
+		t.Fatalf("Expected GPX content to be detected, got error: %v", err)
+	}
+	if len(points) == 0 {
+		t.Error("Expected points from sniffed GPX")
+	}
+
+	// Content wins over a misleading extension
+	if _, err := factory.ParseFile("track.kml", []byte(testTCX)); err != nil {
+		t.Errorf("Expected TCX content to override .kml extension, got error: %v", err)
+	}
+
+	// Unknown content falls back to the extension
+	if format, err := factory.ResolveFormat("track.gpx", []byte("garbage")); err != nil || format != "gpx" {
+		t.Errorf("Expected fallback to gpx, got %s (%v)", format, err)
+	}
+
+	// A "$" cell does not turn a CSV into an NMEA log
+	if points, err := factory.ParseFile("track.csv", []byte(testDollarCSV)); err != nil || len(points) != 3 {
+		t.Errorf("Expected 3 points from a CSV with a $ value, got %d (%v)", len(points), err)
+	}
+
+	// Nor do a few checksummed sentences in a .csv file
+	mixed := testDollarCSV + strings.Repeat("$GNGSA,A,3,01,03,07,08,11,14,17,22,,,,,1.6,0.9,1.3*2E\n", 4)
+	if d := Sniff([]byte(mixed)); d.Format != "nmea" || d.Confidence >= strongNMEAConfidence {
+		t.Fatalf("Expected a weak NMEA match, got %+v", d)
+	}
+	if format, _ := factory.ResolveFormat("track.csv", []byte(mixed)); format != "csv" {
+		t.Errorf("Expected a weak NMEA match to keep the .csv extension, got %s", format)
+	}
+
+	if _, err := factory.ParseFile("notes.txt", []byte("just some notes")); err != ErrUnsupportedFormat {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
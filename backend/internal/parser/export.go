@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// ExportFormats returns the formats Export can write
+func ExportFormats() []string {
+	return []string{"gpx", "kml", "tcx", "fit", "csv", "geojson"}
+}
+
+// Export converts points to the given file format
+func Export(format string, points []model.Point, name string) ([]byte, error) {
+	switch format {
+	case "gpx":
+		return ToGPX(points, name)
+	case "kml":
+		return ToKML(points, name)
+	case "tcx":
+		return ToTCX(points, name)
+	case "fit":
+		return ToFIT(points, name)
+	case "csv":
+		return ToCSV(points)
+	case "geojson":
+		return ToGeoJSON(points, name)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// ToGeoJSON converts points to a GeoJSON LineString feature
+func ToGeoJSON(points []model.Point, name string) ([]byte, error) {
+	coordinates := make([][]float64, len(points))
+	for i, p := range points {
+		coordinates[i] = []float64{p.Lon, p.Lat}
+	}
+
+	geojson := map[string]interface{}{
+		"type": "Feature",
+		"properties": map[string]interface{}{
+			"name": name,
+		},
+		"geometry": map[string]interface{}{
+			"type":        "LineString",
+			"coordinates": coordinates,
+		},
+	}
+
+	data, err := json.Marshal(geojson)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal GeoJSON: %w", err)
+	}
+	return data, nil
+}
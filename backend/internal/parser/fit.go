@@ -0,0 +1,589 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// FIT protocol constants
+const (
+	fitEpoch = 631065600 // 1989-12-31T00:00:00Z in unix seconds
+
+	fitMesgFileID   = 0
+	fitMesgSession  = 18
+	fitMesgLap      = 19
+	fitMesgRecord   = 20
+	fitMesgActivity = 34
+
+	fitFieldTimestamp = 253
+
+	// Record message field numbers
+	fitRecordPositionLat      = 0
+	fitRecordPositionLong     = 1
+	fitRecordAltitude         = 2
+	fitRecordHeartRate        = 3
+	fitRecordCadence          = 4
+	fitRecordDistance         = 5
+	fitRecordSpeed            = 6
+	fitRecordEnhancedSpeed    = 73
+	fitRecordEnhancedAltitude = 78
+
+	// Base types
+	fitEnum    = 0x00
+	fitUint8   = 0x02
+	fitUint16  = 0x84
+	fitSint32  = 0x85
+	fitUint32  = 0x86
+	fitUint32z = 0x8C
+)
+
+// semicircleDegrees converts FIT semicircles to degrees
+const semicircleDegrees = 180.0 / (1 << 31)
+
+// FITParser handles Garmin FIT binary file parsing
+type FITParser struct {
+	strictMode bool
+}
+
+// NewFITParser creates a new FIT parser
+func NewFITParser() *FITParser {
+	return &FITParser{
+		strictMode: false,
+	}
+}
+
+// fitFieldDef describes one field of a definition message
+type fitFieldDef struct {
+	num      byte
+	size     int
+	baseType byte
+}
+
+// fitDefinition describes the layout of a local message type
+type fitDefinition struct {
+	global    uint16
+	order     binary.ByteOrder
+	fields    []fitFieldDef
+	devFields int // Total size of developer fields, skipped when decoding
+}
+
+// Parse parses FIT data from bytes
+func (f *FITParser) Parse(data []byte) ([]model.Point, error) {
+	var points []model.Point
+
+	// A FIT file may contain several chained FIT sections
+	for len(data) > 0 {
+		headerSize, dataSize, err := f.readHeader(data)
+		if err != nil {
+			if len(points) > 0 {
+				break
+			}
+			return nil, err
+		}
+
+		end := headerSize + dataSize
+		if end > len(data) {
+			// Truncated recordings are common when a device runs out of power
+			end = len(data)
+		}
+
+		sectionPoints, err := f.decodeRecords(data[headerSize:end])
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, sectionPoints...)
+
+		// Skip the trailing file CRC
+		next := headerSize + dataSize + 2
+		if next >= len(data) {
+			break
+		}
+		data = data[next:]
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points found in FIT file")
+	}
+
+	for i := range points {
+		points[i].Index = i
+	}
+
+	return points, nil
+}
+
+// ParseReader parses FIT data from reader
+func (f *FITParser) ParseReader(r io.Reader) ([]model.Point, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FIT: %w", err)
+	}
+	return f.Parse(data)
+}
+
+// readHeader validates the file header and returns header and data sizes
+func (f *FITParser) readHeader(data []byte) (int, int, error) {
+	if len(data) < 12 {
+		return 0, 0, fmt.Errorf("file too small to be a valid FIT")
+	}
+
+	headerSize := int(data[0])
+	if headerSize != 12 && headerSize != 14 {
+		return 0, 0, fmt.Errorf("invalid FIT format: unexpected header size %d", headerSize)
+	}
+	if len(data) < headerSize {
+		return 0, 0, fmt.Errorf("file too small to be a valid FIT")
+	}
+	if string(data[8:12]) != ".FIT" {
+		return 0, 0, fmt.Errorf("not a FIT file - missing .FIT signature")
+	}
+
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	return headerSize, dataSize, nil
+}
+
+// decodeRecords walks the data records of one FIT section and collects
+// record messages that carry a position
+func (f *FITParser) decodeRecords(data []byte) ([]model.Point, error) {
+	var points []model.Point
+	var definitions [16]*fitDefinition
+	var lastTimestamp uint32
+
+	pos := 0
+	for pos < len(data) {
+		header := data[pos]
+		pos++
+
+		var local byte
+		var timestamp uint32
+		compressed := header&0x80 != 0
+
+		if compressed {
+			// Compressed timestamp header: 5-bit offset from the last timestamp
+			local = (header >> 5) & 0x03
+			offset := uint32(header & 0x1F)
+			timestamp = (lastTimestamp &^ 0x1F) + offset
+			if offset < lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+			lastTimestamp = timestamp
+		} else {
+			local = header & 0x0F
+			if header&0x40 != 0 {
+				def, n, err := f.readDefinition(data[pos:], header&0x20 != 0)
+				if err != nil {
+					return nil, err
+				}
+				definitions[local] = def
+				pos += n
+				continue
+			}
+		}
+
+		def := definitions[local]
+		if def == nil {
+			return nil, fmt.Errorf("invalid FIT format: data message for undefined local type %d", local)
+		}
+
+		size := def.devFields
+		for _, fd := range def.fields {
+			size += fd.size
+		}
+		if pos+size > len(data) {
+			// Truncated final message
+			break
+		}
+		msg := data[pos : pos+size]
+		pos += size
+
+		values := f.readValues(def, msg)
+		if ts, ok := values[fitFieldTimestamp]; ok && ts.valid {
+			timestamp = uint32(ts.raw)
+			lastTimestamp = timestamp
+		}
+
+		if def.global != fitMesgRecord {
+			continue
+		}
+
+		if point, ok := f.recordToPoint(values, timestamp); ok {
+			points = append(points, point)
+		}
+	}
+
+	return points, nil
+}
+
+// readDefinition parses a definition message and returns it with its length
+func (f *FITParser) readDefinition(data []byte, hasDevFields bool) (*fitDefinition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("invalid FIT format: truncated definition message")
+	}
+
+	def := &fitDefinition{order: binary.LittleEndian}
+	if data[1] == 1 {
+		def.order = binary.BigEndian
+	}
+	def.global = def.order.Uint16(data[2:4])
+
+	numFields := int(data[4])
+	pos := 5
+	if len(data) < pos+numFields*3 {
+		return nil, 0, fmt.Errorf("invalid FIT format: truncated definition message")
+	}
+	for i := 0; i < numFields; i++ {
+		def.fields = append(def.fields, fitFieldDef{
+			num:      data[pos],
+			size:     int(data[pos+1]),
+			baseType: data[pos+2],
+		})
+		pos += 3
+	}
+
+	if hasDevFields {
+		if len(data) < pos+1 {
+			return nil, 0, fmt.Errorf("invalid FIT format: truncated definition message")
+		}
+		numDev := int(data[pos])
+		pos++
+		if len(data) < pos+numDev*3 {
+			return nil, 0, fmt.Errorf("invalid FIT format: truncated definition message")
+		}
+		for i := 0; i < numDev; i++ {
+			def.devFields += int(data[pos+1])
+			pos += 3
+		}
+	}
+
+	return def, pos, nil
+}
+
+// fitValue is a decoded numeric field value
+type fitValue struct {
+	raw   int64
+	valid bool
+}
+
+// readValues decodes the numeric fields of a data message
+func (f *FITParser) readValues(def *fitDefinition, msg []byte) map[byte]fitValue {
+	values := make(map[byte]fitValue, len(def.fields))
+	pos := 0
+
+	for _, fd := range def.fields {
+		b := msg[pos : pos+fd.size]
+		pos += fd.size
+
+		// Base type number without the endian flag
+		switch fd.baseType & 0x1F {
+		case 0x00, 0x02, 0x0A: // enum, uint8, uint8z
+			if fd.size >= 1 {
+				values[fd.num] = fitValue{raw: int64(b[0]), valid: b[0] != 0xFF}
+			}
+		case 0x04, 0x0B: // uint16, uint16z
+			if fd.size >= 2 {
+				v := def.order.Uint16(b)
+				values[fd.num] = fitValue{raw: int64(v), valid: v != 0xFFFF}
+			}
+		case 0x05: // sint32
+			if fd.size >= 4 {
+				v := int32(def.order.Uint32(b))
+				values[fd.num] = fitValue{raw: int64(v), valid: v != math.MaxInt32}
+			}
+		case 0x06, 0x0C: // uint32, uint32z
+			if fd.size >= 4 {
+				v := def.order.Uint32(b)
+				values[fd.num] = fitValue{raw: int64(v), valid: v != 0xFFFFFFFF}
+			}
+		}
+	}
+
+	return values
+}
+
+// recordToPoint converts decoded record fields into a point
+func (f *FITParser) recordToPoint(values map[byte]fitValue, timestamp uint32) (model.Point, bool) {
+	lat, okLat := values[fitRecordPositionLat]
+	lon, okLon := values[fitRecordPositionLong]
+	if !okLat || !okLon || !lat.valid || !lon.valid {
+		return model.Point{}, false
+	}
+
+	point := model.Point{
+		Lat:    float64(lat.raw) * semicircleDegrees,
+		Lon:    float64(lon.raw) * semicircleDegrees,
+		Status: model.StatusNormal,
+	}
+	if !isValidLatLon(point.Lat, point.Lon) {
+		return model.Point{}, false
+	}
+
+	if timestamp != 0 {
+		point.Time = time.Unix(int64(timestamp)+fitEpoch, 0).UTC()
+	}
+
+	if alt, ok := values[fitRecordEnhancedAltitude]; ok && alt.valid {
+		point.Elevation = float64(alt.raw)/5 - 500
+	} else if alt, ok := values[fitRecordAltitude]; ok && alt.valid {
+		point.Elevation = float64(alt.raw)/5 - 500
+	}
+
+	if speed, ok := values[fitRecordEnhancedSpeed]; ok && speed.valid {
+		point.Speed = float64(speed.raw) / 1000 * 3.6
+	} else if speed, ok := values[fitRecordSpeed]; ok && speed.valid {
+		point.Speed = float64(speed.raw) / 1000 * 3.6
+	}
+
+	if hr, ok := values[fitRecordHeartRate]; ok && hr.valid {
+		point.HeartRate = int(hr.raw)
+	}
+	if cad, ok := values[fitRecordCadence]; ok && cad.valid {
+		point.Cadence = int(cad.raw)
+	}
+
+	return point, true
+}
+
+// Validate validates FIT data with detailed error messages
+func (f *FITParser) Validate(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("FIT file is empty")
+	}
+
+	headerSize, dataSize, err := f.readHeader(data)
+	if err != nil {
+		return err
+	}
+
+	if headerSize == 14 {
+		if crc := binary.LittleEndian.Uint16(data[12:14]); crc != 0 && crc != fitCRC(data[:12]) {
+			return fmt.Errorf("invalid FIT format: header CRC mismatch")
+		}
+	}
+
+	end := headerSize + dataSize
+	if end+2 > len(data) {
+		if f.strictMode {
+			return fmt.Errorf("invalid FIT format: file is truncated")
+		}
+		return nil
+	}
+
+	if f.strictMode && binary.LittleEndian.Uint16(data[end:end+2]) != fitCRC(data[:end]) {
+		return fmt.Errorf("invalid FIT format: file CRC mismatch")
+	}
+
+	return nil
+}
+
+// fitCRCTable is the nibble lookup table of the FIT CRC-16
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// fitCRC calculates the FIT CRC-16 of data
+func fitCRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// fitEncoder writes FIT data messages in little-endian architecture
+type fitEncoder struct {
+	buf bytes.Buffer
+}
+
+// define writes a definition message for a local message type
+func (e *fitEncoder) define(local byte, global uint16, fields []fitFieldDef) {
+	e.buf.WriteByte(0x40 | local)
+	e.buf.WriteByte(0) // Reserved
+	e.buf.WriteByte(0) // Little endian
+	binary.Write(&e.buf, binary.LittleEndian, global)
+	e.buf.WriteByte(byte(len(fields)))
+	for _, fd := range fields {
+		e.buf.Write([]byte{fd.num, byte(fd.size), fd.baseType})
+	}
+}
+
+// write writes a data message; values must match the definition field sizes
+func (e *fitEncoder) write(local byte, values ...interface{}) {
+	e.buf.WriteByte(local)
+	for _, v := range values {
+		binary.Write(&e.buf, binary.LittleEndian, v)
+	}
+}
+
+// fitAltitude encodes an elevation in meters as an enhanced_altitude value
+// (scale 5, offset 500), clamped to the range the field can represent
+func fitAltitude(elevation float64) uint32 {
+	raw := math.Round((elevation + 500) * 5)
+	switch {
+	case raw < 0:
+		return 0
+	case raw > 0xFFFFFFFE: // 0xFFFFFFFF is the invalid sentinel
+		return 0xFFFFFFFE
+	}
+	return uint32(raw)
+}
+
+// ToFIT converts points to a FIT activity file. FIT activities carry no
+// track name, so name is accepted only for symmetry with ToGPX and ToKML.
+func ToFIT(points []model.Point, name string) ([]byte, error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("failed to encode FIT: no points")
+	}
+
+	start, end := points[0].Time, points[len(points)-1].Time
+	if start.IsZero() || end.IsZero() {
+		return nil, fmt.Errorf("failed to encode FIT: points need timestamps")
+	}
+
+	enc := &fitEncoder{}
+
+	// file_id: activity file created by a development device
+	enc.define(0, fitMesgFileID, []fitFieldDef{
+		{num: 0, size: 1, baseType: fitEnum},    // type
+		{num: 1, size: 2, baseType: fitUint16},  // manufacturer
+		{num: 2, size: 2, baseType: fitUint16},  // product
+		{num: 3, size: 4, baseType: fitUint32z}, // serial_number
+		{num: 4, size: 4, baseType: fitUint32},  // time_created
+	})
+	enc.write(0, uint8(4), uint16(255), uint16(0), uint32(1), fitTimestamp(start))
+
+	enc.define(1, fitMesgRecord, []fitFieldDef{
+		{num: fitFieldTimestamp, size: 4, baseType: fitUint32},
+		{num: fitRecordPositionLat, size: 4, baseType: fitSint32},
+		{num: fitRecordPositionLong, size: 4, baseType: fitSint32},
+		{num: fitRecordDistance, size: 4, baseType: fitUint32},
+		{num: fitRecordEnhancedAltitude, size: 4, baseType: fitUint32},
+		{num: fitRecordEnhancedSpeed, size: 4, baseType: fitUint32},
+		{num: fitRecordHeartRate, size: 1, baseType: fitUint8},
+		{num: fitRecordCadence, size: 1, baseType: fitUint8},
+	})
+
+	// Devices that record altitude report it on every fix, so a zero in a
+	// track with elevation data is sea level rather than a missing value
+	hasElevation := false
+	for _, p := range points {
+		if p.Elevation != 0 {
+			hasElevation = true
+			break
+		}
+	}
+
+	distance := 0.0
+	for i, p := range points {
+		if i > 0 {
+			distance += model.HaversineDistance(points[i-1].Lat, points[i-1].Lon, p.Lat, p.Lon)
+		}
+
+		altitude := uint32(0xFFFFFFFF)
+		if hasElevation {
+			altitude = fitAltitude(p.Elevation)
+		}
+		speed := uint32(0xFFFFFFFF)
+		if p.Speed > 0 {
+			speed = uint32(math.Round(p.Speed / 3.6 * 1000))
+		}
+		heartRate := uint8(0xFF)
+		if p.HeartRate > 0 && p.HeartRate < 0xFF {
+			heartRate = uint8(p.HeartRate)
+		}
+		cadence := uint8(0xFF)
+		if p.Cadence > 0 && p.Cadence < 0xFF {
+			cadence = uint8(p.Cadence)
+		}
+
+		enc.write(1,
+			fitTimestamp(p.Time),
+			toSemicircles(p.Lat),
+			toSemicircles(p.Lon),
+			uint32(math.Round(distance*100)),
+			altitude,
+			speed,
+			heartRate,
+			cadence,
+		)
+	}
+
+	elapsed := uint32(math.Round(end.Sub(start).Seconds() * 1000))
+	totalDistance := uint32(math.Round(distance * 100))
+
+	// Devices only import activities that close with lap, session and activity
+	enc.define(2, fitMesgLap, []fitFieldDef{
+		{num: fitFieldTimestamp, size: 4, baseType: fitUint32},
+		{num: 0, size: 1, baseType: fitEnum},   // event
+		{num: 1, size: 1, baseType: fitEnum},   // event_type
+		{num: 2, size: 4, baseType: fitUint32}, // start_time
+		{num: 7, size: 4, baseType: fitUint32}, // total_elapsed_time
+		{num: 8, size: 4, baseType: fitUint32}, // total_timer_time
+		{num: 9, size: 4, baseType: fitUint32}, // total_distance
+	})
+	enc.write(2, fitTimestamp(end), uint8(9), uint8(1), fitTimestamp(start), elapsed, elapsed, totalDistance)
+
+	enc.define(3, fitMesgSession, []fitFieldDef{
+		{num: fitFieldTimestamp, size: 4, baseType: fitUint32},
+		{num: 0, size: 1, baseType: fitEnum},    // event
+		{num: 1, size: 1, baseType: fitEnum},    // event_type
+		{num: 2, size: 4, baseType: fitUint32},  // start_time
+		{num: 5, size: 1, baseType: fitEnum},    // sport
+		{num: 7, size: 4, baseType: fitUint32},  // total_elapsed_time
+		{num: 8, size: 4, baseType: fitUint32},  // total_timer_time
+		{num: 9, size: 4, baseType: fitUint32},  // total_distance
+		{num: 25, size: 2, baseType: fitUint16}, // first_lap_index
+		{num: 26, size: 2, baseType: fitUint16}, // num_laps
+	})
+	enc.write(3, fitTimestamp(end), uint8(8), uint8(1), fitTimestamp(start), uint8(0),
+		elapsed, elapsed, totalDistance, uint16(0), uint16(1))
+
+	enc.define(4, fitMesgActivity, []fitFieldDef{
+		{num: fitFieldTimestamp, size: 4, baseType: fitUint32},
+		{num: 0, size: 4, baseType: fitUint32}, // total_timer_time
+		{num: 1, size: 2, baseType: fitUint16}, // num_sessions
+		{num: 2, size: 1, baseType: fitEnum},   // type
+		{num: 3, size: 1, baseType: fitEnum},   // event
+		{num: 4, size: 1, baseType: fitEnum},   // event_type
+	})
+	enc.write(4, fitTimestamp(end), elapsed, uint16(1), uint8(0), uint8(26), uint8(1))
+
+	records := enc.buf.Bytes()
+
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = 0x20 // Protocol 2.0
+	binary.LittleEndian.PutUint16(header[2:4], 2132)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(records)))
+	copy(header[8:12], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:14], fitCRC(header[:12]))
+
+	out := bytes.Buffer{}
+	out.Write(header)
+	out.Write(records)
+	binary.Write(&out, binary.LittleEndian, fitCRC(out.Bytes()))
+
+	return out.Bytes(), nil
+}
+
+// fitTimestamp converts a time to FIT seconds since 1989-12-31
+func fitTimestamp(t time.Time) uint32 {
+	return uint32(t.Unix() - fitEpoch)
+}
+
+// toSemicircles converts degrees to FIT semicircles
+func toSemicircles(deg float64) int32 {
+	return int32(math.Round(deg / semicircleDegrees))
+}
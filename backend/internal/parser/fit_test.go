@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func loadFITFixture(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/sample.fit")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	return data
+}
+
+func TestFITParser_Parse(t *testing.T) {
+	parser := NewFITParser()
+
+	points, err := parser.Parse(loadFITFixture(t))
+	if err != nil {
+		t.Fatalf("Failed to parse FIT: %v", err)
+	}
+
+	// Ten records, one of which has no position
+	if len(points) != 9 {
+		t.Fatalf("Expected 9 points, got %d", len(points))
+	}
+
+	first := points[0]
+	if math.Abs(first.Lat-39.9042) > 1e-6 || math.Abs(first.Lon-116.4074) > 1e-6 {
+		t.Errorf("Expected position (39.9042, 116.4074), got (%f, %f)", first.Lat, first.Lon)
+	}
+	if first.Elevation != 50 {
+		t.Errorf("Expected elevation 50, got %f", first.Elevation)
+	}
+	if math.Abs(first.Speed-10.8) > 1e-9 {
+		t.Errorf("Expected speed 10.8 km/h, got %f", first.Speed)
+	}
+	if first.HeartRate != 120 || first.Cadence != 80 {
+		t.Errorf("Expected hr 120 and cadence 80, got %d and %d", first.HeartRate, first.Cadence)
+	}
+
+	start := time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !first.Time.Equal(start) {
+		t.Errorf("Expected first timestamp %s, got %s", start, first.Time)
+	}
+
+	// Big-endian records use enhanced altitude
+	if points[4].Elevation != 55 {
+		t.Errorf("Expected enhanced elevation 55, got %f", points[4].Elevation)
+	}
+
+	// Compressed timestamp headers continue from the last full timestamp
+	last := points[len(points)-1]
+	if want := start.Add(9 * time.Second); !last.Time.Equal(want) {
+		t.Errorf("Expected compressed timestamp %s, got %s", want, last.Time)
+	}
+	if last.HeartRate != 129 {
+		t.Errorf("Expected hr 129, got %d", last.HeartRate)
+	}
+}
+
+func TestFITParser_RoundTrip(t *testing.T) {
+	parser := NewFITParser()
+
+	original, err := parser.Parse(loadFITFixture(t))
+	if err != nil {
+		t.Fatalf("Failed to parse FIT: %v", err)
+	}
+
+	data, err := ToFIT(original, "Round Trip")
+	if err != nil {
+		t.Fatalf("Failed to encode FIT: %v", err)
+	}
+
+	parser.strictMode = true
+	if err := parser.Validate(data); err != nil {
+		t.Fatalf("Encoded FIT failed strict validation: %v", err)
+	}
+
+	decoded, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse encoded FIT: %v", err)
+	}
+
+	if len(decoded) != len(original) {
+		t.Fatalf("Expected %d points, got %d", len(original), len(decoded))
+	}
+
+	for i := range original {
+		o, d := original[i], decoded[i]
+		if math.Abs(o.Lat-d.Lat) > 1e-6 || math.Abs(o.Lon-d.Lon) > 1e-6 {
+			t.Errorf("Point %d: position changed from (%f, %f) to (%f, %f)", i, o.Lat, o.Lon, d.Lat, d.Lon)
+		}
+		if !o.Time.Equal(d.Time) {
+			t.Errorf("Point %d: time changed from %s to %s", i, o.Time, d.Time)
+		}
+		if math.Abs(o.Elevation-d.Elevation) > 0.2 {
+			t.Errorf("Point %d: elevation changed from %f to %f", i, o.Elevation, d.Elevation)
+		}
+		if o.HeartRate != d.HeartRate || o.Cadence != d.Cadence {
+			t.Errorf("Point %d: sensors changed from %d/%d to %d/%d", i, o.HeartRate, o.Cadence, d.HeartRate, d.Cadence)
+		}
+	}
+}
+
+func TestFITParser_Validate(t *testing.T) {
+	parser := NewFITParser()
+
+	if err := parser.Validate(loadFITFixture(t)); err != nil {
+		t.Errorf("Expected valid FIT, got error: %v", err)
+	}
+
+	if err := parser.Validate([]byte("")); err == nil {
+		t.Error("Expected error for empty data")
+	}
+
+	if err := parser.Validate([]byte("<?xml version=\"1.0\"?><gpx></gpx>")); err == nil {
+		t.Error("Expected error for non-FIT data")
+	}
+}
+
+func TestToFIT_Elevation(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	points := []model.Point{
+		{Lat: 39.9, Lon: 116.4, Time: start, Elevation: 0},
+		{Lat: 39.9001, Lon: 116.4, Time: start.Add(time.Second), Elevation: 12.4},
+		{Lat: 39.9002, Lon: 116.4, Time: start.Add(2 * time.Second), Elevation: -700},
+	}
+
+	data, err := ToFIT(points, "")
+	if err != nil {
+		t.Fatalf("Failed to encode FIT: %v", err)
+	}
+
+	decoded, err := NewFITParser().Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse encoded FIT: %v", err)
+	}
+
+	want := []float64{0, 12.4, -500}
+	for i, ele := range want {
+		if math.Abs(decoded[i].Elevation-ele) > 0.2 {
+			t.Errorf("Point %d: expected elevation %f, got %f", i, ele, decoded[i].Elevation)
+		}
+	}
+
+	if got := fitAltitude(0); got != 2500 {
+		t.Errorf("Expected sea level to encode as 2500, got %d", got)
+	}
+	if got := fitAltitude(1e9); got != 0xFFFFFFFE {
+		t.Errorf("Expected out-of-range altitude to clamp, got %d", got)
+	}
+}
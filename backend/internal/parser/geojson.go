@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// geoJSONTypes lists the object types defined by RFC 7946
+var geoJSONTypes = map[string]bool{
+	"FeatureCollection":  true,
+	"Feature":            true,
+	"Point":              true,
+	"MultiPoint":         true,
+	"LineString":         true,
+	"MultiLineString":    true,
+	"Polygon":            true,
+	"MultiPolygon":       true,
+	"GeometryCollection": true,
+}
+
+// GeoJSONParser handles GeoJSON file parsing
+type GeoJSONParser struct {
+	strictMode bool
+}
+
+// NewGeoJSONParser creates a new GeoJSON parser
+func NewGeoJSONParser() *GeoJSONParser {
+	return &GeoJSONParser{
+		strictMode: false,
+	}
+}
+
+// GeoJSONObject represents any GeoJSON object; only the members used for
+// trajectories are decoded
+type GeoJSONObject struct {
+	Type        string                     `json:"type"`
+	Features    []GeoJSONObject            `json:"features,omitempty"`
+	Geometry    *GeoJSONObject             `json:"geometry,omitempty"`
+	Geometries  []GeoJSONObject            `json:"geometries,omitempty"`
+	Coordinates json.RawMessage            `json:"coordinates,omitempty"`
+	Properties  map[string]json.RawMessage `json:"properties,omitempty"`
+}
+
+// Parse parses GeoJSON data from bytes
+func (g *GeoJSONParser) Parse(data []byte) ([]model.Point, error) {
+	var obj GeoJSONObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+
+	return g.ExtractPoints(&obj)
+}
+
+// ParseReader parses GeoJSON data from reader
+func (g *GeoJSONParser) ParseReader(r io.Reader) ([]model.Point, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoJSON: %w", err)
+	}
+	return g.Parse(data)
+}
+
+// ExtractPoints extracts points from line and point geometries
+func (g *GeoJSONParser) ExtractPoints(obj *GeoJSONObject) ([]model.Point, error) {
+	var points []model.Point
+	if err := g.collect(obj, nil, &points); err != nil {
+		return nil, err
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("GeoJSON file contains no coordinate positions")
+	}
+
+	for i := range points {
+		points[i].Index = i
+	}
+
+	return points, nil
+}
+
+// collect walks the object tree, passing feature properties down to geometries
+func (g *GeoJSONParser) collect(obj *GeoJSONObject, props map[string]json.RawMessage, points *[]model.Point) error {
+	switch obj.Type {
+	case "FeatureCollection":
+		// Each feature is a track
+		for i := range obj.Features {
+			start := len(*points)
+			if err := g.collect(&obj.Features[i], nil, points); err != nil {
+				return err
+			}
+			for j := start; j < len(*points); j++ {
+				(*points)[j].Track = i
+			}
+		}
+	case "Feature":
+		if obj.Geometry != nil {
+			return g.collect(obj.Geometry, obj.Properties, points)
+		}
+	case "GeometryCollection":
+		for i := range obj.Geometries {
+			if err := g.collect(&obj.Geometries[i], props, points); err != nil {
+				return err
+			}
+		}
+	case "Point":
+		var coord []float64
+		if err := json.Unmarshal(obj.Coordinates, &coord); err != nil {
+			return fmt.Errorf("invalid Point coordinates: %w", err)
+		}
+		g.appendPositions(points, [][]float64{coord}, []string{geoJSONString(props, "time", "timestamp")})
+	case "MultiPoint", "LineString":
+		var coords [][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return fmt.Errorf("invalid %s coordinates: %w", obj.Type, err)
+		}
+		var times []string
+		unmarshalGeoJSONProperty(props, &times, "coordTimes", "times")
+		g.appendPositions(points, coords, times)
+	case "MultiLineString":
+		var lines [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &lines); err != nil {
+			return fmt.Errorf("invalid MultiLineString coordinates: %w", err)
+		}
+		var times [][]string
+		unmarshalGeoJSONProperty(props, &times, "coordTimes", "times")
+		// Each line is a segment
+		for i, line := range lines {
+			var lineTimes []string
+			if i < len(times) {
+				lineTimes = times[i]
+			}
+			start := len(*points)
+			g.appendPositions(points, line, lineTimes)
+			for j := start; j < len(*points); j++ {
+				(*points)[j].Segment = i
+			}
+		}
+	default:
+		// Polygons and unknown types carry no trajectory
+	}
+
+	return nil
+}
+
+// appendPositions converts [lon, lat, ele?] positions to points
+func (g *GeoJSONParser) appendPositions(points *[]model.Point, coords [][]float64, times []string) {
+	for i, c := range coords {
+		if len(c) < 2 || !isValidLatLon(c[1], c[0]) {
+			continue
+		}
+
+		point := model.Point{
+			Lat:    c[1],
+			Lon:    c[0],
+			Status: model.StatusNormal,
+		}
+		if len(c) > 2 {
+			point.Elevation = c[2]
+		}
+		if i < len(times) {
+			if ts, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(times[i])); err == nil {
+				point.Time = ts
+			}
+		}
+
+		*points = append(*points, point)
+	}
+}
+
+// Validate validates GeoJSON data with detailed error messages
+func (g *GeoJSONParser) Validate(data []byte) error {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return fmt.Errorf("GeoJSON file is empty")
+	}
+
+	var obj GeoJSONObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid GeoJSON format: %w (hint: check if file is valid JSON)", err)
+	}
+
+	if !geoJSONTypes[obj.Type] {
+		return fmt.Errorf("not a GeoJSON file - missing or unknown \"type\" member")
+	}
+
+	if _, err := g.ExtractPoints(&obj); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// geoJSONString returns the first string property found under the given keys
+func geoJSONString(props map[string]json.RawMessage, keys ...string) string {
+	var value string
+	unmarshalGeoJSONProperty(props, &value, keys...)
+	return value
+}
+
+// unmarshalGeoJSONProperty decodes the first property found under the given keys
+func unmarshalGeoJSONProperty(props map[string]json.RawMessage, v interface{}, keys ...string) {
+	for _, key := range keys {
+		if raw, ok := props[key]; ok && json.Unmarshal(raw, v) == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGeoJSONParser_Parse(t *testing.T) {
+	parser := NewGeoJSONParser()
+
+	data := []byte(`{
+  "type": "FeatureCollection",
+  "features": [
+    {
+      "type": "Feature",
+      "properties": {"coordTimes": ["2024-01-01T08:00:00Z", "2024-01-01T08:00:05Z"]},
+      "geometry": {"type": "LineString", "coordinates": [[116.4074, 39.9042, 50], [116.4084, 39.9052, 51]]}
+    },
+    {
+      "type": "Feature",
+      "properties": {"time": "2024-01-01T08:00:10Z"},
+      "geometry": {"type": "Point", "coordinates": [116.4094, 39.9062]}
+    },
+    {
+      "type": "Feature",
+      "properties": {},
+      "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}
+    }
+  ]
+}`)
+
+	points, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse GeoJSON: %v", err)
+	}
+
+	if len(points) != 3 {
+		t.Fatalf("Expected 3 points, got %d", len(points))
+	}
+
+	if points[0].Lat != 39.9042 || points[0].Lon != 116.4074 || points[0].Elevation != 50 {
+		t.Errorf("Expected (39.9042, 116.4074, 50), got (%f, %f, %f)", points[0].Lat, points[0].Lon, points[0].Elevation)
+	}
+
+	if want := time.Date(2024, 1, 1, 8, 0, 10, 0, time.UTC); !points[2].Time.Equal(want) {
+		t.Errorf("Expected point time %s, got %s", want, points[2].Time)
+	}
+	if points[2].Index != 2 {
+		t.Errorf("Expected index 2, got %d", points[2].Index)
+	}
+}
+
+func TestGeoJSONParser_Validate(t *testing.T) {
+	parser := NewGeoJSONParser()
+
+	if err := parser.Validate([]byte("")); err == nil {
+		t.Error("Expected error for empty data")
+	}
+
+	if err := parser.Validate([]byte(`{"name": "not geojson"}`)); err == nil {
+		t.Error("Expected error for JSON without a GeoJSON type")
+	}
+
+	polygonOnly := []byte(`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 0]]]}`)
+	if err := parser.Validate(polygonOnly); err == nil {
+		t.Error("Expected error for GeoJSON without line or point geometry")
+	}
+}
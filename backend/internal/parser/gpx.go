@@ -48,18 +48,25 @@ func (g *GPXParser) ParseReader(r io.Reader) ([]model.Point, error) {
 func (g *GPXParser) ExtractPoints(gpx *GPX) ([]model.Point, error) {
 	var allPoints []model.Point
 
-	// Extract from tracks
-	for _, track := range gpx.Tracks {
-		for _, segment := range track.Segments {
+	// Extract from tracks, keeping the trk/trkseg layout
+	for t, track := range gpx.Tracks {
+		for s, segment := range track.Segments {
 			points := g.extractTrackPoints(segment.Points)
+			for i := range points {
+				points[i].Track = t
+				points[i].Segment = s
+			}
 			allPoints = append(allPoints, points...)
 		}
 	}
 
-	// Extract from routes if no tracks
+	// Extract from routes if no tracks, one track per route
 	if len(allPoints) == 0 {
-		for _, route := range gpx.Routes {
+		for r, route := range gpx.Routes {
 			points := g.extractRoutePoints(route.Points)
+			for i := range points {
+				points[i].Track = r
+			}
 			allPoints = append(allPoints, points...)
 		}
 	}
@@ -142,10 +149,16 @@ func (g *GPXParser) extractTrackPoints(points []GPXPoint) []model.Point {
 		}
 
 		point := model.Point{
-			Lat:       p.Lat,
-			Lon:       p.Lon,
-			Elevation: p.Elevation,
-			Status:    model.StatusNormal,
+			Lat:        p.Lat,
+			Lon:        p.Lon,
+			Elevation:  p.Elevation,
+			Status:     model.StatusNormal,
+			FixType:    strings.TrimSpace(p.Fix),
+			HDOP:       p.HDOP,
+			VDOP:       p.VDOP,
+			PDOP:       p.PDOP,
+			Satellites: p.Satellites,
+			Course:     p.Course,
 		}
 
 		// Try multiple time formats
@@ -153,6 +166,17 @@ func (g *GPXParser) extractTrackPoints(points []GPXPoint) []model.Point {
 			point.Time = t
 		}
 
+		// Garmin TrackPointExtension sensor data
+		if p.Extensions != nil && p.Extensions.TrackPointExtension != nil {
+			ext := p.Extensions.TrackPointExtension
+			point.HeartRate = ext.HeartRate
+			point.Cadence = ext.Cadence
+			point.Temperature = ext.Temperature
+			if point.Course == nil {
+				point.Course = ext.Course
+			}
+		}
+
 		result = append(result, point)
 	}
 
@@ -222,6 +246,8 @@ func (g *GPXParser) Validate(data []byte) error {
 // GPX represents the root GPX element
 type GPX struct {
 	XMLName   xml.Name   `xml:"gpx"`
+	Xmlns     string     `xml:"xmlns,attr,omitempty"`
+	XmlnsTPX  string     `xml:"xmlns:gpxtpx,attr,omitempty"`
 	Version   string     `xml:"version,attr"`
 	Creator   string     `xml:"creator,attr"`
 	Name      string     `xml:"name"`
@@ -249,36 +275,87 @@ type GPXRoute struct {
 
 // GPXPoint represents a waypoint/track point
 type GPXPoint struct {
-	Lat       float64  `xml:"lat,attr"`
-	Lon       float64  `xml:"lon,attr"`
-	Elevation float64  `xml:"ele"`
-	Time      string   `xml:"time"`
-	Name      string   `xml:"name"`
-	Speed     float64  `xml:"speed"`
+	Lat        float64        `xml:"lat,attr"`
+	Lon        float64        `xml:"lon,attr"`
+	Elevation  float64        `xml:"ele"`
+	Time       string         `xml:"time"`
+	Course     *float64       `xml:"course,omitempty"` // GPX 1.0 only
+	Speed      float64        `xml:"speed,omitempty"`  // GPX 1.0 only
+	Name       string         `xml:"name,omitempty"`
+	Fix        string         `xml:"fix,omitempty"`
+	Satellites int            `xml:"sat,omitempty"`
+	HDOP       float64        `xml:"hdop,omitempty"`
+	VDOP       float64        `xml:"vdop,omitempty"`
+	PDOP       float64        `xml:"pdop,omitempty"`
 	Extensions *GPXExtensions `xml:"extensions"`
 }
 
 // GPXExtensions represents GPX extensions
 type GPXExtensions struct {
-	// Can be extended for vendor-specific data
+	TrackPointExtension *GPXTrackPointExtension `xml:"TrackPointExtension"`
+}
+
+// gpxTPXNamespace is the Garmin TrackPointExtension v2 namespace
+const gpxTPXNamespace = "http://www.garmin.com/xmlschemas/TrackPointExtension/v2"
+
+// GPXTrackPointExtension represents the Garmin TrackPointExtension (v1 and v2)
+// Temperature and course are nil when absent, since 0 °C and due north are
+// real values.
+type GPXTrackPointExtension struct {
+	Temperature *float64 `xml:"atemp"`
+	HeartRate   int      `xml:"hr"`
+	Cadence     int      `xml:"cad"`
+	Course      *float64 `xml:"course"` // v2 only
 }
 
-// ToGPX converts points to GPX format
+// MarshalXML writes the extension with the gpxtpx prefix declared on the gpx
+// element, since encoding/xml cannot emit namespace prefixes itself
+func (t GPXTrackPointExtension) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "gpxtpx:TrackPointExtension"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	// Element order follows the v2 schema; a heart rate or cadence of 0 is
+	// no reading
+	optional := func(v int) *float64 {
+		if v == 0 {
+			return nil
+		}
+		f := float64(v)
+		return &f
+	}
+	fields := []struct {
+		name  string
+		value *float64
+	}{
+		{"atemp", t.Temperature},
+		{"hr", optional(t.HeartRate)},
+		{"cad", optional(t.Cadence)},
+		{"course", t.Course},
+	}
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		if err := e.EncodeElement(*f.value, xml.StartElement{Name: xml.Name{Local: "gpxtpx:" + f.name}}); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// ToGPX converts points to GPX format, writing one trk per track and one
+// trkseg per segment
 func ToGPX(points []model.Point, name string) ([]byte, error) {
 	gpx := GPX{
-		Version: "1.1",
-		Creator: "PositionDoctor",
-		Name:    name,
-		Tracks: []GPXTrack{
-			{
-				Name: name,
-				Segments: []GPXSegment{
-					{
-						Points: makeGPXPoints(points),
-					},
-				},
-			},
-		},
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		XmlnsTPX: gpxTPXNamespace,
+		Version:  "1.1",
+		Creator:  "PositionDoctor",
+		Name:     name,
+		Tracks:   makeGPXTracks(points, name),
 	}
 
 	data, err := xml.MarshalIndent(gpx, "", "  ")
@@ -294,20 +371,57 @@ func ToGPX(points []model.Point, name string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// makeGPXTracks groups points into tracks and segments
+func makeGPXTracks(points []model.Point, name string) []GPXTrack {
+	var tracks []GPXTrack
+	lastTrack := 0
+	for _, segment := range model.SplitSegments(points) {
+		if len(tracks) == 0 || segment[0].Track != lastTrack {
+			tracks = append(tracks, GPXTrack{Name: name})
+			lastTrack = segment[0].Track
+		}
+		track := &tracks[len(tracks)-1]
+		track.Segments = append(track.Segments, GPXSegment{Points: makeGPXPoints(segment)})
+	}
+
+	if len(tracks) == 0 {
+		tracks = append(tracks, GPXTrack{Name: name, Segments: []GPXSegment{{}}})
+	}
+
+	return tracks
+}
+
 // makeGPXPoints converts model points to GPX points
 func makeGPXPoints(points []model.Point) []GPXPoint {
 	result := make([]GPXPoint, len(points))
 
 	for i, p := range points {
 		result[i] = GPXPoint{
-			Lat:       p.Lat,
-			Lon:       p.Lon,
-			Elevation: p.Elevation,
+			Lat:        p.Lat,
+			Lon:        p.Lon,
+			Elevation:  p.Elevation,
+			Fix:        p.FixType,
+			Satellites: p.Satellites,
+			HDOP:       p.HDOP,
+			VDOP:       p.VDOP,
+			PDOP:       p.PDOP,
 		}
 
 		if !p.Time.IsZero() {
 			result[i].Time = p.Time.Format(time.RFC3339)
 		}
+
+		// Course has no GPX 1.1 element, so it travels in the extension
+		if p.HeartRate != 0 || p.Cadence != 0 || p.Temperature != nil || p.Course != nil {
+			result[i].Extensions = &GPXExtensions{
+				TrackPointExtension: &GPXTrackPointExtension{
+					Temperature: p.Temperature,
+					HeartRate:   p.HeartRate,
+					Cadence:     p.Cadence,
+					Course:      p.Course,
+				},
+			}
+		}
 	}
 
 	return result
@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"encoding/xml"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
 )
 
 func TestGPXParser_Parse(t *testing.T) {
@@ -118,6 +121,169 @@ func TestToGPX(t *testing.T) {
 	}
 }
 
+func TestGPXParser_TrackSegments(t *testing.T) {
+	gpxData := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="PositionDoctor">
+  <trk>
+    <trkseg>
+      <trkpt lat="39.9042" lon="116.4074"><time>2024-01-01T08:00:00Z</time></trkpt>
+      <trkpt lat="39.9043" lon="116.4075"><time>2024-01-01T08:00:01Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="39.9142" lon="116.4174"><time>2024-01-01T09:00:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+  <trk>
+    <trkseg>
+      <trkpt lat="39.9242" lon="116.4274"><time>2024-01-01T10:00:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`)
+
+	points, err := NewGPXParser().Parse(gpxData)
+	if err != nil {
+		t.Fatalf("Failed to parse GPX: %v", err)
+	}
+
+	expected := [][2]int{{0, 0}, {0, 0}, {0, 1}, {1, 0}}
+	if len(points) != len(expected) {
+		t.Fatalf("Expected %d points, got %d", len(expected), len(points))
+	}
+	for i, e := range expected {
+		if points[i].Track != e[0] || points[i].Segment != e[1] {
+			t.Errorf("Point %d: expected track/segment %v, got %d/%d", i, e, points[i].Track, points[i].Segment)
+		}
+	}
+
+	// Speeds are not computed across the pause between segments
+	PostProcess(points)
+	if points[2].Speed != 0 {
+		t.Errorf("Expected no speed at segment start, got %f", points[2].Speed)
+	}
+
+	data, err := ToGPX(points, "Test")
+	if err != nil {
+		t.Fatalf("Failed to create GPX: %v", err)
+	}
+
+	gpx := &GPX{}
+	if err := xml.Unmarshal(data, gpx); err != nil {
+		t.Fatalf("Failed to re-parse exported GPX: %v", err)
+	}
+	if len(gpx.Tracks) != 2 {
+		t.Fatalf("Expected 2 tracks, got %d", len(gpx.Tracks))
+	}
+	if len(gpx.Tracks[0].Segments) != 2 || len(gpx.Tracks[1].Segments) != 1 {
+		t.Errorf("Expected 2 and 1 segments, got %d and %d", len(gpx.Tracks[0].Segments), len(gpx.Tracks[1].Segments))
+	}
+}
+
+func TestGPXParser_SensorExtensions(t *testing.T) {
+	gpxData := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="Garmin" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <trk>
+    <trkseg>
+      <trkpt lat="39.9042" lon="116.4074">
+        <ele>50.5</ele>
+        <time>2024-01-01T08:00:00Z</time>
+        <fix>3d</fix>
+        <sat>9</sat>
+        <hdop>0.8</hdop>
+        <vdop>1.2</vdop>
+        <pdop>1.4</pdop>
+        <extensions>
+          <gpxtpx:TrackPointExtension>
+            <gpxtpx:atemp>21.5</gpxtpx:atemp>
+            <gpxtpx:hr>132</gpxtpx:hr>
+            <gpxtpx:cad>88</gpxtpx:cad>
+          </gpxtpx:TrackPointExtension>
+        </extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`)
+
+	points, err := NewGPXParser().Parse(gpxData)
+	if err != nil {
+		t.Fatalf("Failed to parse GPX: %v", err)
+	}
+
+	check := func(points []model.Point) {
+		p := points[0]
+		if p.FixType != "3d" || p.Satellites != 9 {
+			t.Errorf("Expected fix 3d with 9 satellites, got %q with %d", p.FixType, p.Satellites)
+		}
+		if p.HDOP != 0.8 || p.VDOP != 1.2 || p.PDOP != 1.4 {
+			t.Errorf("Expected DOP 0.8/1.2/1.4, got %v/%v/%v", p.HDOP, p.VDOP, p.PDOP)
+		}
+		if p.HeartRate != 132 || p.Cadence != 88 || p.Temperature == nil || *p.Temperature != 21.5 {
+			t.Errorf("Expected hr 132, cad 88, atemp 21.5, got %d, %d, %v", p.HeartRate, p.Cadence, p.Temperature)
+		}
+		if p.Course != nil {
+			t.Errorf("Expected no course, got %v", *p.Course)
+		}
+	}
+	check(points)
+
+	// Sensor data survives export
+	data, err := ToGPX(points, "Test")
+	if err != nil {
+		t.Fatalf("Failed to create GPX: %v", err)
+	}
+	if !contains(string(data), `xmlns:gpxtpx="`+gpxTPXNamespace+`"`) {
+		t.Error("Expected gpxtpx namespace declaration in GPX output")
+	}
+
+	exported, err := NewGPXParser().Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to re-parse exported GPX: %v", err)
+	}
+	check(exported)
+}
+
+func TestGPXParser_ZeroSensorValuesRoundTrip(t *testing.T) {
+	gpxData := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="Garmin" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v2">
+  <trk>
+    <trkseg>
+      <trkpt lat="39.9042" lon="116.4074">
+        <time>2024-01-01T08:00:00Z</time>
+        <extensions>
+          <gpxtpx:TrackPointExtension>
+            <gpxtpx:atemp>0</gpxtpx:atemp>
+            <gpxtpx:course>0</gpxtpx:course>
+          </gpxtpx:TrackPointExtension>
+        </extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`)
+
+	points, err := NewGPXParser().Parse(gpxData)
+	if err != nil {
+		t.Fatalf("Failed to parse GPX: %v", err)
+	}
+
+	data, err := ToGPX(points, "Test")
+	if err != nil {
+		t.Fatalf("Failed to create GPX: %v", err)
+	}
+	exported, err := NewGPXParser().Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to re-parse exported GPX: %v", err)
+	}
+
+	p := exported[0]
+	if p.Temperature == nil || *p.Temperature != 0 {
+		t.Errorf("Expected atemp 0 to survive export, got %v in:\n%s", p.Temperature, data)
+	}
+	if p.Course == nil || *p.Course != 0 {
+		t.Errorf("Expected course 0 to survive export, got %v in:\n%s", p.Course, data)
+	}
+}
+
 // Helper function for string contains
 func contains(s, substr string) bool {
 	return strings.Contains(s, substr)
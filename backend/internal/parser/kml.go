@@ -64,21 +64,23 @@ func (k *KMLParser) ParseReader(r io.Reader) ([]model.Point, error) {
 	return k.Parse(data)
 }
 
-// ExtractPoints extracts points from KML structure
+// ExtractPoints extracts points from KML structure. Each line placemark is a
+// track and each of its geometries a segment; runs of point placemarks share
+// one track.
 func (k *KMLParser) ExtractPoints(kml *KML) ([]model.Point, error) {
-	var allPoints []model.Point
+	c := &kmlCollector{track: -1}
 
 	// Extract from LineString
 	for _, doc := range kml.Documents {
-		allPoints = append(allPoints, k.extractFromDocument(doc)...)
+		k.extractFromDocument(c, doc)
 	}
 
 	// Extract from Placemarks
 	for _, pm := range kml.Placemarks {
-		points := k.extractFromPlacemark(pm)
-		allPoints = append(allPoints, points...)
+		k.extractFromPlacemark(c, pm)
 	}
 
+	allPoints := c.points
 	if len(allPoints) == 0 {
 		return nil, fmt.Errorf("no points found in KML file")
 	}
@@ -91,65 +93,113 @@ func (k *KMLParser) ExtractPoints(kml *KML) ([]model.Point, error) {
 	return allPoints, nil
 }
 
-// extractFromDocument extracts points from a document
-func (k *KMLParser) extractFromDocument(doc KMLDocument) []model.Point {
-	var points []model.Point
+// kmlCollector accumulates points while numbering tracks and segments
+type kmlCollector struct {
+	points      []model.Point
+	track       int
+	inPointsRun bool
+}
+
+// addSegments appends the geometries of one placemark as a new track
+func (c *kmlCollector) addSegments(segments [][]model.Point) {
+	c.inPointsRun = false
+	c.track++
+
+	segment := 0
+	for _, points := range segments {
+		if len(points) == 0 {
+			continue
+		}
+		for i := range points {
+			points[i].Track = c.track
+			points[i].Segment = segment
+		}
+		c.points = append(c.points, points...)
+		segment++
+	}
+}
+
+// addPoint appends a point placemark to the current run of points
+func (c *kmlCollector) addPoint(p model.Point) {
+	if !c.inPointsRun {
+		c.inPointsRun = true
+		c.track++
+	}
+	p.Track = c.track
+	c.points = append(c.points, p)
+}
 
+// extractFromDocument extracts points from a document
+func (k *KMLParser) extractFromDocument(c *kmlCollector, doc KMLDocument) {
 	// From Placemarks
 	for _, pm := range doc.Placemarks {
-		points = append(points, k.extractFromPlacemark(pm)...)
+		k.extractFromPlacemark(c, pm)
 	}
 
 	// From nested documents
 	for _, nestedDoc := range doc.Documents {
-		points = append(points, k.extractFromDocument(nestedDoc)...)
+		k.extractFromDocument(c, nestedDoc)
 	}
 
 	// From folders
 	for _, folder := range doc.Folders {
-		points = append(points, k.extractFromFolder(folder)...)
+		k.extractFromFolder(c, folder)
 	}
-
-	return points
 }
 
 // extractFromFolder extracts points from a folder
-func (k *KMLParser) extractFromFolder(folder KMLFolder) []model.Point {
-	var points []model.Point
-
+func (k *KMLParser) extractFromFolder(c *kmlCollector, folder KMLFolder) {
 	for _, pm := range folder.Placemarks {
-		points = append(points, k.extractFromPlacemark(pm)...)
+		k.extractFromPlacemark(c, pm)
 	}
 
 	for _, nestedFolder := range folder.Folders {
-		points = append(points, k.extractFromFolder(nestedFolder)...)
+		k.extractFromFolder(c, nestedFolder)
 	}
-
-	return points
 }
 
 // extractFromPlacemark extracts points from a placemark
-func (k *KMLParser) extractFromPlacemark(pm KMLPlacemark) []model.Point {
-	var points []model.Point
+func (k *KMLParser) extractFromPlacemark(c *kmlCollector, pm KMLPlacemark) {
+	var segments [][]model.Point
 
 	// From LineString
 	if pm.LineString != nil {
-		points = append(points, k.parseLineString(pm.LineString.Coordinates)...)
+		segments = append(segments, k.parseLineString(pm.LineString.Coordinates))
 	}
 
 	// From Track (if available)
 	if pm.Track != nil {
-		points = append(points, k.parseTrack(pm.Track)...)
+		segments = append(segments, k.parseTrack(pm.Track))
+	}
+
+	// From MultiGeometry, one segment per line or track
+	if pm.MultiGeometry != nil {
+		for _, ls := range pm.MultiGeometry.LineStrings {
+			segments = append(segments, k.parseLineString(ls.Coordinates))
+		}
+		for i := range pm.MultiGeometry.Tracks {
+			segments = append(segments, k.parseTrack(&pm.MultiGeometry.Tracks[i]))
+		}
+	}
+
+	// From gx:MultiTrack
+	if pm.MultiTrack != nil {
+		for i := range pm.MultiTrack.Tracks {
+			segments = append(segments, k.parseTrack(&pm.MultiTrack.Tracks[i]))
+		}
+	}
+
+	if len(segments) > 0 {
+		c.addSegments(segments)
+		return
 	}
 
 	// From Point
 	if pm.Point != nil {
 		if p := k.parsePoint(pm.Point); p != nil {
-			points = append(points, *p)
+			c.addPoint(*p)
 		}
 	}
-
-	return points
 }
 
 // parseLineString parses coordinate string from LineString
@@ -328,32 +378,9 @@ func (k *KMLParser) Validate(data []byte) error {
 		return fmt.Errorf("KML file contains no documents or placemarks")
 	}
 
-	// Check for coordinate data
-	hasCoords := false
-	for _, pm := range kml.Placemarks {
-		if pm.LineString != nil && strings.TrimSpace(pm.LineString.Coordinates) != "" {
-			hasCoords = true
-			break
-		}
-		if pm.Point != nil && strings.TrimSpace(pm.Point.Coordinates) != "" {
-			hasCoords = true
-			break
-		}
-	}
-	if !hasCoords {
-		for _, doc := range kml.Documents {
-			for _, pm := range doc.Placemarks {
-				if pm.LineString != nil && strings.TrimSpace(pm.LineString.Coordinates) != "" {
-					hasCoords = true
-					break
-				}
-				if pm.Point != nil && strings.TrimSpace(pm.Point.Coordinates) != "" {
-					hasCoords = true
-					break
-				}
-			}
-		}
-	}
+	// Check for coordinate data in any supported geometry
+	_, extractErr := k.ExtractPoints(kml)
+	hasCoords := extractErr == nil
 
 	if !hasCoords {
 		return fmt.Errorf("KML file has structure but no coordinate data")
@@ -374,6 +401,7 @@ func countPlacemarksInFolder(folder KMLFolder) int {
 // KML represents the root KML element
 type KML struct {
 	XMLName   xml.Name     `xml:"kml"`
+	Xmlns     string       `xml:"xmlns,attr,omitempty"`
 	Documents []KMLDocument `xml:"Document"`
 	Placemarks []KMLPlacemark `xml:"Placemark"`
 }
@@ -395,10 +423,23 @@ type KMLFolder struct {
 
 // KMLPlacemark represents a KML placemark
 type KMLPlacemark struct {
-	Name       string        `xml:"name"`
-	LineString *KMLLineString `xml:"LineString"`
-	Point      *KMLPoint      `xml:"Point"`
-	Track      *KMLTrack      `xml:"Track"`
+	Name          string            `xml:"name"`
+	LineString    *KMLLineString    `xml:"LineString"`
+	Point         *KMLPoint         `xml:"Point"`
+	Track         *KMLTrack         `xml:"Track"`
+	MultiGeometry *KMLMultiGeometry `xml:"MultiGeometry"`
+	MultiTrack    *KMLMultiTrack    `xml:"MultiTrack"`
+}
+
+// KMLMultiGeometry represents a MultiGeometry of lines or tracks
+type KMLMultiGeometry struct {
+	LineStrings []KMLLineString `xml:"LineString"`
+	Tracks      []KMLTrack      `xml:"Track"`
+}
+
+// KMLMultiTrack represents a GX multi-track
+type KMLMultiTrack struct {
+	Tracks []KMLTrack `xml:"Track"`
 }
 
 // KMLLineString represents a line string
@@ -425,24 +466,44 @@ type KMLTrackCoord struct {
 	When      time.Time `xml:"when,omitempty"`
 }
 
-// ToKML converts points to KML format
+// ToKML converts points to KML format, writing one placemark per track and
+// a MultiGeometry when a track has several segments
 func ToKML(points []model.Point, name string) ([]byte, error) {
-	coordStrings := make([]string, len(points))
-	for i, p := range points {
-		if p.Elevation > 0 {
-			coordStrings[i] = fmt.Sprintf("%.8f,%.8f,%.2f", p.Lon, p.Lat, p.Elevation)
-		} else {
-			coordStrings[i] = fmt.Sprintf("%.8f,%.8f", p.Lon, p.Lat)
+	var placemarks []KMLPlacemark
+	var lines []KMLLineString
+	lastTrack := 0
+
+	flush := func() {
+		switch len(lines) {
+		case 0:
+			return
+		case 1:
+			placemarks = append(placemarks, KMLPlacemark{Name: name, LineString: &lines[0]})
+		default:
+			placemarks = append(placemarks, KMLPlacemark{Name: name, MultiGeometry: &KMLMultiGeometry{LineStrings: lines}})
 		}
+		lines = nil
+	}
+
+	for _, segment := range model.SplitSegments(points) {
+		if segment[0].Track != lastTrack {
+			flush()
+			lastTrack = segment[0].Track
+		}
+		lines = append(lines, KMLLineString{Coordinates: kmlCoordinates(segment)})
+	}
+	flush()
+
+	if len(placemarks) == 0 {
+		placemarks = append(placemarks, KMLPlacemark{Name: name, LineString: &KMLLineString{}})
 	}
 
 	kml := KML{
-		Placemarks: []KMLPlacemark{
+		Xmlns: "http://www.opengis.net/kml/2.2",
+		Documents: []KMLDocument{
 			{
-				Name: name,
-				LineString: &KMLLineString{
-					Coordinates: strings.Join(coordStrings, " \n "),
-				},
+				Name:       name,
+				Placemarks: placemarks,
 			},
 		},
 	}
@@ -452,12 +513,23 @@ func ToKML(points []model.Point, name string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to marshal KML: %w", err)
 	}
 
-	// Add XML header and KML namespace
+	// Add XML header
 	buf := bytes.Buffer{}
 	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
-	buf.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">`)
 	buf.Write(data)
-	buf.WriteString(`</kml>`)
 
 	return buf.Bytes(), nil
 }
+
+// kmlCoordinates formats points as a KML coordinate list
+func kmlCoordinates(points []model.Point) string {
+	coordStrings := make([]string, len(points))
+	for i, p := range points {
+		if p.Elevation > 0 {
+			coordStrings[i] = fmt.Sprintf("%.8f,%.8f,%.2f", p.Lon, p.Lat, p.Elevation)
+		} else {
+			coordStrings[i] = fmt.Sprintf("%.8f,%.8f", p.Lon, p.Lat)
+		}
+	}
+	return strings.Join(coordStrings, " \n ")
+}
@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+func TestKMLParser_MultiGeometry(t *testing.T) {
+	kmlData := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+  <Document>
+    <Placemark>
+      <MultiGeometry>
+        <LineString><coordinates>116.4074,39.9042 116.4075,39.9043</coordinates></LineString>
+        <LineString><coordinates>116.4174,39.9142</coordinates></LineString>
+      </MultiGeometry>
+    </Placemark>
+    <Placemark>
+      <LineString><coordinates>116.4274,39.9242</coordinates></LineString>
+    </Placemark>
+  </Document>
+</kml>`)
+
+	parser := NewKMLParser()
+	if err := parser.Validate(kmlData); err != nil {
+		t.Fatalf("Expected valid KML, got error: %v", err)
+	}
+
+	points, err := parser.Parse(kmlData)
+	if err != nil {
+		t.Fatalf("Failed to parse KML: %v", err)
+	}
+
+	expected := [][2]int{{0, 0}, {0, 0}, {0, 1}, {1, 0}}
+	if len(points) != len(expected) {
+		t.Fatalf("Expected %d points, got %d", len(expected), len(points))
+	}
+	for i, e := range expected {
+		if points[i].Track != e[0] || points[i].Segment != e[1] {
+			t.Errorf("Point %d: expected track/segment %v, got %d/%d", i, e, points[i].Track, points[i].Segment)
+		}
+	}
+}
+
+func TestToKML_RoundTrip(t *testing.T) {
+	points := []model.Point{
+		{Lat: 39.9042, Lon: 116.4074},
+		{Lat: 39.9043, Lon: 116.4075},
+		{Lat: 39.9142, Lon: 116.4174, Segment: 1},
+		{Lat: 39.9242, Lon: 116.4274, Track: 1},
+	}
+
+	data, err := ToKML(points, "Test")
+	if err != nil {
+		t.Fatalf("Failed to create KML: %v", err)
+	}
+
+	parsed, err := NewKMLParser().Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to re-parse exported KML: %v", err)
+	}
+
+	if len(parsed) != len(points) {
+		t.Fatalf("Expected %d points, got %d", len(points), len(parsed))
+	}
+	for i := range points {
+		if parsed[i].Track != points[i].Track || parsed[i].Segment != points[i].Segment {
+			t.Errorf("Point %d: expected track/segment %d/%d, got %d/%d",
+				i, points[i].Track, points[i].Segment, parsed[i].Track, parsed[i].Segment)
+		}
+	}
+}
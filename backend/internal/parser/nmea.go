@@ -0,0 +1,428 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// NMEAParser handles NMEA 0183 sentence log parsing
+type NMEAParser struct {
+	strictMode bool
+}
+
+// NewNMEAParser creates a new NMEA parser
+func NewNMEAParser() *NMEAParser {
+	return &NMEAParser{
+		strictMode: false,
+	}
+}
+
+// nmeaEpoch collects all sentences belonging to one fix epoch
+type nmeaEpoch struct {
+	tod       time.Duration // Time of day (UTC)
+	dayOffset int           // Midnight rollovers seen before this epoch
+	date      time.Time     // Date from RMC, zero if none seen in this epoch
+
+	hasGGA     bool
+	ggaLat     float64
+	ggaLon     float64
+	elevation  float64
+	quality    int
+	satellites int
+	hdop       float64
+
+	hasRMC   bool
+	rmcValid bool
+	rmcLat   float64
+	rmcLon   float64
+
+	gsaHDOP       float64
+	gsaSatellites int
+	gsvInView     int
+}
+
+// Parse parses NMEA data from bytes
+func (n *NMEAParser) Parse(data []byte) ([]model.Point, error) {
+	return n.ParseReader(bytes.NewReader(data))
+}
+
+// ParseReader parses NMEA data from reader
+func (n *NMEAParser) ParseReader(r io.Reader) ([]model.Point, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1024), 64*1024)
+
+	var epochs []*nmeaEpoch
+	var current *nmeaEpoch
+	var lastTOD time.Duration
+	haveTOD := false
+	dayOffset := 0
+
+	for scanner.Scan() {
+		fields, ok := n.splitSentence(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		kind := sentenceType(fields[0])
+
+		switch kind {
+		case "GGA", "RMC":
+			if len(fields) < 2 {
+				continue
+			}
+			tod, ok := parseNMEATime(fields[1])
+			if !ok {
+				continue
+			}
+
+			// A time of day far behind the previous one means we crossed midnight
+			if haveTOD && tod < lastTOD-12*time.Hour {
+				dayOffset++
+			}
+
+			if current == nil || current.tod != tod || current.dayOffset != dayOffset {
+				current = &nmeaEpoch{tod: tod, dayOffset: dayOffset}
+				epochs = append(epochs, current)
+			}
+			lastTOD = tod
+			haveTOD = true
+
+			if kind == "GGA" {
+				n.applyGGA(current, fields)
+			} else {
+				n.applyRMC(current, fields)
+			}
+		case "GSA":
+			if current != nil {
+				n.applyGSA(current, fields)
+			}
+		case "GSV":
+			if current != nil {
+				n.applyGSV(current, fields)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NMEA: %w", err)
+	}
+
+	points := n.epochsToPoints(epochs)
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no valid fixes found in NMEA log")
+	}
+
+	for i := range points {
+		points[i].Index = i
+	}
+
+	return points, nil
+}
+
+// splitSentence extracts the sentence from a log line, verifies its
+// checksum and splits it into fields
+func (n *NMEAParser) splitSentence(line string) ([]string, bool) {
+	// Loggers often prefix lines with their own timestamps
+	start := strings.IndexByte(line, '$')
+	if start < 0 {
+		return nil, false
+	}
+	sentence := strings.TrimSpace(line[start+1:])
+
+	if star := strings.IndexByte(sentence, '*'); star >= 0 {
+		if len(sentence) < star+3 {
+			return nil, false
+		}
+		want, err := strconv.ParseUint(sentence[star+1:star+3], 16, 8)
+		if err != nil || byte(want) != nmeaChecksum(sentence[:star]) {
+			return nil, false
+		}
+		sentence = sentence[:star]
+	} else if n.strictMode {
+		return nil, false
+	}
+
+	fields := strings.Split(sentence, ",")
+	if len(fields[0]) < 5 {
+		return nil, false
+	}
+	return fields, true
+}
+
+// applyGGA merges a GGA (fix data) sentence into the epoch
+// $--GGA,hhmmss.ss,llll.ll,a,yyyyy.yy,a,q,nn,h.h,a.a,M,g.g,M,...
+func (n *NMEAParser) applyGGA(e *nmeaEpoch, f []string) {
+	if len(f) < 10 {
+		return
+	}
+
+	quality, err := strconv.Atoi(f[6])
+	if err != nil || quality == 0 {
+		return
+	}
+
+	lat, ok1 := parseNMEACoord(f[2], f[3])
+	lon, ok2 := parseNMEACoord(f[4], f[5])
+	if !ok1 || !ok2 {
+		return
+	}
+
+	e.hasGGA = true
+	e.ggaLat = lat
+	e.ggaLon = lon
+	e.quality = quality
+
+	if sats, err := strconv.Atoi(f[7]); err == nil {
+		e.satellites = sats
+	}
+	if hdop, err := strconv.ParseFloat(f[8], 64); err == nil {
+		e.hdop = hdop
+	}
+	if alt, err := strconv.ParseFloat(f[9], 64); err == nil {
+		e.elevation = alt
+	}
+}
+
+// applyRMC merges an RMC (recommended minimum) sentence into the epoch
+// $--RMC,hhmmss.ss,A,llll.ll,a,yyyyy.yy,a,x.x,x.x,ddmmyy,...
+func (n *NMEAParser) applyRMC(e *nmeaEpoch, f []string) {
+	if len(f) < 10 {
+		return
+	}
+
+	e.hasRMC = true
+	if date, ok := parseNMEADate(f[9]); ok {
+		e.date = date
+	}
+
+	if f[2] != "A" {
+		return
+	}
+
+	lat, ok1 := parseNMEACoord(f[3], f[4])
+	lon, ok2 := parseNMEACoord(f[5], f[6])
+	if !ok1 || !ok2 {
+		return
+	}
+
+	e.rmcValid = true
+	e.rmcLat = lat
+	e.rmcLon = lon
+}
+
+// applyGSA merges a GSA (DOP and active satellites) sentence into the epoch
+// $--GSA,a,x,xx,xx,xx,xx,xx,xx,xx,xx,xx,xx,xx,xx,p.p,h.h,v.v
+func (n *NMEAParser) applyGSA(e *nmeaEpoch, f []string) {
+	if len(f) < 17 {
+		return
+	}
+
+	// Multi-constellation receivers emit one GSA per system, so accumulate
+	for _, prn := range f[3:15] {
+		if prn != "" {
+			e.gsaSatellites++
+		}
+	}
+	if hdop, err := strconv.ParseFloat(f[16], 64); err == nil && e.gsaHDOP == 0 {
+		e.gsaHDOP = hdop
+	}
+}
+
+// applyGSV merges a GSV (satellites in view) sentence into the epoch
+// $--GSV,x,x,xx,...
+func (n *NMEAParser) applyGSV(e *nmeaEpoch, f []string) {
+	if len(f) < 4 || f[2] != "1" {
+		return
+	}
+	if inView, err := strconv.Atoi(f[3]); err == nil {
+		e.gsvInView += inView
+	}
+}
+
+// epochsToPoints resolves dates and converts epochs with a valid fix to points
+func (n *NMEAParser) epochsToPoints(epochs []*nmeaEpoch) []model.Point {
+	// Date of day offset zero; RMC sentences re-anchor it as they appear,
+	// epochs before the first RMC borrow the first anchor
+	var anchor time.Time
+	for _, e := range epochs {
+		if !e.date.IsZero() {
+			anchor = e.date.AddDate(0, 0, -e.dayOffset)
+			break
+		}
+	}
+
+	points := make([]model.Point, 0, len(epochs))
+
+	for _, e := range epochs {
+		if !e.date.IsZero() {
+			anchor = e.date.AddDate(0, 0, -e.dayOffset)
+		}
+
+		var point model.Point
+		switch {
+		case e.hasGGA:
+			point = model.Point{
+				Lat:        e.ggaLat,
+				Lon:        e.ggaLon,
+				Elevation:  e.elevation,
+				FixQuality: e.quality,
+				HDOP:       e.hdop,
+				Satellites: e.satellites,
+			}
+		case e.rmcValid:
+			point = model.Point{
+				Lat: e.rmcLat,
+				Lon: e.rmcLon,
+			}
+		default:
+			continue
+		}
+
+		if !isValidLatLon(point.Lat, point.Lon) {
+			continue
+		}
+
+		if point.HDOP == 0 {
+			point.HDOP = e.gsaHDOP
+		}
+		if point.Satellites == 0 {
+			point.Satellites = e.gsaSatellites
+		}
+		if point.Satellites == 0 {
+			point.Satellites = e.gsvInView
+		}
+
+		// Without any RMC the date stays unknown and only time of day is kept
+		point.Time = anchor.AddDate(0, 0, e.dayOffset).Add(e.tod)
+		point.Status = model.StatusNormal
+
+		points = append(points, point)
+	}
+
+	return points
+}
+
+// Validate validates NMEA data with detailed error messages
+func (n *NMEAParser) Validate(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("NMEA file is empty")
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 1024), 64*1024)
+
+	sentences := 0
+	fixes := 0
+	for scanner.Scan() {
+		fields, ok := n.splitSentence(scanner.Text())
+		if !ok {
+			continue
+		}
+		sentences++
+		switch sentenceType(fields[0]) {
+		case "GGA", "RMC":
+			fixes++
+		}
+	}
+
+	if sentences == 0 {
+		return fmt.Errorf("not an NMEA log - no valid NMEA sentences found")
+	}
+	if fixes == 0 {
+		return fmt.Errorf("NMEA log has sentences but no GGA or RMC fixes")
+	}
+
+	return nil
+}
+
+// sentenceType returns the sentence formatter without talker ID ("GPGGA" -> "GGA")
+func sentenceType(address string) string {
+	if len(address) < 3 {
+		return ""
+	}
+	return address[len(address)-3:]
+}
+
+// nmeaChecksum calculates the XOR checksum of the sentence body
+func nmeaChecksum(body string) byte {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return sum
+}
+
+// parseNMEATime parses hhmmss[.sss] into a time of day
+func parseNMEATime(s string) (time.Duration, bool) {
+	if len(s) < 6 {
+		return 0, false
+	}
+
+	hh, err1 := strconv.Atoi(s[0:2])
+	mm, err2 := strconv.Atoi(s[2:4])
+	ss, err3 := strconv.ParseFloat(s[4:], 64)
+	if err1 != nil || err2 != nil || err3 != nil || hh > 23 || mm > 59 || ss >= 61 {
+		return 0, false
+	}
+
+	return time.Duration(hh)*time.Hour +
+		time.Duration(mm)*time.Minute +
+		time.Duration(math.Round(ss*1000))*time.Millisecond, true
+}
+
+// parseNMEADate parses ddmmyy into a UTC date
+func parseNMEADate(s string) (time.Time, bool) {
+	if len(s) != 6 {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse("020106", s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseNMEACoord parses a (d)ddmm.mmmm value and hemisphere into decimal degrees
+func parseNMEACoord(value, hemisphere string) (float64, bool) {
+	dot := strings.IndexByte(value, '.')
+	if dot < 0 {
+		dot = len(value)
+	}
+	if dot < 3 {
+		return 0, false
+	}
+
+	degrees, err1 := strconv.ParseFloat(value[:dot-2], 64)
+	minutes, err2 := strconv.ParseFloat(value[dot-2:], 64)
+	if err1 != nil || err2 != nil || minutes >= 60 {
+		return 0, false
+	}
+
+	coord := degrees + minutes/60
+	switch hemisphere {
+	case "N", "E":
+	case "S", "W":
+		coord = -coord
+	default:
+		return 0, false
+	}
+
+	return coord, true
+}
+
+// isValidLatLon checks if coordinates are within valid ranges
+func isValidLatLon(lat, lon float64) bool {
+	if math.IsNaN(lat) || math.IsInf(lat, 0) || math.IsNaN(lon) || math.IsInf(lon, 0) {
+		return false
+	}
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNMEAParser_Parse(t *testing.T) {
+	parser := NewNMEAParser()
+
+	data, err := os.ReadFile("../../testdata/sample.nmea")
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	points, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse NMEA: %v", err)
+	}
+
+	// Six fix epochs; the corrupted sentence and the no-fix epoch are dropped
+	if len(points) != 6 {
+		t.Fatalf("Expected 6 points, got %d", len(points))
+	}
+
+	first := points[0]
+	if first.FixQuality != 1 || first.Satellites != 8 || first.HDOP != 0.9 {
+		t.Errorf("Expected quality 1, 8 sats, HDOP 0.9, got %d, %d, %f",
+			first.FixQuality, first.Satellites, first.HDOP)
+	}
+	if first.Elevation != 50 {
+		t.Errorf("Expected elevation 50, got %f", first.Elevation)
+	}
+
+	wantLat := 39.0 + 54.252/60
+	if diff := first.Lat - wantLat; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected lat %f, got %f", wantLat, first.Lat)
+	}
+
+	// Timestamps must stay monotonic across midnight
+	want := time.Date(2024, 12, 31, 23, 59, 57, 0, time.UTC)
+	for i, p := range points {
+		if !p.Time.Equal(want) {
+			t.Errorf("Point %d: expected time %s, got %s", i, want, p.Time)
+		}
+		if p.Index != i {
+			t.Errorf("Point %d: expected index %d, got %d", i, i, p.Index)
+		}
+		want = want.Add(time.Second)
+	}
+}
+
+func TestNMEAParser_RolloverBeforeFirstRMC(t *testing.T) {
+	parser := NewNMEAParser()
+
+	data := []byte(
+		"$GPGGA,235959.00,3954.2520,N,11624.4440,E,1,08,0.9,50.0,M,-8.0,M,,*46\n" +
+			"$GPGGA,000000.00,3954.2620,N,11624.4540,E,2,09,0.8,51.0,M,-8.0,M,,*47\n" +
+			"$GPRMC,000000.00,A,3954.2620,N,11624.4540,E,12.5,45.0,010125,,,A*56\n")
+
+	points, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse NMEA: %v", err)
+	}
+
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(points))
+	}
+
+	want := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)
+	if !points[0].Time.Equal(want) {
+		t.Errorf("Expected first fix on previous day %s, got %s", want, points[0].Time)
+	}
+	if points[1].FixQuality != 2 {
+		t.Errorf("Expected DGPS fix quality 2, got %d", points[1].FixQuality)
+	}
+}
+
+func TestNMEAParser_Validate(t *testing.T) {
+	parser := NewNMEAParser()
+
+	if err := parser.Validate([]byte("")); err == nil {
+		t.Error("Expected error for empty data")
+	}
+
+	if err := parser.Validate([]byte("not an nmea log")); err == nil {
+		t.Error("Expected error for data without sentences")
+	}
+
+	onlyGSV := []byte("$GPGSV,2,1,08,01,40,083,46,03,12,135,38,07,56,249,45,08,23,312,42*77\n")
+	if err := parser.Validate(onlyGSV); err == nil {
+		t.Error("Expected error for log without fixes")
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"io"
 	"path/filepath"
 	"strings"
@@ -16,24 +17,46 @@ type Parser interface {
 }
 
 // ParserFactory creates appropriate parser for file type
-type ParserFactory struct{}
+type ParserFactory struct {
+	csvMapping *CSVMapping
+}
 
 // NewParserFactory creates a new parser factory
 func NewParserFactory() *ParserFactory {
 	return &ParserFactory{}
 }
 
+// NewParserFactoryWithCSVMapping creates a parser factory whose CSV parsers
+// use the given column mapping. Content that matches no format is read as CSV.
+func NewParserFactoryWithCSVMapping(mapping CSVMapping) *ParserFactory {
+	return &ParserFactory{csvMapping: &mapping}
+}
+
 // CreateParser creates parser based on file extension
 func (f *ParserFactory) CreateParser(filename string) (Parser, error) {
-	ext := strings.ToLower(filepath.Ext(filename))
+	return f.CreateParserByType(FormatFromExtension(filename))
+}
 
-	switch ext {
+// FormatFromExtension maps a file extension to a parser type, returning ""
+// for unknown extensions
+func FormatFromExtension(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
 	case ".gpx":
-		return NewGPXParser(), nil
+		return "gpx"
 	case ".kml":
-		return NewKMLParser(), nil
+		return "kml"
+	case ".nmea", ".log":
+		return "nmea"
+	case ".fit":
+		return "fit"
+	case ".tcx":
+		return "tcx"
+	case ".csv", ".tsv":
+		return "csv"
+	case ".geojson":
+		return "geojson"
 	default:
-		return nil, ErrUnsupportedFormat
+		return ""
 	}
 }
 
@@ -44,14 +67,98 @@ func (f *ParserFactory) CreateParserByType(fileType string) (Parser, error) {
 		return NewGPXParser(), nil
 	case "kml":
 		return NewKMLParser(), nil
+	case "nmea":
+		return NewNMEAParser(), nil
+	case "fit":
+		return NewFITParser(), nil
+	case "tcx":
+		return NewTCXParser(), nil
+	case "csv", "tsv":
+		if f.csvMapping != nil {
+			return NewCSVParserWithMapping(*f.csvMapping), nil
+		}
+		return NewCSVParser(), nil
+	case "geojson":
+		return NewGeoJSONParser(), nil
 	default:
 		return nil, ErrUnsupportedFormat
 	}
 }
 
-// ParseFile parses file by detecting type from extension
+// ResolveFormat determines the parser type from content, falling back to
+// the file extension when sniffing is inconclusive
+func (f *ParserFactory) ResolveFormat(filename string, data []byte) (string, error) {
+	detection := Sniff(data)
+	weakNMEA := detection.Format == "nmea" && detection.Confidence < strongNMEAConfidence
+	if weakNMEA && FormatFromExtension(filename) == "csv" {
+		return "csv", nil
+	}
+	if detection.Confidence >= MinDetectionConfidence {
+		if _, err := f.CreateParserByType(detection.Format); err != nil {
+			return detection.Format, err
+		}
+		return detection.Format, nil
+	}
+
+	format := FormatFromExtension(filename)
+	if _, err := f.CreateParserByType(format); err != nil {
+		// An explicit column mapping marks unrecognised text as CSV
+		if f.csvMapping != nil && detection.Format == "unknown" {
+			return "csv", nil
+		}
+		return strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), "."), err
+	}
+	return format, nil
+}
+
+// ParseFile parses file by detecting type from content and extension.
+// Gzip-compressed files and archives holding a single track are unwrapped.
 func (f *ParserFactory) ParseFile(filename string, data []byte) ([]model.Point, error) {
-	parser, err := f.CreateParser(filename)
+	files, err := f.ParseArchive(filename, data, DefaultMaxDecompressedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) > 1 {
+		return nil, fmt.Errorf("archive contains %d track files (hint: use ParseArchive)", len(files))
+	}
+
+	return files[0].Points, files[0].Err
+}
+
+// ParsedFile is the result of parsing one track file from an upload
+type ParsedFile struct {
+	Name   string
+	Format string
+	Points []model.Point
+	Err    error
+}
+
+// ParseArchive unwraps gzip and zip uploads and parses every track file in
+// them. Archive-level failures are returned as an error; failures of single
+// tracks are reported in ParsedFile.Err.
+func (f *ParserFactory) ParseArchive(filename string, data []byte, limit int64) ([]ParsedFile, error) {
+	entries, err := Unpack(filename, data, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]ParsedFile, len(entries))
+	for i, entry := range entries {
+		files[i].Name = entry.Name
+		files[i].Format, files[i].Err = f.ResolveFormat(entry.Name, entry.Data)
+		if files[i].Err != nil {
+			continue
+		}
+		files[i].Points, files[i].Err = f.ParseAs(files[i].Format, entry.Data)
+	}
+
+	return files, nil
+}
+
+// ParseAs validates and parses data with the parser for the given type
+func (f *ParserFactory) ParseAs(format string, data []byte) ([]model.Point, error) {
+	parser, err := f.CreateParserByType(format)
 	if err != nil {
 		return nil, err
 	}
@@ -63,31 +170,30 @@ func (f *ParserFactory) ParseFile(filename string, data []byte) ([]model.Point,
 	return parser.Parse(data)
 }
 
-// DetectFormat detects file format from content
+// DetectFormat detects file format from content, returning "unknown" when
+// no format matches
 func DetectFormat(data []byte) string {
-	// Check for GPX
-	if len(data) > 100 {
-		content := strings.ToLower(string(data[:100]))
-		if strings.Contains(content, "<gpx") {
-			return "gpx"
-		}
-		if strings.Contains(content, "<kml") {
-			return "kml"
-		}
-	}
-	return "unknown"
+	return Sniff(data).Format
 }
 
-// CalculateSpeeds calculates speeds for points
+// CalculateSpeeds calculates speeds for points; the first point of each
+// segment has no speed
 func CalculateSpeeds(points []model.Point) {
 	for i := 1; i < len(points); i++ {
+		if !model.SameSegment(points[i-1], points[i]) {
+			continue
+		}
 		points[i].Speed = model.CalculateSpeed(points[i-1], points[i])
 	}
 }
 
-// CalculateBearings calculates bearings for points
+// CalculateBearings calculates bearings for points; the first point of each
+// segment has no bearing
 func CalculateBearings(points []model.Point) {
 	for i := 1; i < len(points); i++ {
+		if !model.SameSegment(points[i-1], points[i]) {
+			continue
+		}
 		points[i].Bearing = model.CalculateBearing(
 			points[i-1].Lat, points[i-1].Lon,
 			points[i].Lat, points[i].Lon,
@@ -104,7 +210,7 @@ func PostProcess(points []model.Point) []model.Point {
 
 // SupportedFormats returns list of supported formats
 func SupportedFormats() []string {
-	return []string{"gpx", "kml"}
+	return []string{"gpx", "kml", "nmea", "fit", "tcx", "csv", "geojson"}
 }
 
 // Errors
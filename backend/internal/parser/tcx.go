@@ -0,0 +1,294 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// TCXParser handles Training Center XML file parsing
+type TCXParser struct {
+	strictMode bool
+}
+
+// NewTCXParser creates a new TCX parser
+func NewTCXParser() *TCXParser {
+	return &TCXParser{
+		strictMode: false,
+	}
+}
+
+// Parse parses TCX data from bytes
+func (t *TCXParser) Parse(data []byte) ([]model.Point, error) {
+	tcx := &TCX{}
+	err := xml.Unmarshal(data, tcx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TCX: %w", err)
+	}
+
+	return t.ExtractPoints(tcx)
+}
+
+// ParseReader parses TCX data from reader
+func (t *TCXParser) ParseReader(r io.Reader) ([]model.Point, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TCX: %w", err)
+	}
+	return t.Parse(data)
+}
+
+// ExtractPoints extracts points from TCX structure, numbering laps
+// consecutively across all activities
+func (t *TCXParser) ExtractPoints(tcx *TCX) ([]model.Point, error) {
+	var allPoints []model.Point
+	lapNumber := 0
+
+	for _, activity := range tcx.Activities {
+		for _, lap := range activity.Laps {
+			points := t.extractLapPoints(lap, lapNumber)
+			if len(points) == 0 {
+				continue
+			}
+			allPoints = append(allPoints, points...)
+			lapNumber++
+		}
+	}
+
+	if len(allPoints) == 0 {
+		return nil, fmt.Errorf("no points found in TCX file")
+	}
+
+	for i := range allPoints {
+		allPoints[i].Index = i
+	}
+
+	return allPoints, nil
+}
+
+// extractLapPoints extracts points with a position from all tracks of a lap
+func (t *TCXParser) extractLapPoints(lap TCXLap, lapNumber int) []model.Point {
+	var points []model.Point
+
+	for _, track := range lap.Tracks {
+		for _, tp := range track.Trackpoints {
+			// Trackpoints without a position only carry sensor data
+			if tp.Position == nil || !isValidLatLon(tp.Position.Lat, tp.Position.Lon) {
+				continue
+			}
+
+			point := model.Point{
+				Lat:       tp.Position.Lat,
+				Lon:       tp.Position.Lon,
+				Elevation: tp.Altitude,
+				Distance:  tp.Distance,
+				Cadence:   tp.Cadence,
+				Lap:       lapNumber,
+				Status:    model.StatusNormal,
+			}
+
+			if tp.HeartRate != nil {
+				point.HeartRate = tp.HeartRate.Value
+			}
+
+			if ts, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(tp.Time)); err == nil {
+				point.Time = ts
+			}
+
+			points = append(points, point)
+		}
+	}
+
+	return points
+}
+
+// Validate validates TCX data with detailed error messages
+func (t *TCXParser) Validate(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("TCX file is empty")
+	}
+
+	if len(data) < 50 {
+		return fmt.Errorf("file too small to be a valid TCX")
+	}
+
+	content := strings.ToLower(string(data))
+	if !strings.Contains(content, "<trainingcenterdatabase") {
+		return fmt.Errorf("not a TCX file - missing TrainingCenterDatabase root element")
+	}
+
+	tcx := &TCX{}
+	if err := xml.Unmarshal(data, tcx); err != nil {
+		return fmt.Errorf("invalid TCX format: %w (hint: check if file is valid XML)", err)
+	}
+
+	lapCount := 0
+	positionCount := 0
+	for _, activity := range tcx.Activities {
+		lapCount += len(activity.Laps)
+		for _, lap := range activity.Laps {
+			for _, track := range lap.Tracks {
+				for _, tp := range track.Trackpoints {
+					if tp.Position != nil {
+						positionCount++
+					}
+				}
+			}
+		}
+	}
+
+	if lapCount == 0 {
+		return fmt.Errorf("TCX file contains no tracks or laps")
+	}
+
+	if positionCount == 0 {
+		return fmt.Errorf("TCX file has structure but no track points with position")
+	}
+
+	return nil
+}
+
+// TCX represents the root TrainingCenterDatabase element
+type TCX struct {
+	XMLName    xml.Name      `xml:"TrainingCenterDatabase"`
+	Xmlns      string        `xml:"xmlns,attr,omitempty"`
+	Activities []TCXActivity `xml:"Activities>Activity"`
+}
+
+// TCXActivity represents an activity
+type TCXActivity struct {
+	Sport string   `xml:"Sport,attr"`
+	ID    string   `xml:"Id"`
+	Laps  []TCXLap `xml:"Lap"`
+	Notes string   `xml:"Notes,omitempty"`
+}
+
+// TCXLap represents a lap
+type TCXLap struct {
+	StartTime        string     `xml:"StartTime,attr"`
+	TotalTimeSeconds float64    `xml:"TotalTimeSeconds"`
+	DistanceMeters   float64    `xml:"DistanceMeters"`
+	Calories         int        `xml:"Calories"`
+	Intensity        string     `xml:"Intensity"`
+	TriggerMethod    string     `xml:"TriggerMethod"`
+	Tracks           []TCXTrack `xml:"Track"`
+}
+
+// TCXTrack represents a track within a lap
+type TCXTrack struct {
+	Trackpoints []TCXTrackpoint `xml:"Trackpoint"`
+}
+
+// TCXTrackpoint represents a track point
+type TCXTrackpoint struct {
+	Time      string        `xml:"Time,omitempty"`
+	Position  *TCXPosition  `xml:"Position,omitempty"`
+	Altitude  float64       `xml:"AltitudeMeters,omitempty"`
+	Distance  float64       `xml:"DistanceMeters,omitempty"`
+	HeartRate *TCXHeartRate `xml:"HeartRateBpm,omitempty"`
+	Cadence   int           `xml:"Cadence,omitempty"`
+}
+
+// TCXPosition represents a position
+type TCXPosition struct {
+	Lat float64 `xml:"LatitudeDegrees"`
+	Lon float64 `xml:"LongitudeDegrees"`
+}
+
+// TCXHeartRate represents a heart rate value
+type TCXHeartRate struct {
+	Value int `xml:"Value"`
+}
+
+// ToTCX converts points to TCX format, starting a new lap whenever the
+// lap number changes
+func ToTCX(points []model.Point, name string) ([]byte, error) {
+	activity := TCXActivity{
+		Sport: "Other",
+		Laps:  makeTCXLaps(points),
+		Notes: name,
+	}
+	if len(points) > 0 && !points[0].Time.IsZero() {
+		activity.ID = points[0].Time.UTC().Format(time.RFC3339)
+	}
+
+	tcx := TCX{
+		Xmlns:      "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2",
+		Activities: []TCXActivity{activity},
+	}
+
+	data, err := xml.MarshalIndent(tcx, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal TCX: %w", err)
+	}
+
+	// Add XML header
+	buf := bytes.Buffer{}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	buf.Write(data)
+
+	return buf.Bytes(), nil
+}
+
+// makeTCXLaps groups consecutive points with the same lap number into laps
+func makeTCXLaps(points []model.Point) []TCXLap {
+	var laps []TCXLap
+	distance := 0.0
+
+	for start := 0; start < len(points); {
+		end := start
+		for end < len(points) && points[end].Lap == points[start].Lap {
+			end++
+		}
+
+		lapStartDistance := distance
+		trackpoints := make([]TCXTrackpoint, 0, end-start)
+		for i := start; i < end; i++ {
+			p := points[i]
+
+			// Distance is recomputed because corrections move points
+			if i > 0 {
+				distance += model.HaversineDistance(points[i-1].Lat, points[i-1].Lon, p.Lat, p.Lon)
+			}
+
+			tp := TCXTrackpoint{
+				Position: &TCXPosition{Lat: p.Lat, Lon: p.Lon},
+				Altitude: p.Elevation,
+				Distance: distance,
+				Cadence:  p.Cadence,
+			}
+			if !p.Time.IsZero() {
+				tp.Time = p.Time.UTC().Format(time.RFC3339)
+			}
+			if p.HeartRate > 0 {
+				tp.HeartRate = &TCXHeartRate{Value: p.HeartRate}
+			}
+
+			trackpoints = append(trackpoints, tp)
+		}
+
+		lap := TCXLap{
+			DistanceMeters: distance - lapStartDistance,
+			Intensity:      "Active",
+			TriggerMethod:  "Manual",
+			Tracks:         []TCXTrack{{Trackpoints: trackpoints}},
+		}
+		first, last := points[start].Time, points[end-1].Time
+		if !first.IsZero() {
+			lap.StartTime = first.UTC().Format(time.RFC3339)
+			if !last.IsZero() {
+				lap.TotalTimeSeconds = last.Sub(first).Seconds()
+			}
+		}
+
+		laps = append(laps, lap)
+		start = end
+	}
+
+	return laps
+}
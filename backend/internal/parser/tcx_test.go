@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+const testTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities>
+    <Activity Sport="Running">
+      <Id>2024-01-01T08:00:00Z</Id>
+      <Lap StartTime="2024-01-01T08:00:00Z">
+        <TotalTimeSeconds>10</TotalTimeSeconds>
+        <DistanceMeters>140</DistanceMeters>
+        <Track>
+          <Trackpoint>
+            <Time>2024-01-01T08:00:00Z</Time>
+            <Position><LatitudeDegrees>39.9042</LatitudeDegrees><LongitudeDegrees>116.4074</LongitudeDegrees></Position>
+            <AltitudeMeters>50.5</AltitudeMeters>
+            <DistanceMeters>0</DistanceMeters>
+            <HeartRateBpm><Value>120</Value></HeartRateBpm>
+            <Cadence>85</Cadence>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-01-01T08:00:05Z</Time>
+            <HeartRateBpm><Value>121</Value></HeartRateBpm>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-01-01T08:00:10Z</Time>
+            <Position><LatitudeDegrees>39.9052</LatitudeDegrees><LongitudeDegrees>116.4084</LongitudeDegrees></Position>
+            <DistanceMeters>140.2</DistanceMeters>
+          </Trackpoint>
+        </Track>
+      </Lap>
+      <Lap StartTime="2024-01-01T08:00:20Z">
+        <TotalTimeSeconds>10</TotalTimeSeconds>
+        <DistanceMeters>140</DistanceMeters>
+        <Track>
+          <Trackpoint>
+            <Time>2024-01-01T08:00:20Z</Time>
+            <Position><LatitudeDegrees>39.9062</LatitudeDegrees><LongitudeDegrees>116.4094</LongitudeDegrees></Position>
+            <DistanceMeters>280.4</DistanceMeters>
+          </Trackpoint>
+        </Track>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`
+
+func TestTCXParser_Parse(t *testing.T) {
+	parser := NewTCXParser()
+
+	points, err := parser.Parse([]byte(testTCX))
+	if err != nil {
+		t.Fatalf("Failed to parse TCX: %v", err)
+	}
+
+	// The trackpoint without a position is skipped
+	if len(points) != 3 {
+		t.Fatalf("Expected 3 points, got %d", len(points))
+	}
+
+	if points[0].HeartRate != 120 || points[0].Cadence != 85 || points[0].Elevation != 50.5 {
+		t.Errorf("Expected hr 120, cadence 85, elevation 50.5, got %+v", points[0])
+	}
+	if points[1].Distance != 140.2 {
+		t.Errorf("Expected distance 140.2, got %f", points[1].Distance)
+	}
+
+	wantLaps := []int{0, 0, 1}
+	for i, p := range points {
+		if p.Lap != wantLaps[i] {
+			t.Errorf("Point %d: expected lap %d, got %d", i, wantLaps[i], p.Lap)
+		}
+	}
+}
+
+func TestTCXParser_Validate(t *testing.T) {
+	parser := NewTCXParser()
+
+	if err := parser.Validate([]byte(testTCX)); err != nil {
+		t.Errorf("Expected valid TCX, got error: %v", err)
+	}
+
+	if err := parser.Validate([]byte(`<?xml version="1.0"?><gpx version="1.1"><trk></trk></gpx>`)); err == nil {
+		t.Error("Expected error for non-TCX data")
+	}
+}
+
+func TestToTCX_PreservesLaps(t *testing.T) {
+	parser := NewTCXParser()
+
+	points, err := parser.Parse([]byte(testTCX))
+	if err != nil {
+		t.Fatalf("Failed to parse TCX: %v", err)
+	}
+
+	data, err := ToTCX(points, "Test")
+	if err != nil {
+		t.Fatalf("Failed to create TCX: %v", err)
+	}
+
+	if DetectFormat(data) != "tcx" {
+		t.Errorf("Expected exported data to be detected as tcx")
+	}
+	if strings.Count(string(data), "<Lap ") != 2 {
+		t.Errorf("Expected 2 laps in output:\n%s", data)
+	}
+
+	reparsed, err := parser.Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse exported TCX: %v", err)
+	}
+	if len(reparsed) != len(points) {
+		t.Fatalf("Expected %d points, got %d", len(points), len(reparsed))
+	}
+	for i := range points {
+		if reparsed[i].Lap != points[i].Lap || reparsed[i].HeartRate != points[i].HeartRate {
+			t.Errorf("Point %d: lap/hr changed from %d/%d to %d/%d", i,
+				points[i].Lap, points[i].HeartRate, reparsed[i].Lap, reparsed[i].HeartRate)
+		}
+	}
+}
+
+func TestToTCX_OmitsMissingTime(t *testing.T) {
+	points := []model.Point{
+		{Lat: 39.9, Lon: 116.4},
+		{Lat: 39.9001, Lon: 116.4},
+	}
+
+	data, err := ToTCX(points, "Test")
+	if err != nil {
+		t.Fatalf("Failed to create TCX: %v", err)
+	}
+
+	if strings.Contains(string(data), "<Time>") || strings.Contains(string(data), "<Time/>") {
+		t.Errorf("Expected no Time element for untimed points:\n%s", data)
+	}
+}
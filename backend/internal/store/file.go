@@ -0,0 +1,183 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// sweepInterval is how often Save removes expired report files
+const sweepInterval = time.Minute
+
+// reportExt is the extension of report files
+const reportExt = ".json"
+
+// FileStore keeps each report as a JSON file in a directory, so reports
+// survive restarts. Files older than the TTL are treated as missing and
+// removed.
+type FileStore struct {
+	dir       string
+	ttl       time.Duration
+	mu        sync.Mutex // Serializes sweeps
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewFileStore creates a file store in dir with DefaultTTL, creating the
+// directory when needed
+func NewFileStore(dir string) (*FileStore, error) {
+	return NewFileStoreWithTTL(dir, DefaultTTL)
+}
+
+// NewFileStoreWithTTL creates a file store in dir keeping reports for ttl
+func NewFileStoreWithTTL(dir string, ttl time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+	return &FileStore{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+// Save writes a report to its file, atomically replacing an older version
+func (s *FileStore) Save(report *model.Data) error {
+	path, ok := s.path(report.ReportID)
+	if !ok {
+		return fmt.Errorf("invalid report ID %q", report.ReportID)
+	}
+	s.sweep()
+
+	tmp, err := os.CreateTemp(s.dir, ".report-*")
+	if err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(report); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	return nil
+}
+
+// Load reads a report from its file
+func (s *FileStore) Load(id string) (*model.Data, error) {
+	path, ok := s.path(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if s.expired(path) {
+		os.Remove(path)
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report: %w", err)
+	}
+
+	var report model.Data
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to load report: %w", err)
+	}
+	return &report, nil
+}
+
+// Delete removes a report's file
+func (s *FileStore) Delete(id string) error {
+	path, ok := s.path(id)
+	if !ok {
+		return ErrNotFound
+	}
+	expired := s.expired(path)
+
+	err := os.Remove(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist), err == nil && expired:
+		return ErrNotFound
+	case err != nil:
+		return fmt.Errorf("failed to delete report: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of report files that have not expired
+func (s *FileStore) Len() int {
+	count := 0
+	s.walk(func(path string, expired bool) {
+		if !expired {
+			count++
+		}
+	})
+	return count
+}
+
+// path returns the file of a report. Only UUIDs are accepted so IDs cannot
+// name files outside the directory.
+func (s *FileStore) path(id string) (string, bool) {
+	parsed, err := uuid.Parse(id)
+	if err != nil || parsed.String() != strings.ToLower(id) {
+		return "", false
+	}
+	return filepath.Join(s.dir, parsed.String()+reportExt), true
+}
+
+// expired reports whether a report file is older than the TTL. Missing files
+// are not expired.
+func (s *FileStore) expired(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && s.now().Sub(info.ModTime()) >= s.ttl
+}
+
+// sweep removes expired report files, at most once per sweepInterval
+func (s *FileStore) sweep() {
+	s.mu.Lock()
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	s.walk(func(path string, expired bool) {
+		if expired {
+			os.Remove(path)
+		}
+	})
+}
+
+// walk calls fn for every report file in the directory
+func (s *FileStore) walk(fn func(path string, expired bool)) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	now := s.now()
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), reportExt) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		fn(filepath.Join(s.dir, e.Name()), now.Sub(info.ModTime()) >= s.ttl)
+	}
+}
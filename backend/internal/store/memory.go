@@ -0,0 +1,130 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// MemoryStore keeps reports in memory. It evicts the least recently used
+// report once full and forgets reports older than its TTL.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // Most recently used first
+	entries  map[string]*list.Element
+	now      func() time.Time
+}
+
+// memoryEntry is a stored report and its expiry time
+type memoryEntry struct {
+	report  model.Data
+	expires time.Time
+}
+
+// NewMemoryStore creates a memory store with DefaultCapacity and DefaultTTL
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithLimits(DefaultCapacity, DefaultTTL)
+}
+
+// NewMemoryStoreWithLimits creates a memory store holding at most capacity
+// reports, each for at most ttl
+func NewMemoryStoreWithLimits(capacity int, ttl time.Duration) *MemoryStore {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemoryStore{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Save stores a report as the most recently used one
+func (s *MemoryStore) Save(report *model.Data) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	entry := &memoryEntry{report: *report, expires: now.Add(s.ttl)}
+	if e, ok := s.entries[report.ReportID]; ok {
+		e.Value = entry
+		s.order.MoveToFront(e)
+		return nil
+	}
+
+	s.entries[report.ReportID] = s.order.PushFront(entry)
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Load returns a report and marks it as recently used
+func (s *MemoryStore) Load(id string) (*model.Data, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	entry := e.Value.(*memoryEntry)
+	if !s.now().Before(entry.expires) {
+		s.remove(e)
+		return nil, ErrNotFound
+	}
+
+	s.order.MoveToFront(e)
+	report := entry.report
+	return &report, nil
+}
+
+// Delete removes a report
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	expired := !s.now().Before(e.Value.(*memoryEntry).expires)
+	s.remove(e)
+	if expired {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Len returns the number of reports that have not expired
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(s.now())
+	return s.order.Len()
+}
+
+// prune drops expired reports. The caller holds s.mu.
+func (s *MemoryStore) prune(now time.Time) {
+	for e := s.order.Back(); e != nil; {
+		prev := e.Prev()
+		if !now.Before(e.Value.(*memoryEntry).expires) {
+			s.remove(e)
+		}
+		e = prev
+	}
+}
+
+// remove drops one entry. The caller holds s.mu.
+func (s *MemoryStore) remove(e *list.Element) {
+	s.order.Remove(e)
+	delete(s.entries, e.Value.(*memoryEntry).report.ReportID)
+}
@@ -0,0 +1,34 @@
+// Package store keeps diagnosis reports so clients can reopen and export
+// them after the diagnosis request has finished.
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Defaults for report retention
+const (
+	DefaultTTL      = 24 * time.Hour
+	DefaultCapacity = 100 // Reports kept in memory
+)
+
+// ErrNotFound is returned for reports that were never saved, were deleted
+// or have expired
+var ErrNotFound = errors.New("report not found")
+
+// ReportStore keeps diagnosis reports by ReportID. Implementations are safe
+// for concurrent use. Saved reports, including their points, must not be
+// modified afterwards.
+type ReportStore interface {
+	// Save stores a report, replacing any report with the same ID
+	Save(report *model.Data) error
+	// Load returns a copy of a report, or ErrNotFound
+	Load(id string) (*model.Data, error)
+	// Delete removes a report, or returns ErrNotFound
+	Delete(id string) error
+	// Len returns the number of reports that have not expired
+	Len() int
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// testReport returns a report with a new ID and one point
+func testReport() *model.Data {
+	return &model.Data{
+		ReportID: uuid.New().String(),
+		Diagnostics: model.DiagnosticsInfo{
+			HealthScore: model.HealthScore{Total: 87},
+		},
+		Points: []model.Point{{Lat: 39.9042, Lon: 116.4074}},
+	}
+}
+
+// testStores returns one store of each kind with a controllable clock
+func testStores(t *testing.T, ttl time.Duration, clock *time.Time) map[string]ReportStore {
+	t.Helper()
+	memory := NewMemoryStoreWithLimits(10, ttl)
+	memory.now = func() time.Time { return *clock }
+	file, err := NewFileStoreWithTTL(t.TempDir(), ttl)
+	if err != nil {
+		t.Fatalf("NewFileStoreWithTTL() error = %v", err)
+	}
+	file.now = func() time.Time { return *clock }
+	return map[string]ReportStore{"memory": memory, "file": file}
+}
+
+func TestReportStore_SaveLoadDelete(t *testing.T) {
+	clock := time.Now()
+	for name, s := range testStores(t, time.Hour, &clock) {
+		report := testReport()
+		if err := s.Save(report); err != nil {
+			t.Fatalf("%s: Save() error = %v", name, err)
+		}
+
+		got, err := s.Load(report.ReportID)
+		if err != nil {
+			t.Fatalf("%s: Load() error = %v", name, err)
+		}
+		if got.Diagnostics.HealthScore.Total != 87 || len(got.Points) != 1 {
+			t.Errorf("%s: Load() = %+v, want the whole report", name, got)
+		}
+
+		// Loaded reports are copies
+		got.Points = nil
+		if again, _ := s.Load(report.ReportID); len(again.Points) != 1 {
+			t.Errorf("%s: changing a loaded report changed the store", name)
+		}
+
+		if s.Len() != 1 {
+			t.Errorf("%s: Len() = %d, want 1", name, s.Len())
+		}
+		if err := s.Delete(report.ReportID); err != nil {
+			t.Errorf("%s: Delete() error = %v", name, err)
+		}
+		if _, err := s.Load(report.ReportID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Load() after Delete error = %v, want ErrNotFound", name, err)
+		}
+		if err := s.Delete(report.ReportID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: second Delete() error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestReportStore_TTL(t *testing.T) {
+	clock := time.Now()
+	for name, s := range testStores(t, time.Hour, &clock) {
+		report := testReport()
+		s.Save(report)
+
+		clock = clock.Add(2 * time.Hour)
+		if _, err := s.Load(report.ReportID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Load() of an expired report error = %v, want ErrNotFound", name, err)
+		}
+		if s.Len() != 0 {
+			t.Errorf("%s: Len() = %d, want 0 after expiry", name, s.Len())
+		}
+	}
+}
+
+func TestMemoryStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewMemoryStoreWithLimits(2, time.Hour)
+	a, b, c := testReport(), testReport(), testReport()
+	s.Save(a)
+	s.Save(b)
+	s.Load(a.ReportID) // b is now the least recently used
+	s.Save(c)
+
+	if _, err := s.Load(b.ReportID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("least recently used report should be evicted")
+	}
+	for _, r := range []*model.Data{a, c} {
+		if _, err := s.Load(r.ReportID); err != nil {
+			t.Errorf("Load(%s) error = %v", r.ReportID, err)
+		}
+	}
+}
+
+func TestFileStore_Persists(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewFileStore(dir)
+	report := testReport()
+	if err := s.Save(report); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A new store on the same directory, as after a restart
+	reopened, _ := NewFileStore(dir)
+	if got, err := reopened.Load(report.ReportID); err != nil || got.ReportID != report.ReportID {
+		t.Errorf("Load() = %v, %v, want the saved report", got, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != report.ReportID+".json" {
+		t.Errorf("directory holds %v, want one report file", entries)
+	}
+}
+
+func TestFileStore_RejectsPaths(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewFileStore(filepath.Join(dir, "reports"))
+
+	if err := s.Save(&model.Data{ReportID: "../escape"}); err == nil {
+		t.Errorf("Save() should reject IDs that are not UUIDs")
+	}
+	if _, err := s.Load("../escape"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() error = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.json")); err == nil {
+		t.Errorf("report written outside the store directory")
+	}
+}
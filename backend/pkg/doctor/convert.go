@@ -0,0 +1,301 @@
+package doctor
+
+import (
+	"context"
+
+	"github.com/positiondoctor/backend/internal/algorithm"
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// Conversions between the public types and the internal model. Every field
+// is copied by name, so a field added internally stays internal until it is
+// added here too.
+
+func toModelPoints(points []Point) []model.Point {
+	if points == nil {
+		return nil
+	}
+	out := make([]model.Point, len(points))
+	for i, p := range points {
+		out[i] = model.Point{
+			Index:          p.Index,
+			Lat:            p.Lat,
+			Lon:            p.Lon,
+			Time:           p.Time,
+			Elevation:      p.Elevation,
+			Status:         model.PointStatus(p.Status),
+			Flags:          toModelStatuses(p.Flags),
+			IsInterpolated: p.IsInterpolated,
+			OriginalLat:    p.OriginalLat,
+			OriginalLon:    p.OriginalLon,
+			Speed:          p.Speed,
+			Bearing:        p.Bearing,
+			Acceleration:   p.Acceleration,
+			FixedBy:        p.FixedBy,
+			FixQuality:     p.FixQuality,
+			FixType:        p.FixType,
+			HDOP:           p.HDOP,
+			VDOP:           p.VDOP,
+			PDOP:           p.PDOP,
+			Satellites:     p.Satellites,
+			Accuracy:       p.Accuracy,
+			Course:         p.Course,
+			HeartRate:      p.HeartRate,
+			Cadence:        p.Cadence,
+			Temperature:    p.Temperature,
+			Distance:       p.Distance,
+			Lap:            p.Lap,
+			Track:          p.Track,
+			Segment:        p.Segment,
+		}
+	}
+	return out
+}
+
+func fromModelPoints(points []model.Point) []Point {
+	if points == nil {
+		return nil
+	}
+	out := make([]Point, len(points))
+	for i, p := range points {
+		out[i] = Point{
+			Index:          p.Index,
+			Lat:            p.Lat,
+			Lon:            p.Lon,
+			Time:           p.Time,
+			Elevation:      p.Elevation,
+			Status:         PointStatus(p.Status),
+			Flags:          fromModelStatuses(p.Flags),
+			IsInterpolated: p.IsInterpolated,
+			OriginalLat:    p.OriginalLat,
+			OriginalLon:    p.OriginalLon,
+			Speed:          p.Speed,
+			Bearing:        p.Bearing,
+			Acceleration:   p.Acceleration,
+			FixedBy:        p.FixedBy,
+			FixQuality:     p.FixQuality,
+			FixType:        p.FixType,
+			HDOP:           p.HDOP,
+			VDOP:           p.VDOP,
+			PDOP:           p.PDOP,
+			Satellites:     p.Satellites,
+			Accuracy:       p.Accuracy,
+			Course:         p.Course,
+			HeartRate:      p.HeartRate,
+			Cadence:        p.Cadence,
+			Temperature:    p.Temperature,
+			Distance:       p.Distance,
+			Lap:            p.Lap,
+			Track:          p.Track,
+			Segment:        p.Segment,
+		}
+	}
+	return out
+}
+
+func toModelStatuses(statuses []PointStatus) []model.PointStatus {
+	if statuses == nil {
+		return nil
+	}
+	out := make([]model.PointStatus, len(statuses))
+	for i, s := range statuses {
+		out[i] = model.PointStatus(s)
+	}
+	return out
+}
+
+func fromModelStatuses(statuses []model.PointStatus) []PointStatus {
+	if statuses == nil {
+		return nil
+	}
+	out := make([]PointStatus, len(statuses))
+	for i, s := range statuses {
+		out[i] = PointStatus(s)
+	}
+	return out
+}
+
+func fromModelStats(s model.TrajectoryStats) Stats {
+	return Stats{
+		PointCount:   s.PointCount,
+		Distance:     s.Distance,
+		DurationSecs: s.DurationSecs,
+		Bounds:       Bounds(s.Bounds),
+		Elevation:    ElevationStats(s.Elevation),
+		AvgSpeed:     s.AvgSpeed,
+		MaxSpeed:     s.MaxSpeed,
+	}
+}
+
+func toModelAnomalies(anomalies []Anomaly) []model.Anomaly {
+	if anomalies == nil {
+		return nil
+	}
+	out := make([]model.Anomaly, len(anomalies))
+	for i, a := range anomalies {
+		out[i] = model.Anomaly{
+			Type:        model.AnomalyType(a.Type),
+			Description: a.Description,
+			Count:       a.Count,
+			Severity:    model.Severity(a.Severity),
+			Indices:     a.Indices,
+			Gaps:        a.Gaps,
+		}
+	}
+	return out
+}
+
+func fromModelAnomalies(anomalies []model.Anomaly) []Anomaly {
+	if anomalies == nil {
+		return nil
+	}
+	out := make([]Anomaly, len(anomalies))
+	for i, a := range anomalies {
+		out[i] = Anomaly{
+			Type:        AnomalyType(a.Type),
+			Description: a.Description,
+			Count:       a.Count,
+			Severity:    Severity(a.Severity),
+			Indices:     a.Indices,
+			Gaps:        a.Gaps,
+		}
+	}
+	return out
+}
+
+func fromModelHealthScore(h model.HealthScore) HealthScore {
+	score := HealthScore{Total: h.Total, Rating: Rating(h.Rating)}
+	if h.Breakdown != nil {
+		score.Breakdown = make(map[string]ScoreDetail, len(h.Breakdown))
+		for k, d := range h.Breakdown {
+			score.Breakdown[k] = ScoreDetail(d)
+		}
+	}
+	return score
+}
+
+func fromModelDiagnostics(d model.DiagnosticsInfo) Diagnostics {
+	algorithms := make([]AlgorithmInfo, len(d.Algorithms))
+	for i, a := range d.Algorithms {
+		algorithms[i] = AlgorithmInfo(a)
+	}
+	return Diagnostics{
+		NormalPoints:       d.NormalPoints,
+		AnomalyPoints:      d.AnomalyPoints,
+		FixedPoints:        d.FixedPoints,
+		RemovedPoints:      d.RemovedPoints,
+		InterpolatedPoints: d.InterpolatedPoints,
+		TotalProcessed:     d.TotalProcessed,
+		Anomalies:          fromModelAnomalies(d.Anomalies),
+		Algorithms:         algorithms,
+		HealthScore:        fromModelHealthScore(d.HealthScore),
+		Profile:            d.Profile,
+		ProfileInferred:    d.ProfileInferred,
+	}
+}
+
+func fromModelSegments(segments []model.ModeSegment) []ModeSegment {
+	if segments == nil {
+		return nil
+	}
+	out := make([]ModeSegment, len(segments))
+	for i, s := range segments {
+		out[i] = ModeSegment(s)
+	}
+	return out
+}
+
+func fromModelStops(stops []model.Stop) []Stop {
+	if stops == nil {
+		return nil
+	}
+	out := make([]Stop, len(stops))
+	for i, s := range stops {
+		out[i] = Stop(s)
+	}
+	return out
+}
+
+func toModelParameters(p Parameters) model.AlgorithmParameters {
+	return model.AlgorithmParameters{
+		AdaptiveRTS:         model.RTSParameters(p.AdaptiveRTS),
+		SplineInterpolation: model.SplineParameters(p.SplineInterpolation),
+		Simplification:      model.SimplifyParameters(p.Simplification),
+	}
+}
+
+func fromModelParameters(p model.AlgorithmParameters) Parameters {
+	return Parameters{
+		AdaptiveRTS:         RTSParameters(p.AdaptiveRTS),
+		SplineInterpolation: SplineParameters(p.SplineInterpolation),
+		Simplification:      SimplifyParameters(p.Simplification),
+	}
+}
+
+func toModelStepConfigs(steps []StepConfig) []model.StepConfig {
+	if steps == nil {
+		return nil
+	}
+	out := make([]model.StepConfig, len(steps))
+	for i, s := range steps {
+		out[i] = model.StepConfig(s)
+	}
+	return out
+}
+
+func toModelParameterSpecs(specs []ParameterSpec) []model.ParameterSpec {
+	if specs == nil {
+		return nil
+	}
+	out := make([]model.ParameterSpec, len(specs))
+	for i, s := range specs {
+		out[i] = model.ParameterSpec(s)
+	}
+	return out
+}
+
+// stepAdapter runs a public Step in the internal pipeline
+type stepAdapter struct {
+	step Step
+}
+
+func (a stepAdapter) Name() string { return a.step.Name() }
+
+func (a stepAdapter) Parameters() []model.ParameterSpec {
+	return toModelParameterSpecs(a.step.Parameters())
+}
+
+func (a stepAdapter) Apply(ctx context.Context, points []model.Point, params algorithm.Params,
+	input algorithm.StepInput) ([]model.Point, algorithm.StepStats, error) {
+	corrected, stats, err := a.step.Apply(ctx, fromModelPoints(points), Params(params), StepInput{
+		Anomalies: fromModelAnomalies(input.Anomalies),
+		Stops:     fromModelStops(input.Stops),
+	})
+	if err != nil {
+		return nil, algorithm.StepStats{}, err
+	}
+	return toModelPoints(corrected), algorithm.StepStats(stats), nil
+}
+
+// DisplayName falls back to Name, as the algorithm report does for steps
+// without one
+func (a stepAdapter) DisplayName() string {
+	if d, ok := a.step.(interface{ DisplayName() string }); ok {
+		return d.DisplayName()
+	}
+	return a.step.Name()
+}
+
+func (a stepAdapter) Description() string {
+	if d, ok := a.step.(interface{ Description() string }); ok {
+		return d.Description()
+	}
+	return ""
+}
+
+func (a stepAdapter) CheckParameters(params algorithm.Params) error {
+	if c, ok := a.step.(interface{ CheckParameters(Params) error }); ok {
+		return c.CheckParameters(Params(params))
+	}
+	return nil
+}
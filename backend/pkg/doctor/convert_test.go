@@ -0,0 +1,82 @@
+package doctor
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/positiondoctor/backend/internal/model"
+)
+
+// jsonNames returns the JSON field names of a struct type
+func jsonNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The public types mirror the internal model. A field added on one side
+// must be added on the other and to the conversions on purpose.
+func TestTypes_MatchModel(t *testing.T) {
+	pairs := []struct {
+		public, internal interface{}
+	}{
+		{Point{}, model.Point{}},
+		{Stats{}, model.TrajectoryStats{}},
+		{Anomaly{}, model.Anomaly{}},
+		{HealthScore{}, model.HealthScore{}},
+		{Diagnostics{}, model.DiagnosticsInfo{}},
+		{Thresholds{}, model.ThresholdOptions{}},
+		{Parameters{}, model.AlgorithmParameters{}},
+	}
+
+	for _, p := range pairs {
+		public, internal := reflect.TypeOf(p.public), reflect.TypeOf(p.internal)
+		if got, want := jsonNames(public), jsonNames(internal); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s fields = %v, want those of model.%s %v", public.Name(), got, internal.Name(), want)
+		}
+	}
+}
+
+func TestConstants_MatchModel(t *testing.T) {
+	pairs := [][2]string{
+		{StepAdaptiveRTS, model.StepAdaptiveRTS},
+		{StepSplineInterpolation, model.StepSplineInterpolation},
+		{StepSimplification, model.StepSimplification},
+		{StepOutlierRemoval, model.StepOutlierRemoval},
+		{ProfileWalk, model.ProfileWalk},
+		{ProfileBike, model.ProfileBike},
+		{ProfileCar, model.ProfileCar},
+		{ProfileTrain, model.ProfileTrain},
+		{ProfileFlight, model.ProfileFlight},
+		{ProfileBoat, model.ProfileBoat},
+		{ProfileAuto, model.ProfileAuto},
+		{string(StatusJump), string(model.StatusJump)},
+		{string(AnomalyDensity), string(model.AnomalyDensity)},
+		{ParameterInteger, model.ParameterInteger},
+	}
+
+	for _, p := range pairs {
+		if p[0] != p[1] {
+			t.Errorf("constant %q, want %q", p[0], p[1])
+		}
+	}
+}
+
+func TestPoints_RoundTrip(t *testing.T) {
+	points := createPoints(3)
+	points[1].Flags = []PointStatus{StatusJump, StatusDrift}
+	points[2].HeartRate = 140
+
+	got := fromModelPoints(toModelPoints(points))
+	if !reflect.DeepEqual(got, points) {
+		t.Errorf("round trip = %+v, want %+v", got, points)
+	}
+}
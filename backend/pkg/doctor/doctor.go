@@ -0,0 +1,170 @@
+// Package doctor embeds PositionDoctor's trajectory diagnosis in Go programs.
+// It parses track files, detects anomalies, corrects and scores trajectories
+// and exports the result in-process, without running the HTTP server.
+//
+// The package is the stable public API of PositionDoctor; see APIVersion. Its
+// types are converted to and from the server's internal model at the
+// package boundary.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/positiondoctor/backend/internal/algorithm"
+	"github.com/positiondoctor/backend/internal/diagnosis"
+	"github.com/positiondoctor/backend/internal/model"
+	"github.com/positiondoctor/backend/internal/parser"
+)
+
+// Doctor diagnoses and corrects trajectories. It is safe for concurrent use.
+type Doctor struct {
+	service *diagnosis.Service
+	request model.DiagnoseRequest
+}
+
+// New creates a Doctor. It returns an error for invalid thresholds,
+// parameters or steps.
+func New(opts ...Option) (*Doctor, error) {
+	defaults := model.DefaultRequest()
+	s := &settings{
+		epsilon: defaults.Output.SimplifyEpsilon,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.thresholds.MaxSpeed < 0 || s.thresholds.MaxAcceleration < 0 || s.thresholds.MaxJump < 0 {
+		return nil, fmt.Errorf("thresholds must be positive")
+	}
+
+	registry := algorithm.NewRegistry()
+	for _, step := range s.customSteps {
+		if err := registry.Register(stepAdapter{step}); err != nil {
+			return nil, err
+		}
+	}
+
+	request := defaults
+	request.Profile = s.profile
+	request.Thresholds = model.ThresholdOptions(s.thresholds)
+	request.Parameters = toModelParameters(s.parameters)
+	request.Output.SimplifyEpsilon = s.epsilon
+	request.Steps = toModelStepConfigs(s.steps)
+
+	d := &Doctor{
+		service: diagnosis.NewServiceWithRegistry(registry),
+		request: request,
+	}
+	if err := d.service.Validate(request); err != nil {
+		return nil, fmt.Errorf("doctor: %w", err)
+	}
+
+	return d, nil
+}
+
+// Diagnose detects anomalies, corrects the trajectory and scores it. The
+// input points are not modified.
+func (d *Doctor) Diagnose(ctx context.Context, points []Point) (*Report, error) {
+	if len(points) < 2 {
+		return nil, fmt.Errorf("at least 2 points are required, got %d", len(points))
+	}
+
+	result, err := d.service.Diagnose(ctx, toModelPoints(points), d.request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		APIVersion:  APIVersion,
+		ID:          result.ReportID,
+		Original:    fromModelStats(result.Original),
+		Corrected:   fromModelStats(result.Corrected),
+		Diagnostics: fromModelDiagnostics(result.Diagnostics),
+		Segments:    fromModelSegments(result.Segments),
+		Stops:       fromModelStops(result.Stops),
+		Points:      fromModelPoints(result.Points),
+	}, nil
+}
+
+// DiagnoseFile parses a track file and diagnoses it. The format is detected
+// from the content, falling back to the filename's extension.
+func (d *Doctor) DiagnoseFile(ctx context.Context, filename string, data []byte) (*Report, error) {
+	points, err := Parse(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	return d.Diagnose(ctx, points)
+}
+
+// Detect returns the anomalies in a trajectory without correcting it
+func (d *Doctor) Detect(ctx context.Context, points []Point) ([]Anomaly, error) {
+	anomalies, err := d.service.Detect(ctx, toModelPoints(points), d.request)
+	if err != nil {
+		return nil, err
+	}
+	return fromModelAnomalies(anomalies), nil
+}
+
+// Correct returns the corrected trajectory
+func (d *Doctor) Correct(ctx context.Context, points []Point) ([]Point, error) {
+	report, err := d.Diagnose(ctx, points)
+	if err != nil {
+		return nil, err
+	}
+	return report.Points, nil
+}
+
+// Score rates a trajectory given its anomalies
+func Score(points []Point, anomalies []Anomaly) HealthScore {
+	return fromModelHealthScore(algorithm.NewHealthScorer().Calculate(toModelPoints(points), toModelAnomalies(anomalies)))
+}
+
+// Profiles returns the presets of every transport mode profile
+func Profiles() []Profile {
+	profiles := model.Profiles()
+	out := make([]Profile, len(profiles))
+	for i, p := range profiles {
+		out[i] = Profile{
+			Name:       p.Name,
+			Thresholds: Thresholds(p.Thresholds),
+			Parameters: fromModelParameters(p.Parameters),
+		}
+	}
+	return out
+}
+
+// Parse parses a track file. The format is detected from the content,
+// falling back to the filename's extension; gzip files and archives holding
+// one track are unwrapped.
+func Parse(filename string, data []byte) ([]Point, error) {
+	points, err := parser.NewParserFactory().ParseFile(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	return fromModelPoints(points), nil
+}
+
+// ParseFormat parses data in the given format, e.g. "gpx" or "nmea"
+func ParseFormat(format string, data []byte) ([]Point, error) {
+	points, err := parser.NewParserFactory().ParseAs(format, data)
+	if err != nil {
+		return nil, err
+	}
+	return fromModelPoints(points), nil
+}
+
+// Formats returns the formats Parse understands
+func Formats() []string {
+	return parser.SupportedFormats()
+}
+
+// Export writes points as a file in the given format: gpx, kml, tcx, fit,
+// csv, geojson or json
+func Export(points []Point, format string) ([]byte, error) {
+	if format == "json" {
+		return json.Marshal(points)
+	}
+	return parser.Export(format, toModelPoints(points), "PositionDoctor Corrected Trajectory")
+}
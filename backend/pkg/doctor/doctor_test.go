@@ -0,0 +1,106 @@
+package doctor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func createPoints(n int) []Point {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = Point{
+			Lat:  39.9042 + float64(i)*0.00005,
+			Lon:  116.4074,
+			Time: start.Add(time.Duration(i) * 5 * time.Second),
+		}
+	}
+	return points
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"negative speed", []Option{WithMaxSpeed(-1)}},
+		{"unknown step", []Option{WithSteps(StepConfig{Name: "unknown"})}},
+		{"parameter out of range", []Option{WithParameters(Parameters{AdaptiveRTS: RTSParameters{ProcessNoise: 1e9}})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts...); err == nil {
+				t.Errorf("New() should fail")
+			}
+		})
+	}
+}
+
+func TestDoctor_DiagnoseKeepsInput(t *testing.T) {
+	d, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	points := createPoints(50)
+	points[25].Lon += 0.02
+	lon := points[25].Lon
+
+	report, err := d.Diagnose(context.Background(), points)
+	if err != nil {
+		t.Fatalf("Diagnose() error = %v", err)
+	}
+	if report.APIVersion != APIVersion {
+		t.Errorf("APIVersion = %s, want %s", report.APIVersion, APIVersion)
+	}
+	if len(report.Diagnostics.Anomalies) == 0 {
+		t.Errorf("Diagnose() should report the jump")
+	}
+	if points[25].Lon != lon || points[25].Status != "" {
+		t.Errorf("Diagnose() modified its input")
+	}
+}
+
+func TestDoctor_DiagnoseTooFewPoints(t *testing.T) {
+	d, _ := New()
+	if _, err := d.Diagnose(context.Background(), createPoints(1)); err == nil {
+		t.Errorf("Diagnose() should reject a single point")
+	}
+}
+
+func TestDoctor_ThresholdsWinOverProfile(t *testing.T) {
+	// About 80 km/h
+	points := createPoints(50)
+	for i := range points {
+		points[i].Lat = 39.9042 + float64(i)*0.001
+	}
+
+	for _, tt := range []struct {
+		name    string
+		opts    []Option
+		flagged bool
+	}{
+		{"walk preset", []Option{WithProfile(ProfileWalk)}, true},
+		{"explicit max speed", []Option{WithProfile(ProfileWalk), WithMaxSpeed(120)}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New(tt.opts...)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			anomalies, err := d.Detect(context.Background(), points)
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			flagged := false
+			for _, a := range anomalies {
+				flagged = flagged || a.Type == "speed_anomaly"
+			}
+			if flagged != tt.flagged {
+				t.Errorf("speed anomaly reported = %v, want %v", flagged, tt.flagged)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package doctor_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/positiondoctor/backend/pkg/doctor"
+)
+
+// track returns a straight walk north with one GPS jump
+func track() []doctor.Point {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]doctor.Point, 60)
+	for i := range points {
+		points[i] = doctor.Point{
+			Lat:  39.9042 + float64(i)*0.00005,
+			Lon:  116.4074,
+			Time: start.Add(time.Duration(i) * 5 * time.Second),
+		}
+	}
+	points[30].Lon += 0.02
+	return points
+}
+
+func Example() {
+	d, err := doctor.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := d.Diagnose(context.Background(), track())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, a := range report.Diagnostics.Anomalies {
+		fmt.Println(a.Type, a.Severity)
+	}
+	fmt.Println("points:", len(report.Points))
+	// Output:
+	// speed_anomaly high
+	// acceleration_anomaly medium
+	// jump high
+	// density_anomaly high
+	// points: 14
+}
+
+func ExampleNew_options() {
+	d, err := doctor.New(
+		doctor.WithMaxSpeed(25),
+		doctor.WithParameters(doctor.Parameters{
+			AdaptiveRTS: doctor.RTSParameters{ProcessNoise: 2},
+		}),
+		doctor.WithSteps(
+			doctor.StepConfig{Name: doctor.StepOutlierRemoval},
+			doctor.StepConfig{Name: doctor.StepAdaptiveRTS},
+		),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := d.Diagnose(context.Background(), track())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, info := range report.Diagnostics.Algorithms {
+		fmt.Println(info.Name)
+	}
+	fmt.Println("processNoise:", report.Diagnostics.Algorithms[1].Parameters["processNoise"])
+	// Output:
+	// 离群点移除器
+	// 自适应RTS平滑器
+	// processNoise: 2
+}
+
+// northShift is an in-house correction step
+type northShift struct{}
+
+func (northShift) Name() string { return "northShift" }
+
+func (northShift) Parameters() []doctor.ParameterSpec {
+	return []doctor.ParameterSpec{{Name: "meters", Type: "number", Default: 1.0, Min: 0, Max: 100}}
+}
+
+func (northShift) Apply(ctx context.Context, points []doctor.Point, params doctor.Params, input doctor.StepInput) ([]doctor.Point, doctor.StepStats, error) {
+	for i := range points {
+		points[i].Lat += params.Float("meters") / 111320
+	}
+	return points, doctor.StepStats{}, nil
+}
+
+func ExampleWithStep() {
+	d, err := doctor.New(
+		doctor.WithStep(northShift{}),
+		doctor.WithSteps(doctor.StepConfig{Name: "northShift", Parameters: map[string]interface{}{"meters": 10.0}}),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	points := track()
+	corrected, err := d.Correct(context.Background(), points)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%.1f m\n", (corrected[0].Lat-points[0].Lat)*111320)
+	// Output:
+	// 10.0 m
+}
+
+func ExampleExport() {
+	points, err := doctor.Parse("walk.csv", []byte("lat,lon,time\n"+
+		"39.9042,116.4074,2024-01-01T08:00:00Z\n"+
+		"39.9043,116.4075,2024-01-01T08:00:05Z\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := doctor.Export(points, "geojson")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(string(data))
+	// Output:
+	// {"geometry":{"coordinates":[[116.4074,39.9042],[116.4075,39.9043]],"type":"LineString"},"properties":{"name":"PositionDoctor Corrected Trajectory"},"type":"Feature"}
+}
@@ -0,0 +1,85 @@
+package doctor
+
+// Option configures a Doctor
+type Option func(*settings)
+
+// settings collects options before New validates them
+type settings struct {
+	profile     string
+	thresholds  Thresholds
+	parameters  Parameters
+	epsilon     float64
+	steps       []StepConfig
+	customSteps []Step
+}
+
+// WithProfile presets thresholds and parameters for a transport mode: one of
+// the Profile constants, or ProfileAuto to infer it from each trajectory.
+// Thresholds and parameters set with other options win over the preset.
+func WithProfile(name string) Option {
+	return func(s *settings) {
+		s.profile = name
+	}
+}
+
+// WithThresholds replaces all anomaly detection thresholds; zero values take
+// the profile's preset
+func WithThresholds(t Thresholds) Option {
+	return func(s *settings) {
+		s.thresholds = t
+	}
+}
+
+// WithMaxSpeed sets the speed above which points are anomalous, in km/h
+func WithMaxSpeed(kmh float64) Option {
+	return func(s *settings) {
+		s.thresholds.MaxSpeed = kmh
+	}
+}
+
+// WithMaxAcceleration sets the acceleration above which points are
+// anomalous, in m/s²
+func WithMaxAcceleration(ms2 float64) Option {
+	return func(s *settings) {
+		s.thresholds.MaxAcceleration = ms2
+	}
+}
+
+// WithMaxJump sets the distance between consecutive points above which a
+// jump is reported, in meters
+func WithMaxJump(meters float64) Option {
+	return func(s *settings) {
+		s.thresholds.MaxJump = meters
+	}
+}
+
+// WithParameters tunes the built-in correction algorithms; zero values keep
+// the defaults
+func WithParameters(p Parameters) Option {
+	return func(s *settings) {
+		s.parameters = p
+	}
+}
+
+// WithSimplifyEpsilon sets the Douglas-Peucker tolerance in meters
+func WithSimplifyEpsilon(meters float64) Option {
+	return func(s *settings) {
+		s.epsilon = meters
+	}
+}
+
+// WithSteps sets the correction steps and their order. Without it the
+// built-in steps run in the order RTS, spline, Douglas-Peucker, outlier
+// removal.
+func WithSteps(steps ...StepConfig) Option {
+	return func(s *settings) {
+		s.steps = append([]StepConfig{}, steps...)
+	}
+}
+
+// WithStep makes a custom correction step available to WithSteps
+func WithStep(step Step) Option {
+	return func(s *settings) {
+		s.customSteps = append(s.customSteps, step)
+	}
+}
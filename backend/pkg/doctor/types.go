@@ -0,0 +1,361 @@
+package doctor
+
+import (
+	"context"
+	"time"
+)
+
+// APIVersion is the version of this package's API and of the types below.
+// Within a major version, fields are only added and JSON names never change.
+// The types are defined here rather than re-exported, so changes to the
+// server's internal model do not reach them.
+const APIVersion = "1.0.0"
+
+// Point is one trajectory fix
+type Point struct {
+	Index          int           `json:"index"` // Position in the input track
+	Lat            float64       `json:"lat"`
+	Lon            float64       `json:"lon"`
+	Time           time.Time     `json:"time"`
+	Elevation      float64       `json:"elevation,omitempty"` // meters
+	Status         PointStatus   `json:"status"`
+	Flags          []PointStatus `json:"flags,omitempty"` // Every anomaly status detected, strongest first
+	IsInterpolated bool          `json:"isInterpolated,omitempty"`
+	OriginalLat    float64       `json:"originalLat,omitempty"` // Position before correction
+	OriginalLon    float64       `json:"originalLon,omitempty"`
+	Speed          float64       `json:"speed,omitempty"` // km/h
+	Bearing        float64       `json:"bearing,omitempty"`
+	Acceleration   float64       `json:"acceleration,omitempty"` // m/s²
+	FixedBy        string        `json:"fixedBy,omitempty"`      // Which algorithm fixed this point
+	// Receiver quality indicators
+	FixQuality int      `json:"fixQuality,omitempty"` // NMEA GGA fix quality (0 when unknown)
+	FixType    string   `json:"fixType,omitempty"`    // GPX fix: none, 2d, 3d, dgps or pps
+	HDOP       float64  `json:"hdop,omitempty"`
+	VDOP       float64  `json:"vdop,omitempty"`
+	PDOP       float64  `json:"pdop,omitempty"`
+	Satellites int      `json:"satellites,omitempty"`
+	Accuracy   float64  `json:"accuracy,omitempty"` // Horizontal accuracy reported by the device, meters
+	Course     *float64 `json:"course,omitempty"`   // Heading reported by the device, degrees; nil when absent
+	// Sensor data recorded alongside the fix
+	HeartRate   int      `json:"heartRate,omitempty"`   // bpm
+	Cadence     int      `json:"cadence,omitempty"`     // rpm
+	Temperature *float64 `json:"temperature,omitempty"` // Ambient temperature, °C; nil when absent
+	Distance    float64  `json:"distance,omitempty"`    // Cumulative distance reported by the device, meters
+	Lap         int      `json:"lap,omitempty"`         // Lap number (TCX), 0-based
+	// Source file structure, 0-based
+	Track   int `json:"track,omitempty"`
+	Segment int `json:"segment,omitempty"` // Segment within the track
+}
+
+// PointStatus classifies a point after diagnosis
+type PointStatus string
+
+// Point statuses
+const (
+	StatusNormal       PointStatus = "normal"
+	StatusDrift        PointStatus = "drift"
+	StatusJump         PointStatus = "jump"
+	StatusSpeedAnomaly PointStatus = "speed_anomaly"
+	StatusAccelAnomaly PointStatus = "acceleration_anomaly"
+	StatusMissing      PointStatus = "missing"
+	StatusInterpolated PointStatus = "interpolated"
+)
+
+// Stats summarises a trajectory
+type Stats struct {
+	PointCount   int            `json:"pointCount"`
+	Distance     float64        `json:"distance"` // meters
+	DurationSecs int64          `json:"durationSeconds"`
+	Bounds       Bounds         `json:"bounds"`
+	Elevation    ElevationStats `json:"elevation"`
+	AvgSpeed     float64        `json:"avgSpeed"` // km/h
+	MaxSpeed     float64        `json:"maxSpeed"` // km/h
+}
+
+// Bounds is the bounding box of a trajectory in degrees
+type Bounds struct {
+	North float64 `json:"north"`
+	South float64 `json:"south"`
+	East  float64 `json:"east"`
+	West  float64 `json:"west"`
+}
+
+// ElevationStats summarises a trajectory's elevation in meters
+type ElevationStats struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Gain float64 `json:"gain"`
+	Loss float64 `json:"loss"`
+	Avg  float64 `json:"avg"`
+}
+
+// Anomaly is one detected problem and the points it affects
+type Anomaly struct {
+	Type        AnomalyType `json:"type"`
+	Description string      `json:"description"`
+	Count       int         `json:"count"`
+	Severity    Severity    `json:"severity"`
+	Indices     []int       `json:"indices"`        // Affected points, as Point.Index values
+	Gaps        [][]int     `json:"gaps,omitempty"` // Start and end index of each gap, for missing data
+}
+
+// AnomalyType names a kind of anomaly
+type AnomalyType string
+
+// Anomaly types
+const (
+	AnomalyDrift        AnomalyType = "drift"
+	AnomalyJump         AnomalyType = "jump"
+	AnomalySpeedAnomaly AnomalyType = "speed_anomaly"
+	AnomalyAccelAnomaly AnomalyType = "acceleration_anomaly"
+	AnomalyMissing      AnomalyType = "missing"
+	AnomalyDensity      AnomalyType = "density_anomaly"
+)
+
+// Severity grades an anomaly
+type Severity string
+
+// Severities
+const (
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// HealthScore rates trajectory quality from 0 to 100
+type HealthScore struct {
+	Total     int                    `json:"total"`
+	Breakdown map[string]ScoreDetail `json:"breakdown"`
+	Rating    Rating                 `json:"rating"`
+}
+
+// ScoreDetail is one component of a HealthScore
+type ScoreDetail struct {
+	Score       float64 `json:"score"`
+	Weight      float64 `json:"weight"`
+	Description string  `json:"description"`
+}
+
+// Rating grades a HealthScore
+type Rating string
+
+// Ratings
+const (
+	RatingExcellent Rating = "excellent"
+	RatingGood      Rating = "good"
+	RatingFair      Rating = "fair"
+	RatingPoor      Rating = "poor"
+)
+
+// Diagnostics holds anomalies, per-algorithm results and the health score
+type Diagnostics struct {
+	NormalPoints       int             `json:"normalPoints"`
+	AnomalyPoints      int             `json:"anomalyPoints"`
+	FixedPoints        int             `json:"fixedPoints"`        // Faulty points removed
+	RemovedPoints      int             `json:"removedPoints"`      // Redundant points removed by simplification
+	InterpolatedPoints int             `json:"interpolatedPoints"` // Points added in gaps
+	TotalProcessed     int             `json:"totalProcessed"`     // FixedPoints + RemovedPoints
+	Anomalies          []Anomaly       `json:"anomalies"`
+	Algorithms         []AlgorithmInfo `json:"algorithms"`
+	HealthScore        HealthScore     `json:"healthScore"`
+	Profile            string          `json:"profile"`                   // Transport mode profile used
+	ProfileInferred    bool            `json:"profileInferred,omitempty"` // Profile chosen by ProfileAuto
+}
+
+// AlgorithmInfo reports what one correction step did
+type AlgorithmInfo struct {
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description"`
+	ProcessedPoints int                    `json:"processedPoints"`
+	FixedPoints     int                    `json:"fixedPoints"`
+	RemovedPoints   int                    `json:"removedPoints,omitempty"`
+	FixedIndices    []int                  `json:"fixedIndices,omitempty"`
+	Parameters      map[string]interface{} `json:"parameters"` // Parameters the step ran with
+}
+
+// ModeSegment is a stretch of a trajectory travelled in one transport mode
+type ModeSegment struct {
+	Mode         string    `json:"mode"`       // Profile name of the mode
+	StartIndex   int       `json:"startIndex"` // First point, as an index into the input track
+	EndIndex     int       `json:"endIndex"`   // Last point, inclusive
+	PointCount   int       `json:"pointCount"`
+	StartTime    time.Time `json:"startTime"`
+	EndTime      time.Time `json:"endTime"`
+	DurationSecs int64     `json:"durationSeconds"`
+	Distance     float64   `json:"distance"` // meters
+	AvgSpeed     float64   `json:"avgSpeed"` // km/h
+}
+
+// Stop is a place where a trajectory stayed for a while
+type Stop struct {
+	StartIndex int       `json:"startIndex"` // Arrival point, as an index into the input track
+	EndIndex   int       `json:"endIndex"`   // Departure point, inclusive
+	PointCount int       `json:"pointCount"` // Points in Ranges
+	Ranges     [][]int   `json:"ranges"`     // Start and end index of each stay; excursions between them are not part of the stop
+	Lat        float64   `json:"lat"`        // Centroid of the points in Ranges
+	Lon        float64   `json:"lon"`
+	Radius     float64   `json:"radius"` // Farthest point in Ranges from the centroid, meters
+	Arrival    time.Time `json:"arrival"`
+	Departure  time.Time `json:"departure"`
+	DwellSecs  int64     `json:"dwellSeconds"`
+}
+
+// Thresholds configure anomaly detection. Zero values take the profile's
+// preset.
+type Thresholds struct {
+	MaxSpeed        float64 `json:"maxSpeed"`        // km/h
+	MaxAcceleration float64 `json:"maxAcceleration"` // m/s²
+	MaxJump         float64 `json:"maxJump"`         // meters
+	DriftThreshold  float64 `json:"driftThreshold"`  // degrees
+}
+
+// Parameters tune the built-in correction algorithms. Zero values take the
+// profile's preset or the default.
+type Parameters struct {
+	AdaptiveRTS         RTSParameters      `json:"adaptiveRTS"`
+	SplineInterpolation SplineParameters   `json:"splineInterpolation"`
+	Simplification      SimplifyParameters `json:"simplification"`
+}
+
+// RTSParameters tune the adaptive RTS smoother
+type RTSParameters struct {
+	ProcessNoise     float64 `json:"processNoise,omitempty"`     // White-noise acceleration density, m²/s³
+	MeasurementNoise float64 `json:"measurementNoise,omitempty"` // Initial position variance per axis, m²
+	VBAlpha          float64 `json:"vbAlpha,omitempty"`          // Prior strength of the noise estimate
+	VBBeta           float64 `json:"vbBeta,omitempty"`           // Prior scale of the noise estimate, m²; vbAlpha × measurementNoise when unset
+}
+
+// SplineParameters tune spline gap filling
+type SplineParameters struct {
+	GapSeconds    int `json:"gapSeconds,omitempty"`    // Interval that counts as a gap
+	MaxGapSeconds int `json:"maxGapSeconds,omitempty"` // Longest gap that is filled
+	MaxPoints     int `json:"maxPoints,omitempty"`     // Most points added per gap
+}
+
+// SimplifyParameters tune Douglas-Peucker simplification
+type SimplifyParameters struct {
+	Epsilon       float64 `json:"epsilon,omitempty"`       // Tolerance in meters; WithSimplifyEpsilon when unset
+	ConsiderNoise *bool   `json:"considerNoise,omitempty"` // Widen the tolerance on noisy tracks
+}
+
+// Profile presets thresholds and parameters for a transport mode
+type Profile struct {
+	Name       string     `json:"name"`
+	Thresholds Thresholds `json:"thresholds"`
+	Parameters Parameters `json:"parameters"`
+}
+
+// StepConfig selects a correction step and its parameters
+type StepConfig struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Parameter types used in ParameterSpec
+const (
+	ParameterNumber  = "number"
+	ParameterInteger = "integer"
+	ParameterBoolean = "boolean"
+)
+
+// ParameterSpec describes one step parameter
+type ParameterSpec struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"` // One of the Parameter types
+	Default     interface{} `json:"default"`
+	Min         float64     `json:"min,omitempty"`
+	Max         float64     `json:"max,omitempty"`
+	Unit        string      `json:"unit,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// Step is a correction stage that can be added with WithStep. A step may
+// also implement DisplayName() string and Description() string for the
+// algorithm report, and CheckParameters(Params) error for constraints
+// beyond the per-parameter bounds.
+type Step interface {
+	// Name is the key used in StepConfig
+	Name() string
+	// Parameters describes the accepted parameters
+	Parameters() []ParameterSpec
+	// Apply corrects points and reports what changed; long-running steps
+	// should stop with ctx's error once it is canceled
+	Apply(ctx context.Context, points []Point, params Params, input StepInput) ([]Point, StepStats, error)
+}
+
+// Params holds a step's resolved parameter values
+type Params map[string]interface{}
+
+// Float returns a number parameter
+func (p Params) Float(name string) float64 {
+	switch v := p[name].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return 0
+}
+
+// Int returns an integer parameter
+func (p Params) Int(name string) int {
+	switch v := p[name].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// Bool returns a boolean parameter
+func (p Params) Bool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+
+// StepInput carries diagnosis results a step may use
+type StepInput struct {
+	Anomalies []Anomaly // Detected on the input track, indexed by Point.Index
+	Stops     []Stop    // Stay points of the input track, indexed by Point.Index
+}
+
+// StepStats reports what a step changed
+type StepStats struct {
+	FixedBy  string                 // Point.FixedBy label of points this step corrected
+	Added    int                    // Points inserted
+	Removed  int                    // Redundant points dropped
+	Rejected int                    // Faulty points dropped
+	Details  map[string]interface{} // Extra values for the algorithm report
+}
+
+// Names of the built-in correction steps
+const (
+	StepAdaptiveRTS         = "adaptiveRTS"
+	StepSplineInterpolation = "splineInterpolation"
+	StepSimplification      = "simplification"
+	StepOutlierRemoval      = "outlierRemoval"
+)
+
+// Transport mode profiles for WithProfile
+const (
+	ProfileWalk   = "walk"
+	ProfileBike   = "bike"
+	ProfileCar    = "car"
+	ProfileTrain  = "train"
+	ProfileFlight = "flight"
+	ProfileBoat   = "boat"
+	ProfileAuto   = "auto" // Inferred from each trajectory's speeds and accelerations
+)
+
+// Report is the result of diagnosing one trajectory
+type Report struct {
+	APIVersion  string        `json:"apiVersion"`
+	ID          string        `json:"reportId"`
+	Original    Stats         `json:"original"`
+	Corrected   Stats         `json:"corrected"`
+	Diagnostics Diagnostics   `json:"diagnostics"`
+	Segments    []ModeSegment `json:"segments,omitempty"` // Transport mode legs
+	Stops       []Stop        `json:"stops,omitempty"`    // Stay points
+	Points      []Point       `json:"points"`             // Corrected points
+}
@@ -18,6 +18,7 @@ export interface Point {
   time: string
   elevation?: number
   status: PointStatus
+  flags?: PointStatus[]
   isInterpolated?: boolean
   originalLat?: number
   originalLon?: number
@@ -116,6 +117,35 @@ export interface DiagnosticsInfo {
   anomalies: Anomaly[]
   algorithms: AlgorithmInfo[]
   healthScore: HealthScore
+  profile: TransportProfile
+  profileInferred?: boolean
+}
+
+// Transport mode leg of a track
+export interface ModeSegment {
+  mode: TransportProfile
+  startIndex: number
+  endIndex: number
+  pointCount: number
+  startTime: string
+  endTime: string
+  durationSeconds: number
+  distance: number
+  avgSpeed: number
+}
+
+// Place where a track stayed
+export interface Stop {
+  startIndex: number
+  endIndex: number
+  pointCount: number
+  ranges: [number, number][] // Start and end index of each stay
+  lat: number
+  lon: number
+  radius: number
+  arrival: string
+  departure: string
+  dwellSeconds: number
 }
 
 // Data interface
@@ -124,6 +154,8 @@ export interface Data {
   original: TrajectoryStats
   corrected: TrajectoryStats
   diagnostics: DiagnosticsInfo
+  segments?: ModeSegment[]
+  stops?: Stop[]
   points?: Point[]
 }
 
@@ -194,7 +226,11 @@ export interface OutputOptions {
 }
 
 // Diagnose request options
+// Transport mode profiles
+export type TransportProfile = 'walk' | 'bike' | 'car' | 'train' | 'flight' | 'boat'
+
 export interface DiagnoseOptions {
+  profile?: TransportProfile | 'auto'
   algorithms: AlgorithmOptions
   thresholds: ThresholdOptions
   output: OutputOptions